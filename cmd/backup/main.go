@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -17,7 +18,21 @@ import (
 
 // main is the entry point for the backup application.
 func main() {
-	if err := run(); err != nil {
+	runFn := run
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "auth":
+			runFn = func() error { return runAuth(os.Args[2:]) }
+		case "restore":
+			runFn = func() error { return runRestore(os.Args[2:]) }
+		case "repository":
+			runFn = func() error { return runRepository(os.Args[2:]) }
+		case "wal-push", "wal-fetch":
+			runFn = func() error { return runWAL(os.Args[1], os.Args[2:]) }
+		}
+	}
+
+	if err := runFn(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -76,3 +91,158 @@ func run() error {
 	log.Infof("Application stopped gracefully")
 	return nil
 }
+
+// runAuth implements `phylax auth gdrive`, which authorizes Google Drive
+// targets with the OAuth device flow and stores their tokens.
+func runAuth(args []string) error {
+	flags := flag.NewFlagSet("auth", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "path to configuration file (YAML)")
+	folderID := flags.String("folder", "", "only authorize the gdrive target with this folder_id")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s auth gdrive [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "gdrive" {
+		flags.Usage()
+		return errors.New("unknown auth provider")
+	}
+	flags.Parse(args[1:])
+
+	if envConfig := os.Getenv("PHYLAX_CONFIG"); envConfig != "" {
+		*configPath = envConfig
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return app.AuthorizeGDrive(ctx, cfg, *folderID, os.Stdout)
+}
+
+// runRestore implements `phylax restore`, which restores a MySQL database to
+// a point in time from a full dump and archived binlogs.
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "path to configuration file (YAML)")
+	dbName := flags.String("db", "", "name of the database to restore")
+	toTime := flags.String("to-time", "", "restore up to this time (\"2006-01-02 15:04:05\" local, or RFC 3339)")
+	from := flags.String("from", "", "upload target type to restore from (default: first that supports downloads)")
+	dryRun := flags.Bool("dry-run", false, "only print the dump and binlogs that would be applied")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s restore -db NAME -to-time TIME [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *dbName == "" || *toTime == "" {
+		flags.Usage()
+		return errors.New("-db and -to-time are required")
+	}
+
+	stopTime, err := time.ParseInLocation(time.DateTime, *toTime, time.Local)
+	if err != nil {
+		if stopTime, err = time.Parse(time.RFC3339, *toTime); err != nil {
+			return fmt.Errorf("invalid -to-time %q", *toTime)
+		}
+	}
+
+	if envConfig := os.Getenv("PHYLAX_CONFIG"); envConfig != "" {
+		*configPath = envConfig
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return app.RestoreToTime(ctx, cfg, app.RestoreOptions{
+		Database: *dbName,
+		ToTime:   stopTime,
+		From:     *from,
+		DryRun:   *dryRun,
+	})
+}
+
+// runRepository implements `phylax repository check`, which verifies the
+// deduplicating repositories of the upload targets.
+func runRepository(args []string) error {
+	flags := flag.NewFlagSet("repository", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "path to configuration file (YAML)")
+	from := flags.String("from", "", "upload target type to check (default: every repository target)")
+	readData := flags.Bool("read-data", false, "download every chunk and verify its hash")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s repository check [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "check" {
+		flags.Usage()
+		return errors.New("unknown repository command")
+	}
+	flags.Parse(args[1:])
+
+	if envConfig := os.Getenv("PHYLAX_CONFIG"); envConfig != "" {
+		*configPath = envConfig
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return app.CheckRepositories(ctx, cfg, *from, *readData)
+}
+
+// runWAL implements `phylax wal-push %p` and `phylax wal-fetch %f %p`, for
+// use as PostgreSQL's archive_command and restore_command.
+func runWAL(command string, args []string) error {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "path to configuration file (YAML)")
+	dbName := flags.String("db", "", "postgresql database the WAL belongs to (default: the only one configured)")
+	flags.Usage = func() {
+		if command == "wal-push" {
+			fmt.Fprintf(flags.Output(), "Usage: %s wal-push [flags] WAL_PATH\n", os.Args[0])
+		} else {
+			fmt.Fprintf(flags.Output(), "Usage: %s wal-fetch [flags] WAL_NAME DEST_PATH\n", os.Args[0])
+		}
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	want := 1
+	if command == "wal-fetch" {
+		want = 2
+	}
+	if flags.NArg() != want {
+		flags.Usage()
+		return errors.New("wrong number of arguments")
+	}
+
+	if envConfig := os.Getenv("PHYLAX_CONFIG"); envConfig != "" {
+		*configPath = envConfig
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if command == "wal-push" {
+		return app.WALPush(ctx, cfg, *dbName, flags.Arg(0))
+	}
+	return app.WALFetch(ctx, cfg, *dbName, flags.Arg(0), flags.Arg(1))
+}
@@ -0,0 +1,133 @@
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	formatNone = "none"
+	formatGzip = "gzip"
+	formatZstd = "zstd"
+	formatXz   = "xz"
+	formatLz4  = "lz4"
+)
+
+// magics maps the leading bytes of each compressed format to the format.
+var magics = []struct {
+	format string
+	magic  []byte
+}{
+	{formatGzip, []byte{0x1f, 0x8b}},
+	{formatZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{formatXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{formatLz4, []byte{0x04, 0x22, 0x4d, 0x18}},
+}
+
+// detectFormat identifies the compression format from the magic bytes at
+// the start of r, returning "" when it is not recognized.
+func detectFormat(r *bufio.Reader) string {
+	header, _ := r.Peek(6)
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format
+		}
+	}
+	return ""
+}
+
+// compressFile streams sourcePath through the writer returned by newWriter
+// into destPath.
+func compressFile(sourcePath, destPath, name string, newWriter func(io.Writer) (io.WriteCloser, error)) error {
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create dest file: %w", err)
+	}
+	defer destFile.Close()
+
+	writer, err := newWriter(destFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s writer: %w", name, err)
+	}
+
+	if _, err := io.Copy(writer, sourceFile); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	return destFile.Close()
+}
+
+// decompressFile decompresses sourcePath into destPath, detecting the format
+// from its magic bytes and falling back to defaultFormat.
+func decompressFile(sourcePath, destPath, defaultFormat string) error {
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	source := bufio.NewReader(sourceFile)
+	format := detectFormat(source)
+	if format == "" {
+		format = defaultFormat
+	}
+
+	reader, err := newReader(format, source)
+	if err != nil {
+		return fmt.Errorf("failed to create %s reader: %w", format, err)
+	}
+	defer reader.Close()
+
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create dest file: %w", err)
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, reader); err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	return destFile.Close()
+}
+
+func newReader(format string, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case formatGzip:
+		return gzip.NewReader(r)
+	case formatZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case formatXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case formatLz4:
+		return io.NopCloser(lz4.NewReader(r)), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
@@ -2,65 +2,46 @@ package compressor
 
 import (
 	"compress/gzip"
-	"fmt"
 	"io"
-	"os"
 )
 
-type GzipCompressor struct{}
+// GzipCompressor compresses with gzip on a single core.
+type GzipCompressor struct {
+	level int
+}
 
+// NewGzip creates a GzipCompressor using the best compression level.
 func NewGzip() *GzipCompressor {
-	return &GzipCompressor{}
+	return NewGzipLevel(gzip.BestCompression)
 }
 
-func (g *GzipCompressor) Compress(sourcePath, destPath string) error {
-	sourceFile, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create dest file: %w", err)
-	}
-	defer destFile.Close()
-
-	gzipWriter, err := gzip.NewWriterLevel(destFile, gzip.BestCompression)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip writer: %w", err)
-	}
-	defer gzipWriter.Close()
-
-	if _, err := io.Copy(gzipWriter, sourceFile); err != nil {
-		return fmt.Errorf("failed to compress: %w", err)
+// NewGzipLevel creates a GzipCompressor with the given level (1-9). Zero
+// uses the best compression level.
+func NewGzipLevel(level int) *GzipCompressor {
+	if level == 0 {
+		level = gzip.BestCompression
 	}
+	return &GzipCompressor{level: level}
+}
 
-	return nil
+func (g *GzipCompressor) Compress(sourcePath, destPath string) error {
+	return compressFile(sourcePath, destPath, "gzip", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, g.level)
+	})
 }
 
+// Decompress writes the decompressed content of sourcePath to destPath. The
+// format is detected from the file's magic bytes, so backups made with other
+// algorithms can be restored too; anything unrecognized is read as gzip.
 func (g *GzipCompressor) Decompress(sourcePath, destPath string) error {
-	sourceFile, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer sourceFile.Close()
-
-	gzipReader, err := gzip.NewReader(sourceFile)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer gzipReader.Close()
-
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create dest file: %w", err)
-	}
-	defer destFile.Close()
+	return decompressFile(sourcePath, destPath, formatGzip)
+}
 
-	if _, err := io.Copy(destFile, gzipReader); err != nil {
-		return fmt.Errorf("failed to decompress: %w", err)
-	}
+// Extension returns the file extension for gzip output.
+func (g *GzipCompressor) Extension() string {
+	return ".gz"
+}
 
-	return nil
+func (g *GzipCompressor) Name() string {
+	return "gzip"
 }
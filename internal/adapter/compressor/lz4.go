@@ -0,0 +1,57 @@
+package compressor
+
+import (
+	"io"
+	"runtime"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// lz4Levels maps levels 0-9 to the library's compression levels.
+var lz4Levels = []lz4.CompressionLevel{
+	lz4.Fast, lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4,
+	lz4.Level5, lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+}
+
+// Lz4Compressor compresses with LZ4, trading ratio for very high speed.
+type Lz4Compressor struct {
+	level   int
+	workers int
+}
+
+// NewLz4 creates an Lz4Compressor. level 0 is the fast mode and 1-9 select
+// the high-compression levels; workers defaults to the number of CPUs.
+func NewLz4(level, workers int) *Lz4Compressor {
+	if level < 0 || level >= len(lz4Levels) {
+		level = 0
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Lz4Compressor{level: level, workers: workers}
+}
+
+func (l *Lz4Compressor) Compress(sourcePath, destPath string) error {
+	return compressFile(sourcePath, destPath, "lz4", func(w io.Writer) (io.WriteCloser, error) {
+		lw := lz4.NewWriter(w)
+		if err := lw.Apply(lz4.CompressionLevelOption(lz4Levels[l.level]), lz4.ConcurrencyOption(l.workers)); err != nil {
+			return nil, err
+		}
+		return lw, nil
+	})
+}
+
+// Decompress writes the decompressed content of sourcePath to destPath,
+// detecting the format from its magic bytes.
+func (l *Lz4Compressor) Decompress(sourcePath, destPath string) error {
+	return decompressFile(sourcePath, destPath, formatLz4)
+}
+
+// Extension returns the file extension for lz4 output.
+func (l *Lz4Compressor) Extension() string {
+	return ".lz4"
+}
+
+func (l *Lz4Compressor) Name() string {
+	return "lz4"
+}
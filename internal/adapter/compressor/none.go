@@ -0,0 +1,38 @@
+package compressor
+
+import "io"
+
+// NoneCompressor stores backups uncompressed, e.g. when the dump is already
+// compressed or the target deduplicates.
+type NoneCompressor struct{}
+
+func NewNone() *NoneCompressor {
+	return &NoneCompressor{}
+}
+
+func (n *NoneCompressor) Compress(sourcePath, destPath string) error {
+	return compressFile(sourcePath, destPath, "none", func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+}
+
+// Decompress copies sourcePath to destPath, decompressing it if its magic
+// bytes identify a supported format.
+func (n *NoneCompressor) Decompress(sourcePath, destPath string) error {
+	return decompressFile(sourcePath, destPath, formatNone)
+}
+
+// Extension returns an empty extension: files keep their names.
+func (n *NoneCompressor) Extension() string {
+	return ""
+}
+
+func (n *NoneCompressor) Name() string {
+	return "none"
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,60 @@
+package compressor
+
+import (
+	"compress/gzip"
+	"io"
+	"runtime"
+
+	"github.com/klauspost/pgzip"
+)
+
+// pgzipBlockSize is the amount of input each pgzip worker compresses at a
+// time.
+const pgzipBlockSize = 1 << 20
+
+// PgzipCompressor compresses blocks in parallel into a standard gzip stream,
+// readable by gunzip and GzipCompressor.
+type PgzipCompressor struct {
+	level   int
+	workers int
+}
+
+// NewPgzip creates a PgzipCompressor. level is 1-9 (default 6) and workers
+// defaults to the number of CPUs.
+func NewPgzip(level, workers int) *PgzipCompressor {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &PgzipCompressor{level: level, workers: workers}
+}
+
+func (p *PgzipCompressor) Compress(sourcePath, destPath string) error {
+	return compressFile(sourcePath, destPath, "pgzip", func(w io.Writer) (io.WriteCloser, error) {
+		gw, err := pgzip.NewWriterLevel(w, p.level)
+		if err != nil {
+			return nil, err
+		}
+		if err := gw.SetConcurrency(pgzipBlockSize, p.workers); err != nil {
+			return nil, err
+		}
+		return gw, nil
+	})
+}
+
+// Decompress writes the decompressed content of sourcePath to destPath,
+// detecting the format from its magic bytes.
+func (p *PgzipCompressor) Decompress(sourcePath, destPath string) error {
+	return decompressFile(sourcePath, destPath, formatGzip)
+}
+
+// Extension returns the file extension for gzip output.
+func (p *PgzipCompressor) Extension() string {
+	return ".gz"
+}
+
+func (p *PgzipCompressor) Name() string {
+	return "pgzip"
+}
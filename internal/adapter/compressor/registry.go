@@ -0,0 +1,32 @@
+package compressor
+
+import (
+	"fmt"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// Algorithms lists the supported compression algorithms.
+var Algorithms = []string{"gzip", "pgzip", "zstd", "xz", "lz4", "none"}
+
+// New creates the compressor selected by cfg. Level and worker count are
+// passed to algorithms that support them; zero selects each default.
+func New(cfg config.CompressionConfig) (domain.Compressor, error) {
+	switch cfg.Algorithm {
+	case "", "gzip":
+		return NewGzipLevel(cfg.Level), nil
+	case "pgzip":
+		return NewPgzip(cfg.Level, cfg.Workers), nil
+	case "zstd":
+		return NewZstd(cfg.Level, cfg.Workers), nil
+	case "xz":
+		return NewXz(cfg.Level), nil
+	case "lz4":
+		return NewLz4(cfg.Level, cfg.Workers), nil
+	case "none":
+		return NewNone(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %s", cfg.Algorithm)
+	}
+}
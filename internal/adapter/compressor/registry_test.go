@@ -0,0 +1,95 @@
+package compressor
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Given every registered algorithm", t, func() {
+		tempDir, err := os.MkdirTemp("", "compressor_registry_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		inputContent := []byte(strings.Repeat("INSERT INTO orders VALUES (42, 'pending', 19.99);\n", 20000))
+		inputFile := filepath.Join(tempDir, "backup.sql")
+		So(os.WriteFile(inputFile, inputContent, 0644), ShouldBeNil)
+
+		extensions := map[string]string{
+			"gzip": ".gz", "pgzip": ".gz", "zstd": ".zst", "xz": ".xz", "lz4": ".lz4", "none": "",
+		}
+
+		for _, algorithm := range Algorithms {
+			Convey("The "+algorithm+" compressor should round-trip and be detected by the others", func() {
+				compressor, err := New(config.CompressionConfig{Algorithm: algorithm, Level: 1, Workers: 2})
+				So(err, ShouldBeNil)
+				So(compressor.Name(), ShouldEqual, algorithm)
+				So(compressor.Extension(), ShouldEqual, extensions[algorithm])
+
+				compressed := filepath.Join(tempDir, "backup.sql"+compressor.Extension()+".out")
+				So(compressor.Compress(inputFile, compressed), ShouldBeNil)
+
+				info, err := os.Stat(compressed)
+				So(err, ShouldBeNil)
+				if algorithm != "none" {
+					So(info.Size(), ShouldBeLessThan, len(inputContent)/5)
+				}
+
+				// Uncompressed input is only accepted by "none"; the other
+				// decompressors fall back to their own format.
+				decompressors := []string{algorithm, "gzip", "zstd", "none"}
+				if algorithm == "none" {
+					decompressors = []string{"none"}
+				}
+				for _, other := range decompressors {
+					decompressor, _ := New(config.CompressionConfig{Algorithm: other})
+					restored := filepath.Join(tempDir, "restored-"+other)
+					So(decompressor.Decompress(compressed, restored), ShouldBeNil)
+
+					content, err := os.ReadFile(restored)
+					So(err, ShouldBeNil)
+					So(content, ShouldResemble, inputContent)
+				}
+			})
+		}
+
+		Convey("pgzip output should be a standard gzip stream", func() {
+			compressed := filepath.Join(tempDir, "backup.sql.gz")
+			So(NewPgzip(0, 4).Compress(inputFile, compressed), ShouldBeNil)
+
+			f, err := os.Open(compressed)
+			So(err, ShouldBeNil)
+			defer f.Close()
+			r, err := gzip.NewReader(f)
+			So(err, ShouldBeNil)
+			var n int64
+			buf := make([]byte, 32<<10)
+			for {
+				read, err := r.Read(buf)
+				n += int64(read)
+				if err != nil {
+					break
+				}
+			}
+			So(n, ShouldEqual, len(inputContent))
+		})
+
+		Convey("An empty algorithm should default to gzip", func() {
+			compressor, err := New(config.CompressionConfig{})
+			So(err, ShouldBeNil)
+			So(compressor.Name(), ShouldEqual, "gzip")
+		})
+
+		Convey("An unknown algorithm should return error", func() {
+			_, err := New(config.CompressionConfig{Algorithm: "brotli"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unsupported compression algorithm")
+		})
+	})
+}
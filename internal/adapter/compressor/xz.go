@@ -0,0 +1,49 @@
+package compressor
+
+import (
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// xzDictCaps maps xz presets 0-9 to their dictionary sizes.
+var xzDictCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20,
+	8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// XzCompressor compresses with xz (LZMA2) for the smallest output at the
+// cost of speed. The implementation is single-threaded.
+type XzCompressor struct {
+	level int
+}
+
+// NewXz creates an XzCompressor. level follows the xz presets (0-9,
+// default 6).
+func NewXz(level int) *XzCompressor {
+	if level <= 0 || level >= len(xzDictCaps) {
+		level = 6
+	}
+	return &XzCompressor{level: level}
+}
+
+func (x *XzCompressor) Compress(sourcePath, destPath string) error {
+	return compressFile(sourcePath, destPath, "xz", func(w io.Writer) (io.WriteCloser, error) {
+		return xz.WriterConfig{DictCap: xzDictCaps[x.level]}.NewWriter(w)
+	})
+}
+
+// Decompress writes the decompressed content of sourcePath to destPath,
+// detecting the format from its magic bytes.
+func (x *XzCompressor) Decompress(sourcePath, destPath string) error {
+	return decompressFile(sourcePath, destPath, formatXz)
+}
+
+// Extension returns the file extension for xz output.
+func (x *XzCompressor) Extension() string {
+	return ".xz"
+}
+
+func (x *XzCompressor) Name() string {
+	return "xz"
+}
@@ -0,0 +1,53 @@
+package compressor
+
+import (
+	"io"
+	"runtime"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultZstdLevel matches the zstd command line default.
+const defaultZstdLevel = 3
+
+// ZstdCompressor compresses with Zstandard using several goroutines.
+type ZstdCompressor struct {
+	level   int
+	workers int
+}
+
+// NewZstd creates a ZstdCompressor. level follows the zstd command line
+// scale (1-22, default 3) and workers defaults to the number of CPUs.
+func NewZstd(level, workers int) *ZstdCompressor {
+	if level <= 0 {
+		level = defaultZstdLevel
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &ZstdCompressor{level: level, workers: workers}
+}
+
+func (z *ZstdCompressor) Compress(sourcePath, destPath string) error {
+	return compressFile(sourcePath, destPath, "zstd", func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(z.level)),
+			zstd.WithEncoderConcurrency(z.workers))
+	})
+}
+
+// Decompress writes the decompressed content of sourcePath to destPath. The
+// format is detected from the file's magic bytes, so backups made with other
+// algorithms can be restored too; anything unrecognized is read as zstd.
+func (z *ZstdCompressor) Decompress(sourcePath, destPath string) error {
+	return decompressFile(sourcePath, destPath, formatZstd)
+}
+
+// Extension returns the file extension for zstd output.
+func (z *ZstdCompressor) Extension() string {
+	return ".zst"
+}
+
+func (z *ZstdCompressor) Name() string {
+	return "zstd"
+}
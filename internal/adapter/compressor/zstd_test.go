@@ -0,0 +1,114 @@
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	Convey("Given a ZstdCompressor", t, func() {
+		tempDir, err := os.MkdirTemp("", "zstd_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		compressor := NewZstd(19, 2)
+		inputContent := []byte(strings.Repeat("INSERT INTO users VALUES (1, 'alice');\n", 5000))
+		inputFile := filepath.Join(tempDir, "backup.sql")
+		So(os.WriteFile(inputFile, inputContent, 0644), ShouldBeNil)
+
+		Convey("Defaults should apply for zero settings", func() {
+			defaults := NewZstd(0, 0)
+			So(defaults.level, ShouldEqual, defaultZstdLevel)
+			So(defaults.workers, ShouldBeGreaterThan, 0)
+			So(defaults.Extension(), ShouldEqual, ".zst")
+		})
+
+		Convey("Compress should write a zstd stream", func() {
+			outputFile := filepath.Join(tempDir, "backup.sql.zst")
+			So(compressor.Compress(inputFile, outputFile), ShouldBeNil)
+
+			compressed, err := os.ReadFile(outputFile)
+			So(err, ShouldBeNil)
+			So(len(compressed), ShouldBeLessThan, len(inputContent)/10)
+
+			decoder, err := zstd.NewReader(bytes.NewReader(compressed))
+			So(err, ShouldBeNil)
+			defer decoder.Close()
+			var decompressed bytes.Buffer
+			_, err = decompressed.ReadFrom(decoder)
+			So(err, ShouldBeNil)
+			So(decompressed.Bytes(), ShouldResemble, inputContent)
+
+			Convey("And Decompress should restore it", func() {
+				restored := filepath.Join(tempDir, "restored.sql")
+				So(compressor.Decompress(outputFile, restored), ShouldBeNil)
+
+				content, err := os.ReadFile(restored)
+				So(err, ShouldBeNil)
+				So(content, ShouldResemble, inputContent)
+			})
+
+			Convey("And the gzip compressor should detect and restore it", func() {
+				restored := filepath.Join(tempDir, "restored.sql")
+				So(NewGzip().Decompress(outputFile, restored), ShouldBeNil)
+
+				content, err := os.ReadFile(restored)
+				So(err, ShouldBeNil)
+				So(content, ShouldResemble, inputContent)
+			})
+		})
+
+		Convey("Decompress should detect gzip input", func() {
+			gzipFile := filepath.Join(tempDir, "backup.sql.gz")
+			So(NewGzip().Compress(inputFile, gzipFile), ShouldBeNil)
+
+			restored := filepath.Join(tempDir, "restored.sql")
+			So(compressor.Decompress(gzipFile, restored), ShouldBeNil)
+
+			content, err := os.ReadFile(restored)
+			So(err, ShouldBeNil)
+			So(content, ShouldResemble, inputContent)
+		})
+
+		Convey("Decompress of unrecognized input should return error", func() {
+			invalidFile := filepath.Join(tempDir, "invalid")
+			So(os.WriteFile(invalidFile, []byte("not compressed"), 0644), ShouldBeNil)
+
+			err := compressor.Decompress(invalidFile, filepath.Join(tempDir, "out"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When the source file does not exist", func() {
+			err := compressor.Compress(filepath.Join(tempDir, "nonexistent"), filepath.Join(tempDir, "out.zst"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "failed to open source file")
+		})
+	})
+}
+
+func TestDetectFormat(t *testing.T) {
+	Convey("Given compressed and plain headers", t, func() {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		w.Write([]byte("data"))
+		w.Close()
+
+		zs, _ := zstd.NewWriter(nil)
+		zstdData := zs.EncodeAll([]byte("data"), nil)
+
+		Convey("It should identify each by magic bytes", func() {
+			So(detectFormat(bufio.NewReader(bytes.NewReader(gz.Bytes()))), ShouldEqual, formatGzip)
+			So(detectFormat(bufio.NewReader(bytes.NewReader(zstdData))), ShouldEqual, formatZstd)
+			So(detectFormat(bufio.NewReader(bytes.NewReader([]byte("plain")))), ShouldEqual, "")
+			So(detectFormat(bufio.NewReader(bytes.NewReader(nil))), ShouldEqual, "")
+		})
+	})
+}
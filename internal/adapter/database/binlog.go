@@ -0,0 +1,169 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// binlogPositionPattern matches the coordinates mysqldump writes with
+// --source-data (SOURCE_*) or --master-data (MASTER_*).
+var binlogPositionPattern = regexp.MustCompile(`(?:MASTER|SOURCE)_LOG_FILE='([^']+)',\s*(?:MASTER|SOURCE)_LOG_POS=(\d+)`)
+
+// binlogHeaderLines bounds how far into a dump the coordinates are searched;
+// mysqldump writes them before any table data.
+const binlogHeaderLines = 200
+
+// BinlogPosition reads the binlog coordinates recorded in a dump taken with
+// --source-data or --master-data.
+func (m *MySQLDatabase) BinlogPosition(dumpPath string) (domain.BinlogPosition, error) {
+	file, err := os.Open(dumpPath)
+	if err != nil {
+		return domain.BinlogPosition{}, fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for range binlogHeaderLines {
+		line, err := reader.ReadString('\n')
+		if matches := binlogPositionPattern.FindStringSubmatch(line); matches != nil {
+			position, err := strconv.ParseInt(matches[2], 10, 64)
+			if err != nil {
+				return domain.BinlogPosition{}, fmt.Errorf("invalid binlog position %q: %w", matches[2], err)
+			}
+			return domain.BinlogPosition{File: matches[1], Position: position}, nil
+		}
+		if strings.HasPrefix(line, "INSERT INTO") || err != nil {
+			break
+		}
+	}
+
+	return domain.BinlogPosition{}, errors.New("dump has no binlog coordinates; was it taken with binlog archiving enabled?")
+}
+
+// CurrentBinlog returns the binlog file the server is writing to.
+func (m *MySQLDatabase) CurrentBinlog(ctx context.Context) (string, error) {
+	args := append(m.connectionArgs(), "--batch", "--skip-column-names", "-e", "SHOW BINARY LOGS")
+
+	output, err := exec.CommandContext(ctx, "mysql", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list binary logs: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	current := strings.Fields(lines[len(lines)-1])
+	if len(current) == 0 {
+		return "", errors.New("binary logging is not enabled on the server")
+	}
+	return current[0], nil
+}
+
+// StreamBinlogs copies binlogs from the server into dir, starting with
+// startFile, until ctx is cancelled or the connection drops. Each binlog is
+// written verbatim under its server name.
+func (m *MySQLDatabase) StreamBinlogs(ctx context.Context, dir string, startFile string) error {
+	args := append([]string{"--read-from-remote-server"}, m.connectionArgs()...)
+	args = append(args,
+		"--raw",
+		"--stop-never",
+		fmt.Sprintf("--result-file=%s%c", filepath.Clean(dir), filepath.Separator),
+	)
+	if m.config.Binlog.ServerID > 0 {
+		args = append(args, fmt.Sprintf("--connection-server-id=%d", m.config.Binlog.ServerID))
+	}
+	args = append(args, startFile)
+
+	cmd := exec.CommandContext(ctx, m.binlogBinary(), args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mysqlbinlog failed: %w, output: %s", err, string(output))
+	}
+
+	return nil
+}
+
+// Restore loads a plain SQL dump into the configured database.
+func (m *MySQLDatabase) Restore(ctx context.Context, dumpPath string) error {
+	dump, err := os.Open(dumpPath)
+	if err != nil {
+		return fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer dump.Close()
+
+	cmd := exec.CommandContext(ctx, "mysql", append(m.connectionArgs(), m.config.Database)...)
+	cmd.Stdin = dump
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mysql restore failed: %w, output: %s", err, string(output))
+	}
+
+	return nil
+}
+
+// ReplayBinlogs applies the events of files, in order, from start up to
+// stopTime. Only events for the configured database are replayed.
+func (m *MySQLDatabase) ReplayBinlogs(ctx context.Context, files []string, start domain.BinlogPosition, stopTime time.Time) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	args := []string{
+		// --start-position applies to the first file only.
+		fmt.Sprintf("--start-position=%d", start.Position),
+		fmt.Sprintf("--stop-datetime=%s", stopTime.Local().Format(time.DateTime)),
+		fmt.Sprintf("--database=%s", m.config.Database),
+	}
+	args = append(args, files...)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	var decodeOutput, applyOutput bytes.Buffer
+	decode := exec.CommandContext(ctx, m.binlogBinary(), args...)
+	decode.Stdout = writer
+	decode.Stderr = &decodeOutput
+
+	apply := exec.CommandContext(ctx, "mysql", append(m.connectionArgs(), m.config.Database)...)
+	apply.Stdin = reader
+	apply.Stdout = &applyOutput
+	apply.Stderr = &applyOutput
+
+	if err := apply.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return fmt.Errorf("failed to start mysql: %w", err)
+	}
+	reader.Close()
+
+	decodeErr := decode.Run()
+	writer.Close()
+	applyErr := apply.Wait()
+
+	if decodeErr != nil {
+		return fmt.Errorf("mysqlbinlog failed: %w, output: %s", decodeErr, decodeOutput.String())
+	}
+	if applyErr != nil {
+		return fmt.Errorf("binlog replay failed: %w, output: %s", applyErr, applyOutput.String())
+	}
+	return nil
+}
+
+func (m *MySQLDatabase) binlogBinary() string {
+	if m.config.Binlog.Binary != "" {
+		return m.config.Binlog.Binary
+	}
+	return "mysqlbinlog"
+}
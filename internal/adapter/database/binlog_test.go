@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMySQLBinlogPosition(t *testing.T) {
+	Convey("Given mysqldump output", t, func() {
+		tempDir, err := os.MkdirTemp("", "binlog_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		db := NewMySQL(&config.DatabaseConfig{Name: "orders", Database: "orders"})
+		writeDump := func(header string) string {
+			path := filepath.Join(tempDir, "dump.sql")
+			content := "-- MySQL dump 10.13\n--\n" + header + "\nINSERT INTO `orders` VALUES (1);\n"
+			So(os.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+			return path
+		}
+
+		Convey("It should read --source-data coordinates", func() {
+			path := writeDump("-- CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000042', SOURCE_LOG_POS=157;")
+			position, err := db.BinlogPosition(path)
+			So(err, ShouldBeNil)
+			So(position.File, ShouldEqual, "binlog.000042")
+			So(position.Position, ShouldEqual, 157)
+		})
+
+		Convey("It should read --master-data coordinates", func() {
+			path := writeDump("-- CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000007', MASTER_LOG_POS=1024;")
+			position, err := db.BinlogPosition(path)
+			So(err, ShouldBeNil)
+			So(position.File, ShouldEqual, "mysql-bin.000007")
+			So(position.Position, ShouldEqual, 1024)
+		})
+
+		Convey("It should stop at table data", func() {
+			path := writeDump("")
+			f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+			f.WriteString("-- CHANGE MASTER TO MASTER_LOG_FILE='late.000001', MASTER_LOG_POS=4;\n")
+			f.Close()
+
+			_, err := db.BinlogPosition(path)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "no binlog coordinates")
+		})
+	})
+}
+
+func TestMySQLStreamBinlogs(t *testing.T) {
+	Convey("Given a fake mysqlbinlog binary", t, func() {
+		tempDir, err := os.MkdirTemp("", "binlog_stream_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		argsFile := filepath.Join(tempDir, "args")
+		binary := filepath.Join(tempDir, "mysqlbinlog")
+		So(os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0755), ShouldBeNil)
+
+		db := NewMySQL(&config.DatabaseConfig{
+			Host: "db.internal", Port: 3306, Username: "repl", Password: "secret",
+			Binlog: config.BinlogConfig{Enabled: true, Binary: binary, ServerID: 4242},
+		})
+
+		Convey("It should stream raw binlogs into the directory from the start file", func() {
+			binlogDir := filepath.Join(tempDir, "binlogs")
+			So(db.StreamBinlogs(context.Background(), binlogDir, "binlog.000042"), ShouldBeNil)
+
+			args, err := os.ReadFile(argsFile)
+			So(err, ShouldBeNil)
+			fields := strings.Fields(string(args))
+			So(fields, ShouldContain, "--read-from-remote-server")
+			So(fields, ShouldContain, "--raw")
+			So(fields, ShouldContain, "--stop-never")
+			So(fields, ShouldContain, "--host=db.internal")
+			So(fields, ShouldContain, "--connection-server-id=4242")
+			So(fields, ShouldContain, "--result-file="+binlogDir+"/")
+			So(fields[len(fields)-1], ShouldEqual, "binlog.000042")
+		})
+	})
+}
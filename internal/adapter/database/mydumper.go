@@ -0,0 +1,144 @@
+package database
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/semmidev/phylax/internal/config"
+)
+
+// MySQLParallelDatabase takes logical MySQL backups with mydumper, which
+// dumps tables concurrently. mydumper holds FLUSH TABLES WITH READ LOCK
+// while its threads open START TRANSACTION WITH CONSISTENT SNAPSHOT, so all
+// tables come from one point in time. The dump directory is archived as a
+// single tar file.
+type MySQLParallelDatabase struct {
+	config *config.DatabaseConfig
+	mysql  *MySQLDatabase
+}
+
+func NewMySQLParallel(cfg *config.DatabaseConfig) *MySQLParallelDatabase {
+	return &MySQLParallelDatabase{config: cfg, mysql: NewMySQL(cfg)}
+}
+
+func (m *MySQLParallelDatabase) Backup(ctx context.Context, outputPath string) error {
+	databases, err := m.mysql.databases(ctx)
+	if err != nil {
+		return err
+	}
+	ignored, _, err := m.mysql.tableFilters(ctx, databases)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "phylax-mydumper")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dumpDir := filepath.Join(tempDir, "dump")
+	args := append(m.mysql.connectionArgs(), m.dumpArgs(databases)...)
+	args = append(args, fmt.Sprintf("--outputdir=%s", dumpDir))
+	if len(ignored) > 0 {
+		omitFile := filepath.Join(tempDir, "omit")
+		if err := os.WriteFile(omitFile, []byte(strings.Join(ignored, "\n")+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write omitted tables: %w", err)
+		}
+		args = append(args, fmt.Sprintf("--omit-from-file=%s", omitFile))
+	}
+	args = append(args, m.config.Dump.ExtraArgs...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "mydumper", args...)
+	cmd.Stdout = &stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mydumper failed: %w, output: %s", err, tail(stderr.String(), 4096))
+	}
+
+	return archiveDir(dumpDir, outputPath)
+}
+
+// dumpArgs returns the mydumper options for the configured dump mode and
+// schemas.
+func (m *MySQLParallelDatabase) dumpArgs(databases []string) []string {
+	var args []string
+	if m.config.Dump.Threads > 0 {
+		args = append(args, fmt.Sprintf("--threads=%d", m.config.Dump.Threads))
+	}
+	switch {
+	case m.config.Dump.DataOnly:
+		args = append(args, "--no-schemas")
+	case m.config.Dump.SchemaOnly:
+		args = append(args, "--no-data", "--routines", "--triggers", "--events")
+	default:
+		args = append(args, "--routines", "--triggers", "--events")
+	}
+
+	if m.mysql.multiDatabase() {
+		// --database takes one schema; a regex over "schema.table"
+		// selects several.
+		quoted := make([]string, len(databases))
+		for i, database := range databases {
+			quoted[i] = regexp.QuoteMeta(database)
+		}
+		args = append(args, fmt.Sprintf("--regex=^(%s)\\.", strings.Join(quoted, "|")))
+	} else {
+		args = append(args, fmt.Sprintf("--database=%s", databases[0]))
+	}
+	return args
+}
+
+func (m *MySQLParallelDatabase) Name() string {
+	return m.config.Name
+}
+
+func (m *MySQLParallelDatabase) Type() string {
+	return "mydumper"
+}
+
+func (m *MySQLParallelDatabase) Ping(ctx context.Context) error {
+	return m.mysql.Ping(ctx)
+}
+
+// archiveDir writes the files under dir to a tar file at outputPath, named
+// relative to dir.
+func archiveDir(dir, outputPath string) error {
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.Close()
+
+	archive := tar.NewWriter(output)
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addToTar(archive, path, filepath.ToSlash(name))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive dump directory: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeMyDumper writes a metadata file and one file per table into
+// --outputdir, and records its arguments in args next to the binary.
+const fakeMyDumper = `#!/bin/sh
+dir=$(dirname "$0")
+: > "$dir/args"
+for arg; do
+	echo "$arg" >> "$dir/args"
+	case "$arg" in
+	--outputdir=*) out="${arg#--outputdir=}" ;;
+	--omit-from-file=*) cp "${arg#--omit-from-file=}" "$dir/omitted" ;;
+	esac
+done
+mkdir -p "$out"
+echo "Started dump" > "$out/metadata"
+echo "INSERT" > "$out/shop.orders.00000.sql"
+echo "CREATE TABLE" > "$out/shop.orders-schema.sql"
+`
+
+func TestMySQLParallelBackup(t *testing.T) {
+	Convey("Given fake mysql and mydumper binaries", t, func() {
+		tempDir, err := os.MkdirTemp("", "mydumper_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		So(os.WriteFile(filepath.Join(tempDir, "mysql"), []byte(fakeMySQL), 0755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tempDir, "mydumper"), []byte(fakeMyDumper), 0755), ShouldBeNil)
+		t.Setenv("PATH", tempDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		cfg := &config.DatabaseConfig{
+			Name: "shop", Database: "shop", Host: "db", Port: 3306, Username: "backup",
+			BackupMethod: "mydumper",
+			Dump:         config.DumpConfig{Threads: 8},
+		}
+		outputPath := filepath.Join(tempDir, "dump.tar")
+		readArgs := func() []string {
+			data, err := os.ReadFile(filepath.Join(tempDir, "args"))
+			So(err, ShouldBeNil)
+			return strings.Split(strings.TrimSpace(string(data)), "\n")
+		}
+
+		Convey("It should archive the dump directory as one tar file", func() {
+			db := NewMySQLParallel(cfg)
+			So(db.Type(), ShouldEqual, "mydumper")
+			So(db.Backup(context.Background(), outputPath), ShouldBeNil)
+
+			args := readArgs()
+			So(args, ShouldContain, "--threads=8")
+			So(args, ShouldContain, "--database=shop")
+			So(args, ShouldContain, "--routines")
+			So(args, ShouldContain, "--host=db")
+
+			file, err := os.Open(outputPath)
+			So(err, ShouldBeNil)
+			defer file.Close()
+
+			var names []string
+			reader := tar.NewReader(file)
+			for {
+				header, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				So(err, ShouldBeNil)
+				names = append(names, header.Name)
+			}
+			sort.Strings(names)
+			So(names, ShouldResemble, []string{"metadata", "shop.orders-schema.sql", "shop.orders.00000.sql"})
+		})
+
+		Convey("It should omit excluded tables", func() {
+			cfg.Dump.ExcludeTables = []string{"sessions", "tmp_*"}
+			So(NewMySQLParallel(cfg).Backup(context.Background(), outputPath), ShouldBeNil)
+
+			omitted, err := os.ReadFile(filepath.Join(tempDir, "omitted"))
+			So(err, ShouldBeNil)
+			So(string(omitted), ShouldEqual, "shop.sessions\nshop.tmp_import\n")
+		})
+
+		Convey("It should select several databases with a regex", func() {
+			cfg.Dump.Databases = []string{"shop", "blog.v2"}
+			cfg.Dump.DataOnly = true
+			So(NewMySQLParallel(cfg).Backup(context.Background(), outputPath), ShouldBeNil)
+
+			args := readArgs()
+			So(args, ShouldContain, `--regex=^(shop|blog\.v2)\.`)
+			So(args, ShouldContain, "--no-schemas")
+			So(args, ShouldNotContain, "--routines")
+		})
+
+		Convey("It should report mydumper failures", func() {
+			So(os.WriteFile(filepath.Join(tempDir, "mydumper"), []byte("#!/bin/sh\necho 'access denied' >&2\nexit 2\n"), 0755), ShouldBeNil)
+			err := NewMySQLParallel(cfg).Backup(context.Background(), outputPath)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "access denied")
+		})
+	})
+}
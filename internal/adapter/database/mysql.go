@@ -1,13 +1,25 @@
 package database
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/semmidev/phylax/internal/config"
 )
 
+// systemSchemas are left out of all_databases dumps; they are generated by
+// the server and cannot be restored.
+var systemSchemas = []string{"information_schema", "performance_schema", "sys"}
+
 type MySQLDatabase struct {
 	config *config.DatabaseConfig
 }
@@ -16,29 +28,26 @@ func NewMySQL(cfg *config.DatabaseConfig) *MySQLDatabase {
 	return &MySQLDatabase{config: cfg}
 }
 
+// Backup dumps the selected databases into one SQL file, or, with
+// dump.file_per_database, into a tar archive holding <schema>.sql for each.
 func (m *MySQLDatabase) Backup(ctx context.Context, outputPath string) error {
-	args := []string{
-		fmt.Sprintf("--host=%s", m.config.Host),
-		fmt.Sprintf("--port=%d", m.config.Port),
-		fmt.Sprintf("--user=%s", m.config.Username),
-		fmt.Sprintf("--password=%s", m.config.Password),
-		"--single-transaction",
-		"--quick",
-		"--lock-tables=false",
-		"--routines",
-		"--triggers",
-		"--events",
-		fmt.Sprintf("--result-file=%s", outputPath),
-		m.config.Database,
+	databases, err := m.databases(ctx)
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.CommandContext(ctx, "mysqldump", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("mysqldump failed: %w, output: %s", err, string(output))
+	if m.config.Dump.FilePerDatabase {
+		return m.backupPerDatabase(ctx, databases, outputPath)
 	}
+	return m.dumpToFile(ctx, databases, outputPath)
+}
 
-	return nil
+// Extension is ".tar" for per-database archives and ".sql" otherwise.
+func (m *MySQLDatabase) Extension() string {
+	if m.config.Dump.FilePerDatabase {
+		return ".tar"
+	}
+	return ".sql"
 }
 
 func (m *MySQLDatabase) Name() string {
@@ -50,18 +59,300 @@ func (m *MySQLDatabase) Type() string {
 }
 
 func (m *MySQLDatabase) Ping(ctx context.Context) error {
-	args := []string{
+	args := append(m.connectionArgs(), "-e", "SELECT 1")
+
+	cmd := exec.CommandContext(ctx, "mysql", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysql ping failed: %w", err)
+	}
+
+	return nil
+}
+
+func (m *MySQLDatabase) connectionArgs() []string {
+	return []string{
 		fmt.Sprintf("--host=%s", m.config.Host),
 		fmt.Sprintf("--port=%d", m.config.Port),
 		fmt.Sprintf("--user=%s", m.config.Username),
 		fmt.Sprintf("--password=%s", m.config.Password),
-		"-e", "SELECT 1",
 	}
+}
+
+// databases returns the schemas to dump: every user schema with
+// all_databases, the dump.databases list, or the configured database.
+func (m *MySQLDatabase) databases(ctx context.Context) ([]string, error) {
+	dump := m.config.Dump
+	switch {
+	case dump.AllDatabases:
+		rows, err := m.query(ctx, "SHOW DATABASES")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list databases: %w", err)
+		}
+		var databases []string
+		for _, row := range rows {
+			if !slices.Contains(systemSchemas, strings.ToLower(row[0])) {
+				databases = append(databases, row[0])
+			}
+		}
+		if len(databases) == 0 {
+			return nil, fmt.Errorf("server has no databases to dump")
+		}
+		return databases, nil
+	case len(dump.Databases) > 0:
+		return dump.Databases, nil
+	default:
+		return []string{m.config.Database}, nil
+	}
+}
+
+// multiDatabase reports whether dumps name their schemas, with CREATE
+// DATABASE and USE statements, rather than loading into a chosen database.
+func (m *MySQLDatabase) multiDatabase() bool {
+	return m.config.Dump.AllDatabases || len(m.config.Dump.Databases) > 0
+}
+
+func (m *MySQLDatabase) backupPerDatabase(ctx context.Context, databases []string, outputPath string) error {
+	tempDir, err := os.MkdirTemp("", "phylax-mysqldump")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
 
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.Close()
+
+	archive := tar.NewWriter(output)
+	for _, database := range databases {
+		// Each schema is dumped in its own transaction, so the files are
+		// not consistent with each other.
+		dumpPath := filepath.Join(tempDir, database+".sql")
+		if err := m.dumpToFile(ctx, []string{database}, dumpPath); err != nil {
+			return fmt.Errorf("dump %s: %w", database, err)
+		}
+		if err := addToTar(archive, dumpPath, database+".sql"); err != nil {
+			return err
+		}
+		if err := os.Remove(dumpPath); err != nil {
+			return fmt.Errorf("failed to remove dump: %w", err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// dumpToFile writes one dump of databases to outputPath. Tables selected
+// by dump.ignore_data_for are left out of the main dump and appended,
+// structure only, by a second mysqldump run.
+func (m *MySQLDatabase) dumpToFile(ctx context.Context, databases []string, outputPath string) error {
+	ignored, structureOnly, err := m.tableFilters(ctx, databases)
+	if err != nil {
+		return err
+	}
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.Close()
+
+	args := append(m.connectionArgs(), m.dumpArgs()...)
+	for _, table := range ignored {
+		args = append(args, "--ignore-table="+table)
+	}
+	args = append(args, m.config.Dump.ExtraArgs...)
+	if m.multiDatabase() {
+		args = append(args, "--databases")
+		args = append(args, databases...)
+	} else {
+		args = append(args, databases[0])
+	}
+
+	if err := runMySQLDump(ctx, args, output); err != nil {
+		return err
+	}
+
+	for _, database := range databases {
+		tables := structureOnly[database]
+		if len(tables) == 0 {
+			continue
+		}
+		if m.multiDatabase() {
+			// A single-database mysqldump does not switch schemas itself.
+			if _, err := fmt.Fprintf(output, "\nUSE %s;\n", quoteIdentifier(database)); err != nil {
+				return fmt.Errorf("failed to write dump: %w", err)
+			}
+		}
+		args := append(m.connectionArgs(), "--no-data", "--single-transaction")
+		args = append(args, m.config.Dump.ExtraArgs...)
+		args = append(args, database)
+		args = append(args, tables...)
+		if err := runMySQLDump(ctx, args, output); err != nil {
+			return err
+		}
+	}
+
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to write dump: %w", err)
+	}
+	return nil
+}
+
+// dumpArgs returns the mysqldump options for the configured dump mode.
+func (m *MySQLDatabase) dumpArgs() []string {
+	args := []string{
+		"--single-transaction",
+		"--quick",
+		"--lock-tables=false",
+	}
+	switch {
+	case m.config.Dump.DataOnly:
+		args = append(args, "--no-create-info", "--skip-triggers")
+	case m.config.Dump.SchemaOnly:
+		args = append(args, "--no-data", "--routines", "--triggers", "--events")
+	default:
+		args = append(args, "--routines", "--triggers", "--events")
+	}
+	if m.config.Binlog.Enabled {
+		// Record the binlog position as a comment so point-in-time
+		// restores know where to start replaying.
+		if m.config.Binlog.LegacyMasterData {
+			args = append(args, "--master-data=2")
+		} else {
+			args = append(args, "--source-data=2")
+		}
+	}
+	return args
+}
+
+// tableFilters resolves the dump's table patterns against the server's
+// tables. It returns the "schema.table" names to pass to --ignore-table and,
+// per schema, the tables to dump structure only.
+func (m *MySQLDatabase) tableFilters(ctx context.Context, databases []string) ([]string, map[string][]string, error) {
+	dump := m.config.Dump
+	if len(dump.Tables) == 0 && len(dump.ExcludeTables) == 0 && len(dump.IgnoreDataFor) == 0 {
+		return nil, nil, nil
+	}
+
+	schemas := make([]string, len(databases))
+	for i, database := range databases {
+		schemas[i] = quoteString(database)
+	}
+	rows, err := m.query(ctx, fmt.Sprintf(
+		"SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA IN (%s) ORDER BY TABLE_SCHEMA, TABLE_NAME",
+		strings.Join(schemas, ", "),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	var ignored []string
+	structureOnly := make(map[string][]string)
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		schema, table := row[0], row[1]
+		included := len(dump.Tables) == 0 || matchTable(dump.Tables, schema, table)
+		switch {
+		case !included || matchTable(dump.ExcludeTables, schema, table):
+			ignored = append(ignored, schema+"."+table)
+		case matchTable(dump.IgnoreDataFor, schema, table):
+			ignored = append(ignored, schema+"."+table)
+			// Schema-only dumps already include its structure, and
+			// data-only dumps want none of it.
+			if !dump.SchemaOnly && !dump.DataOnly {
+				structureOnly[schema] = append(structureOnly[schema], table)
+			}
+		}
+	}
+	return ignored, structureOnly, nil
+}
+
+// query runs sql with the mysql client and returns its tab-separated rows.
+func (m *MySQLDatabase) query(ctx context.Context, sql string) ([][]string, error) {
+	args := append(m.connectionArgs(), "--batch", "--skip-column-names", "-e", sql)
+
+	var stderr bytes.Buffer
 	cmd := exec.CommandContext(ctx, "mysql", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w, output: %s", err, stderr.String())
+	}
+
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			rows = append(rows, strings.Split(line, "\t"))
+		}
+	}
+	return rows, nil
+}
+
+func runMySQLDump(ctx context.Context, args []string, output io.Writer) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "mysqldump", args...)
+	cmd.Stdout = output
+	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("mysql ping failed: %w", err)
+		return fmt.Errorf("mysqldump failed: %w, output: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// matchTable reports whether any pattern matches "table" or "schema.table".
+func matchTable(patterns []string, schema, table string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, schema+"."+table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func addToTar(archive *tar.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to create tar header for %s: %w", name, err)
 	}
+	header.Name = name
 
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", name, err)
+	}
+	if _, err := io.Copy(archive, file); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
 	return nil
 }
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeMySQL is a mysql client that answers the schema queries of a dump.
+const fakeMySQL = `#!/bin/sh
+for arg; do
+	case "$arg" in
+	"SHOW DATABASES") printf 'information_schema\nshop\nblog\nsys\n' ;;
+	*information_schema.TABLES*)
+		printf 'shop\torders\nshop\taudit_log\nshop\tsessions\nshop\ttmp_import\nblog\tposts\n' ;;
+	esac
+done
+`
+
+// fakeMySQLDump writes each run's arguments, one per line, as the dump.
+const fakeMySQLDump = `#!/bin/sh
+echo "-- run"
+for arg; do echo "$arg"; done
+`
+
+func TestMySQLBackup(t *testing.T) {
+	Convey("Given fake mysql and mysqldump binaries", t, func() {
+		tempDir, err := os.MkdirTemp("", "mysql_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		So(os.WriteFile(filepath.Join(tempDir, "mysql"), []byte(fakeMySQL), 0755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tempDir, "mysqldump"), []byte(fakeMySQLDump), 0755), ShouldBeNil)
+		t.Setenv("PATH", tempDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		cfg := &config.DatabaseConfig{Name: "shop", Database: "shop", Host: "db", Port: 3306, Username: "backup"}
+		outputPath := filepath.Join(tempDir, "dump.sql")
+		backup := func() []string {
+			So(NewMySQL(cfg).Backup(context.Background(), outputPath), ShouldBeNil)
+			data, err := os.ReadFile(outputPath)
+			So(err, ShouldBeNil)
+			return strings.Split(strings.TrimSpace(string(data)), "-- run\n")[1:]
+		}
+		lines := func(run string) []string {
+			return strings.Split(strings.TrimSpace(run), "\n")
+		}
+
+		Convey("It should dump the configured database with routines, triggers and events", func() {
+			runs := backup()
+			So(runs, ShouldHaveLength, 1)
+			args := lines(runs[0])
+			So(args, ShouldContain, "--single-transaction")
+			So(args, ShouldContain, "--routines")
+			So(args, ShouldContain, "--events")
+			So(args[len(args)-1], ShouldEqual, "shop")
+			So(NewMySQL(cfg).Extension(), ShouldEqual, ".sql")
+		})
+
+		Convey("It should ignore tables outside tables and inside exclude_tables", func() {
+			cfg.Dump.Tables = []string{"shop.*"}
+			cfg.Dump.ExcludeTables = []string{"tmp_*"}
+
+			args := lines(backup()[0])
+			So(args, ShouldContain, "--ignore-table=shop.tmp_import")
+			So(args, ShouldNotContain, "--ignore-table=shop.orders")
+		})
+
+		Convey("It should append structure only for ignore_data_for tables", func() {
+			cfg.Dump.IgnoreDataFor = []string{"audit_log", "sessions"}
+			cfg.Dump.ExtraArgs = []string{"--hex-blob"}
+
+			runs := backup()
+			So(runs, ShouldHaveLength, 2)
+			main := lines(runs[0])
+			So(main, ShouldContain, "--ignore-table=shop.audit_log")
+			So(main, ShouldContain, "--ignore-table=shop.sessions")
+			So(main, ShouldContain, "--hex-blob")
+
+			structure := lines(runs[1])
+			So(structure, ShouldContain, "--no-data")
+			So(structure, ShouldContain, "--hex-blob")
+			So(structure[len(structure)-3:], ShouldResemble, []string{"shop", "audit_log", "sessions"})
+		})
+
+		Convey("It should not append structure in data-only mode", func() {
+			cfg.Dump.DataOnly = true
+			cfg.Dump.IgnoreDataFor = []string{"sessions"}
+
+			runs := backup()
+			So(runs, ShouldHaveLength, 1)
+			args := lines(runs[0])
+			So(args, ShouldContain, "--no-create-info")
+			So(args, ShouldContain, "--ignore-table=shop.sessions")
+			So(args, ShouldNotContain, "--routines")
+		})
+
+		Convey("It should dump schema only", func() {
+			cfg.Dump.SchemaOnly = true
+			So(lines(backup()[0]), ShouldContain, "--no-data")
+		})
+
+		Convey("It should dump every user database into one file", func() {
+			cfg.Dump.AllDatabases = true
+			cfg.Dump.IgnoreDataFor = []string{"blog.posts"}
+
+			runs := backup()
+			So(runs, ShouldHaveLength, 2)
+			So(runs[0], ShouldContainSubstring, "--databases\nshop\nblog\n")
+			So(runs[0], ShouldEndWith, "USE `blog`;\n")
+		})
+
+		Convey("It should archive one file per database", func() {
+			cfg.Dump.Databases = []string{"shop", "blog"}
+			cfg.Dump.FilePerDatabase = true
+			So(NewMySQL(cfg).Extension(), ShouldEqual, ".tar")
+			So(NewMySQL(cfg).Backup(context.Background(), outputPath), ShouldBeNil)
+
+			file, err := os.Open(outputPath)
+			So(err, ShouldBeNil)
+			defer file.Close()
+
+			dumps := map[string]string{}
+			reader := tar.NewReader(file)
+			for {
+				header, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				So(err, ShouldBeNil)
+				data, err := io.ReadAll(reader)
+				So(err, ShouldBeNil)
+				dumps[header.Name] = string(data)
+			}
+			So(dumps, ShouldHaveLength, 2)
+			So(dumps["shop.sql"], ShouldEndWith, "--databases\nshop\n")
+			So(dumps["blog.sql"], ShouldEndWith, "--databases\nblog\n")
+		})
+	})
+}
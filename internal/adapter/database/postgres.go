@@ -0,0 +1,91 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/semmidev/phylax/internal/config"
+)
+
+// PostgresBaseBackup takes physical base backups with pg_basebackup. The
+// backups contain no WAL; the server must archive WAL with
+// `phylax wal-push` so they can be restored.
+type PostgresBaseBackup struct {
+	config *config.DatabaseConfig
+}
+
+func NewPostgresBaseBackup(cfg *config.DatabaseConfig) *PostgresBaseBackup {
+	return &PostgresBaseBackup{config: cfg}
+}
+
+// Backup writes a tar-format base backup of the whole cluster to outputPath.
+func (p *PostgresBaseBackup) Backup(ctx context.Context, outputPath string) error {
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.Close()
+
+	args := append(p.connectionArgs(),
+		"--pgdata=-",
+		"--format=tar",
+		"--wal-method=none",
+		"--checkpoint=fast",
+		"--label=phylax",
+	)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pg_basebackup", args...)
+	cmd.Env = p.env()
+	cmd.Stdout = output
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_basebackup failed: %w, output: %s", err, stderr.String())
+	}
+
+	return output.Close()
+}
+
+func (p *PostgresBaseBackup) Name() string {
+	return p.config.Name
+}
+
+func (p *PostgresBaseBackup) Type() string {
+	return "pgbasebackup"
+}
+
+func (p *PostgresBaseBackup) Ping(ctx context.Context) error {
+	args := append(p.connectionArgs(), "--no-password", "--command=SELECT 1")
+	if p.config.Database != "" {
+		args = append(args, "--dbname="+p.config.Database)
+	}
+
+	cmd := exec.CommandContext(ctx, "psql", args...)
+	cmd.Env = p.env()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("postgresql ping failed: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBaseBackup) connectionArgs() []string {
+	return []string{
+		fmt.Sprintf("--host=%s", p.config.Host),
+		fmt.Sprintf("--port=%d", p.config.Port),
+		fmt.Sprintf("--username=%s", p.config.Username),
+	}
+}
+
+// env passes the password and SSL mode through the environment so they do
+// not show up in the process list.
+func (p *PostgresBaseBackup) env() []string {
+	env := append(os.Environ(), "PGPASSWORD="+p.config.Password)
+	if p.config.SSLMode != "" {
+		env = append(env, "PGSSLMODE="+p.config.SSLMode)
+	}
+	return env
+}
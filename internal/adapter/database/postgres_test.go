@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPostgresBaseBackup(t *testing.T) {
+	Convey("Given a fake pg_basebackup on the PATH", t, func() {
+		tempDir, err := os.MkdirTemp("", "pgbasebackup_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		argsFile := filepath.Join(tempDir, "args")
+		script := "#!/bin/sh\necho \"$@ PGPASSWORD=$PGPASSWORD PGSSLMODE=$PGSSLMODE\" > " + argsFile + "\nprintf 'base tar'\n"
+		So(os.WriteFile(filepath.Join(tempDir, "pg_basebackup"), []byte(script), 0755), ShouldBeNil)
+		t.Setenv("PATH", tempDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		db := NewPostgresBaseBackup(&config.DatabaseConfig{
+			Name: "analytics", Host: "pg.internal", Port: 5432,
+			Username: "replicator", Password: "secret", SSLMode: "require",
+		})
+
+		Convey("Backup should stream a tar base backup without WAL to the output file", func() {
+			output := filepath.Join(tempDir, "base.tar")
+			So(db.Backup(context.Background(), output), ShouldBeNil)
+
+			content, err := os.ReadFile(output)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "base tar")
+
+			args, err := os.ReadFile(argsFile)
+			So(err, ShouldBeNil)
+			fields := strings.Fields(string(args))
+			So(fields, ShouldContain, "--pgdata=-")
+			So(fields, ShouldContain, "--format=tar")
+			So(fields, ShouldContain, "--wal-method=none")
+			So(fields, ShouldContain, "--host=pg.internal")
+			So(fields, ShouldContain, "PGPASSWORD=secret")
+			So(fields, ShouldContain, "PGSSLMODE=require")
+			So(string(args), ShouldNotContainSubstring, "--password")
+		})
+
+		Convey("Type should name base backups apart from logical dumps", func() {
+			So(db.Type(), ShouldEqual, "pgbasebackup")
+		})
+	})
+}
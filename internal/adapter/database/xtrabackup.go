@@ -0,0 +1,233 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+const chainStateFile = "chain.json"
+
+// MySQLPhysicalDatabase takes physical backups with xtrabackup (or
+// mariabackup) streamed as xbstream. After a full backup it takes
+// config.Physical.FullEvery incremental backups, each from the LSN where the
+// previous one ended, before starting a new chain.
+type MySQLPhysicalDatabase struct {
+	config   *config.DatabaseConfig
+	mysql    *MySQLDatabase
+	stateDir string
+
+	chain   physicalChain
+	pending *physicalBackup
+}
+
+// physicalChain is the current backup chain, persisted in stateDir.
+type physicalChain struct {
+	Backups []physicalBackup `json:"backups"`
+}
+
+type physicalBackup struct {
+	File    string    `json:"file"`
+	Kind    string    `json:"kind"`
+	FromLSN uint64    `json:"from_lsn"`
+	ToLSN   uint64    `json:"to_lsn"`
+	Time    time.Time `json:"time"`
+}
+
+// NewMySQLPhysical creates a MySQLPhysicalDatabase that keeps its chain
+// state in stateDir.
+func NewMySQLPhysical(cfg *config.DatabaseConfig, stateDir string) (*MySQLPhysicalDatabase, error) {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create chain state directory: %w", err)
+	}
+
+	m := &MySQLPhysicalDatabase{
+		config:   cfg,
+		mysql:    NewMySQL(cfg),
+		stateDir: stateDir,
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, chainStateFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read chain state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &m.chain); err != nil {
+			return nil, fmt.Errorf("failed to parse chain state: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// NextBackupKind returns incremental while the current chain has fewer than
+// FullEvery incrementals, and full otherwise.
+func (m *MySQLPhysicalDatabase) NextBackupKind() string {
+	if len(m.chain.Backups) == 0 || len(m.chain.Backups) > m.config.Physical.FullEvery {
+		return domain.BackupKindFull
+	}
+	return domain.BackupKindIncremental
+}
+
+func (m *MySQLPhysicalDatabase) Backup(ctx context.Context, outputPath string) error {
+	kind := m.NextBackupKind()
+
+	lsnDir, err := os.MkdirTemp("", "phylax-xtrabackup")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(lsnDir)
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.Close()
+
+	args := []string{"--backup", "--stream=xbstream"}
+	args = append(args, m.mysql.connectionArgs()...)
+	args = append(args, fmt.Sprintf("--extra-lsndir=%s", lsnDir))
+	var fromLSN uint64
+	if kind == domain.BackupKindIncremental {
+		fromLSN = m.chain.Backups[len(m.chain.Backups)-1].ToLSN
+		args = append(args, fmt.Sprintf("--incremental-lsn=%d", fromLSN))
+	}
+	args = append(args, m.config.Physical.ExtraArgs...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.binary(), args...)
+	cmd.Stdout = output
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w, output: %s", m.binary(), err, tail(stderr.String(), 4096))
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	toLSN, err := readToLSN(filepath.Join(lsnDir, "xtrabackup_checkpoints"))
+	if err != nil {
+		return err
+	}
+
+	m.pending = &physicalBackup{
+		File:    filepath.Base(outputPath),
+		Kind:    kind,
+		FromLSN: fromLSN,
+		ToLSN:   toLSN,
+		Time:    time.Now().UTC(),
+	}
+	return nil
+}
+
+// CommitBackup appends the last backup to the chain, or starts a new chain
+// with it if it was a full backup.
+func (m *MySQLPhysicalDatabase) CommitBackup() error {
+	if m.pending == nil {
+		return errors.New("no backup to commit")
+	}
+
+	chain := m.chain
+	if m.pending.Kind == domain.BackupKindFull {
+		chain = physicalChain{}
+	}
+	chain.Backups = append(slices.Clone(chain.Backups), *m.pending)
+
+	if err := m.saveChain(chain); err != nil {
+		return err
+	}
+	m.chain, m.pending = chain, nil
+	return nil
+}
+
+// AbandonBackup discards the last backup and clears the chain, so the next
+// backup is a full backup.
+func (m *MySQLPhysicalDatabase) AbandonBackup() error {
+	if err := m.saveChain(physicalChain{}); err != nil {
+		return err
+	}
+	m.chain, m.pending = physicalChain{}, nil
+	return nil
+}
+
+func (m *MySQLPhysicalDatabase) saveChain(chain physicalChain) error {
+	data, err := json.MarshalIndent(chain, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.stateDir, chainStateFile)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write chain state: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write chain state: %w", err)
+	}
+	return nil
+}
+
+func (m *MySQLPhysicalDatabase) Name() string {
+	return m.config.Name
+}
+
+func (m *MySQLPhysicalDatabase) Type() string {
+	return "mysql-physical"
+}
+
+func (m *MySQLPhysicalDatabase) Ping(ctx context.Context) error {
+	return m.mysql.Ping(ctx)
+}
+
+func (m *MySQLPhysicalDatabase) binary() string {
+	if m.config.Physical.Binary != "" {
+		return m.config.Physical.Binary
+	}
+	return "xtrabackup"
+}
+
+// readToLSN returns to_lsn from an xtrabackup_checkpoints file.
+func readToLSN(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read backup checkpoints: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || strings.TrimSpace(key) != "to_lsn" {
+			continue
+		}
+		lsn, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid to_lsn %q: %w", value, err)
+		}
+		return lsn, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read backup checkpoints: %w", err)
+	}
+	return 0, errors.New("backup checkpoints have no to_lsn")
+}
+
+// tail returns the last n bytes of s; xtrabackup logs every file it copies.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
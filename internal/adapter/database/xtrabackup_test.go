@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMySQLPhysicalDatabase(t *testing.T) {
+	Convey("Given a fake xtrabackup binary", t, func() {
+		tempDir, err := os.MkdirTemp("", "xtrabackup_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		// The fake records its arguments and reports a growing to_lsn.
+		argsFile := filepath.Join(tempDir, "args")
+		binary := filepath.Join(tempDir, "xtrabackup")
+		script := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+for arg in "$@"; do
+  case "$arg" in --extra-lsndir=*) dir="${arg#--extra-lsndir=}" ;; esac
+done
+count=$(cat ` + tempDir + `/count 2>/dev/null || echo 0)
+count=$((count + 1))
+echo $count > ` + tempDir + `/count
+printf 'backup_type = full-backuped\nfrom_lsn = 0\nto_lsn = %d000\n' $count > "$dir/xtrabackup_checkpoints"
+printf 'xbstream'
+`
+		So(os.WriteFile(binary, []byte(script), 0755), ShouldBeNil)
+
+		cfg := &config.DatabaseConfig{
+			Name: "orders", Host: "db.internal", Port: 3306, Username: "backup",
+			Physical: config.PhysicalConfig{Binary: binary, FullEvery: 2},
+		}
+		stateDir := filepath.Join(tempDir, "state")
+		db, err := NewMySQLPhysical(cfg, stateDir)
+		So(err, ShouldBeNil)
+
+		backup := func() string {
+			output := filepath.Join(tempDir, "backup.xbstream")
+			So(db.Backup(context.Background(), output), ShouldBeNil)
+			content, _ := os.ReadFile(output)
+			So(string(content), ShouldEqual, "xbstream")
+			So(db.CommitBackup(), ShouldBeNil)
+			args, _ := os.ReadFile(argsFile)
+			return string(args)
+		}
+
+		Convey("It should take a full backup, then incrementals from the previous LSN, then a new full", func() {
+			So(db.NextBackupKind(), ShouldEqual, domain.BackupKindFull)
+			args := backup()
+			So(args, ShouldContainSubstring, "--backup --stream=xbstream")
+			So(args, ShouldNotContainSubstring, "--incremental-lsn")
+
+			So(db.NextBackupKind(), ShouldEqual, domain.BackupKindIncremental)
+			So(backup(), ShouldContainSubstring, "--incremental-lsn=1000")
+			So(db.NextBackupKind(), ShouldEqual, domain.BackupKindIncremental)
+			So(backup(), ShouldContainSubstring, "--incremental-lsn=2000")
+
+			So(db.NextBackupKind(), ShouldEqual, domain.BackupKindFull)
+			So(backup(), ShouldNotContainSubstring, "--incremental-lsn")
+
+			Convey("And the chain should survive a restart", func() {
+				reopened, err := NewMySQLPhysical(cfg, stateDir)
+				So(err, ShouldBeNil)
+				So(reopened.NextBackupKind(), ShouldEqual, domain.BackupKindIncremental)
+				So(reopened.chain.Backups, ShouldHaveLength, 1)
+				So(reopened.chain.Backups[0].ToLSN, ShouldEqual, 4000)
+			})
+		})
+
+		Convey("An uncommitted backup should not extend the chain", func() {
+			backup()
+			So(db.Backup(context.Background(), filepath.Join(tempDir, "lost.xbstream")), ShouldBeNil)
+
+			args := backup()
+			So(strings.Contains(args, "--incremental-lsn=1000"), ShouldBeTrue)
+		})
+
+		Convey("An abandoned backup should start a new chain, also after a restart", func() {
+			backup()
+			So(db.Backup(context.Background(), filepath.Join(tempDir, "partial.xbstream")), ShouldBeNil)
+			So(db.AbandonBackup(), ShouldBeNil)
+			So(db.NextBackupKind(), ShouldEqual, domain.BackupKindFull)
+
+			reopened, err := NewMySQLPhysical(cfg, stateDir)
+			So(err, ShouldBeNil)
+			So(reopened.NextBackupKind(), ShouldEqual, domain.BackupKindFull)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// HeartbeatNotifier pings a dead-man's-switch monitor for a single database
+// job, so an external service can alert when runs stop arriving or fail.
+//
+// The healthchecks format hits <url>/start, <url> and <url>/fail with the
+// duration and error text as body. The uptime-kuma format uses the push API
+// (<url>?status=up|down&msg=...&ping=<ms>), which has no start signal.
+type HeartbeatNotifier struct {
+	client *http.Client
+	url    string
+	format string
+}
+
+// NewHeartbeat creates a new HeartbeatNotifier.
+func NewHeartbeat(cfg *config.HeartbeatConfig) (*HeartbeatNotifier, error) {
+	if cfg == nil {
+		return nil, errors.New("configuration cannot be nil")
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("heartbeat url is required")
+	}
+
+	format := cfg.Format
+	switch format {
+	case "":
+		format = "healthchecks"
+	case "healthchecks", "uptime-kuma":
+	default:
+		return nil, fmt.Errorf("unsupported heartbeat format: %s", cfg.Format)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &HeartbeatNotifier{
+		client: &http.Client{Timeout: timeout},
+		url:    strings.TrimRight(cfg.URL, "/"),
+		format: format,
+	}, nil
+}
+
+// Notify pings the monitor for backup events. Partially uploaded backups are
+// reported as failures because not every copy was written.
+func (h *HeartbeatNotifier) Notify(ctx context.Context, event domain.Event) error {
+	if event.Backup == nil {
+		return nil
+	}
+
+	var req *http.Request
+	var err error
+
+	switch h.format {
+	case "uptime-kuma":
+		req, err = h.uptimeKumaRequest(ctx, event)
+	default:
+		req, err = h.healthchecksRequest(ctx, event)
+	}
+	if err != nil || req == nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("heartbeat ping failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat ping failed: unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *HeartbeatNotifier) healthchecksRequest(ctx context.Context, event domain.Event) (*http.Request, error) {
+	target := h.url
+	switch event.Type {
+	case domain.EventBackupStarted:
+		target += "/start"
+	case domain.EventBackupFailed, domain.EventBackupPartial:
+		target += "/fail"
+	case domain.EventBackupSucceeded:
+	default:
+		return nil, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(heartbeatMessage(event)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create heartbeat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	return req, nil
+}
+
+func (h *HeartbeatNotifier) uptimeKumaRequest(ctx context.Context, event domain.Event) (*http.Request, error) {
+	status := "up"
+	switch event.Type {
+	case domain.EventBackupSucceeded:
+	case domain.EventBackupFailed, domain.EventBackupPartial:
+		status = "down"
+	default:
+		return nil, nil
+	}
+
+	u, err := url.Parse(h.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid heartbeat url: %w", err)
+	}
+	q := u.Query()
+	q.Set("status", status)
+	q.Set("msg", truncate(heartbeatMessage(event), 250))
+	q.Set("ping", strconv.FormatInt(event.Backup.Duration.Milliseconds(), 10))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create heartbeat request: %w", err)
+	}
+	return req, nil
+}
+
+func heartbeatMessage(event domain.Event) string {
+	r := event.Backup
+	if event.Type == domain.EventBackupStarted {
+		return fmt.Sprintf("%s: backup started", r.Database)
+	}
+
+	msg := fmt.Sprintf("%s: %s in %s", r.Database, event.Type, r.Duration.Round(time.Second))
+	if r.Error != "" {
+		msg += ": " + redact(r.Error)
+	}
+	for _, u := range r.Uploads {
+		if u.Error != "" {
+			msg += fmt.Sprintf("; upload to %s failed: %s", u.Target, redact(u.Error))
+		}
+	}
+	return msg
+}
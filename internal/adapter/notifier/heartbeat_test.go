@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHeartbeatNotifier(t *testing.T) {
+	Convey("Given a HeartbeatNotifier", t, func() {
+		var requests []*http.Request
+		var bodies []string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			requests = append(requests, r)
+			bodies = append(bodies, string(b))
+		}))
+		defer server.Close()
+
+		result := &domain.BackupResult{Database: "prod-mysql", Duration: 2500 * time.Millisecond}
+		ctx := context.Background()
+
+		Convey("NewHeartbeat", func() {
+			Convey("When format is unknown", func() {
+				_, err := NewHeartbeat(&config.HeartbeatConfig{URL: server.URL, Format: "nagios"})
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "unsupported heartbeat format")
+				})
+			})
+		})
+
+		Convey("With the healthchecks format", func() {
+			h, err := NewHeartbeat(&config.HeartbeatConfig{URL: server.URL + "/ping/uuid/"})
+			So(err, ShouldBeNil)
+
+			Convey("When a job starts, succeeds and fails", func() {
+				So(h.Notify(ctx, domain.Event{Type: domain.EventBackupStarted, Backup: result}), ShouldBeNil)
+				So(h.Notify(ctx, domain.Event{Type: domain.EventBackupSucceeded, Backup: result}), ShouldBeNil)
+
+				failed := *result
+				failed.Error = "mysqldump failed"
+				So(h.Notify(ctx, domain.Event{Type: domain.EventBackupFailed, Backup: &failed}), ShouldBeNil)
+
+				Convey("It should ping the start, success and fail endpoints", func() {
+					So(len(requests), ShouldEqual, 3)
+					So(requests[0].URL.Path, ShouldEqual, "/ping/uuid/start")
+					So(requests[1].URL.Path, ShouldEqual, "/ping/uuid")
+					So(bodies[1], ShouldContainSubstring, "backup_succeeded in 3s")
+					So(requests[2].URL.Path, ShouldEqual, "/ping/uuid/fail")
+					So(bodies[2], ShouldContainSubstring, "mysqldump failed")
+				})
+			})
+
+			Convey("When a cleanup event arrives", func() {
+				err := h.Notify(ctx, domain.Event{Type: domain.EventCleanupCompleted})
+
+				Convey("It should not ping", func() {
+					So(err, ShouldBeNil)
+					So(len(requests), ShouldEqual, 0)
+				})
+			})
+		})
+
+		Convey("With the uptime-kuma format", func() {
+			h, err := NewHeartbeat(&config.HeartbeatConfig{
+				URL:    server.URL + "/api/push/token",
+				Format: "uptime-kuma",
+			})
+			So(err, ShouldBeNil)
+
+			Convey("When a job starts and is partially uploaded", func() {
+				So(h.Notify(ctx, domain.Event{Type: domain.EventBackupStarted, Backup: result}), ShouldBeNil)
+
+				partial := *result
+				partial.Uploads = []domain.UploadResult{{Target: "s3", Error: "timeout"}}
+				So(h.Notify(ctx, domain.Event{Type: domain.EventBackupPartial, Backup: &partial}), ShouldBeNil)
+
+				Convey("It should only push the down status with duration", func() {
+					So(len(requests), ShouldEqual, 1)
+					q := requests[0].URL.Query()
+					So(q.Get("status"), ShouldEqual, "down")
+					So(q.Get("ping"), ShouldEqual, "2500")
+					So(q.Get("msg"), ShouldContainSubstring, "upload to s3 failed: timeout")
+				})
+			})
+		})
+	})
+}
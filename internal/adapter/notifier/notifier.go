@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// templateFuncs are available to every payload template.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"mb": func(size int64) string {
+		return fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
+	},
+	"round": func(d time.Duration) time.Duration {
+		return d.Round(time.Second)
+	},
+	"time": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}
+
+// subscribed reports whether an event type is selected by the configured
+// event list. An empty list subscribes to everything.
+func subscribed(events []string, eventType domain.EventType) bool {
+	return len(events) == 0 || slices.Contains(events, string(eventType))
+}
+
+// secretPatterns match credentials that commonly leak into error output from
+// dump tools, connection strings and HTTP clients.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(--?(?:password|pass|secret|token)[= ])\S+`),
+	regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret|token|access_key|secret_key)\s*[=:]\s*)[^\s&;,]+`),
+	regexp.MustCompile(`(://[^/\s:@]+:)[^@/\s]+(@)`),
+	regexp.MustCompile(`(/bot)\d+:[\w-]+`),
+}
+
+// redact masks credentials in s so errors can be forwarded to chat channels.
+func redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.ReplaceAllString(s, "${1}***${2}")
+	}
+	return s
+}
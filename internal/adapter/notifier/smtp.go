@@ -0,0 +1,379 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+const defaultSubjectTemplate = `[phylax] {{.Type}}{{with .Backup}} {{.Database}}{{end}}`
+
+const defaultTextTemplate = `Event: {{.Type}}
+Time: {{time .Time}}
+{{with .Backup}}
+Database: {{.Database}} ({{.Type}})
+{{if .Filename}}File: {{.Filename}}
+Size: {{mb .Size}}
+{{end}}{{with .Compression}}Compression: {{.Algorithm}}, {{printf "%.2f" .Ratio}}x, {{printf "%.1f" .ThroughputMBps}} MB/s
+{{end}}Duration: {{round .Duration}}
+{{range .Uploads}}Upload to {{.Target}}: {{if .Error}}FAILED - {{.Error}}{{else}}ok{{end}}
+{{end}}{{if .Error}}Error: {{.Error}}
+{{end}}{{end}}{{range .Cleanup}}Cleanup {{.Target}}: {{.Deleted}} deleted, {{.Failed}} failed{{if .Error}} ({{.Error}}){{end}}
+{{end}}`
+
+const defaultHTMLTemplate = `<h3>{{.Type}}</h3>
+<p>{{time .Time}}</p>
+{{with .Backup}}<table>
+<tr><td>Database</td><td>{{.Database}} ({{.Type}})</td></tr>
+{{if .Filename}}<tr><td>File</td><td>{{.Filename}}</td></tr>
+<tr><td>Size</td><td>{{mb .Size}}</td></tr>
+{{end}}{{with .Compression}}<tr><td>Compression</td><td>{{.Algorithm}}, {{printf "%.2f" .Ratio}}x, {{printf "%.1f" .ThroughputMBps}} MB/s</td></tr>
+{{end}}<tr><td>Duration</td><td>{{round .Duration}}</td></tr>
+{{range .Uploads}}<tr><td>Upload to {{.Target}}</td><td>{{if .Error}}FAILED - {{.Error}}{{else}}ok{{end}}</td></tr>
+{{end}}{{if .Error}}<tr><td>Error</td><td>{{.Error}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Cleanup}}<ul>{{range .Cleanup}}<li>{{.Target}}: {{.Deleted}} deleted, {{.Failed}} failed{{if .Error}} ({{.Error}}){{end}}</li>{{end}}</ul>{{end}}`
+
+const summarySubjectTemplate = `[phylax] Summary: {{.Succeeded}} succeeded, {{.Partial}} partial, {{.Failed}} failed`
+
+const summaryTextTemplate = `Backup summary from {{time .From}} to {{time .To}}
+
+Succeeded: {{.Succeeded}}
+Partial:   {{.Partial}}
+Failed:    {{.Failed}}
+
+{{range .Events}}{{time .Time}}  {{.Type}}{{with .Backup}}  {{.Database}}  {{round .Duration}}{{if .Error}}  {{.Error}}{{end}}{{end}}{{range .Cleanup}}  {{.Target}}: {{.Deleted}} deleted{{end}}
+{{end}}`
+
+const summaryHTMLTemplate = `<h3>Backup summary</h3>
+<p>{{time .From}} &ndash; {{time .To}}</p>
+<p>Succeeded: {{.Succeeded}}, partial: {{.Partial}}, failed: {{.Failed}}</p>
+<table>
+{{range .Events}}<tr><td>{{time .Time}}</td><td>{{.Type}}</td>{{with .Backup}}<td>{{.Database}}</td><td>{{round .Duration}}</td><td>{{.Error}}</td>{{end}}{{if .Cleanup}}<td colspan="3">{{range .Cleanup}}{{.Target}}: {{.Deleted}} deleted; {{end}}</td>{{end}}</tr>
+{{end}}</table>`
+
+// Summary is the data passed to the digest templates.
+type Summary struct {
+	From      time.Time
+	To        time.Time
+	Events    []domain.Event
+	Succeeded int
+	Partial   int
+	Failed    int
+}
+
+type mailTemplates struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// SMTPNotifier sends events by email. When a summary schedule is configured,
+// completed runs are collected and mailed as a periodic digest; only events
+// listed explicitly in the subscription are then sent immediately.
+type SMTPNotifier struct {
+	addr            string
+	host            string
+	tlsMode         string
+	auth            smtp.Auth
+	from            string
+	to              []string
+	events          []string
+	summarySchedule string
+	eventMail       mailTemplates
+	summaryMail     mailTemplates
+
+	mu      sync.Mutex
+	pending []domain.Event
+	since   time.Time
+}
+
+// NewSMTP creates a new SMTPNotifier. TLS mode is "starttls" (default),
+// "implicit" or "none".
+func NewSMTP(cfg *config.NotificationConfig) (*SMTPNotifier, error) {
+	if cfg == nil {
+		return nil, errors.New("configuration cannot be nil")
+	}
+	if cfg.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("smtp from and to addresses are required")
+	}
+
+	tlsMode := strings.ToLower(cfg.TLS)
+	switch tlsMode {
+	case "":
+		tlsMode = "starttls"
+	case "starttls", "implicit", "none":
+	default:
+		return nil, fmt.Errorf("unsupported smtp tls mode: %s", cfg.TLS)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if tlsMode == "implicit" {
+			port = 465
+		}
+	}
+
+	eventMail, err := parseMailTemplates(
+		orDefault(cfg.Subject, defaultSubjectTemplate),
+		orDefault(cfg.Template, defaultTextTemplate),
+		orDefault(cfg.HTMLTemplate, defaultHTMLTemplate),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	summaryMail, err := parseMailTemplates(summarySubjectTemplate, summaryTextTemplate, summaryHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPNotifier{
+		addr:            net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		host:            cfg.Host,
+		tlsMode:         tlsMode,
+		auth:            auth,
+		from:            cfg.From,
+		to:              cfg.To,
+		events:          cfg.Events,
+		summarySchedule: cfg.SummarySchedule,
+		eventMail:       eventMail,
+		summaryMail:     summaryMail,
+		since:           time.Now(),
+	}, nil
+}
+
+// Notify mails the event, or queues it for the next summary.
+func (s *SMTPNotifier) Notify(ctx context.Context, event domain.Event) error {
+	if s.summarySchedule == "" {
+		if !subscribed(s.events, event.Type) {
+			return nil
+		}
+		return s.sendTemplated(ctx, s.eventMail, event)
+	}
+
+	if event.Type != domain.EventBackupStarted {
+		s.mu.Lock()
+		s.pending = append(s.pending, event)
+		s.mu.Unlock()
+	}
+
+	if len(s.events) > 0 && subscribed(s.events, event.Type) {
+		return s.sendTemplated(ctx, s.eventMail, event)
+	}
+	return nil
+}
+
+// SummarySchedule returns the cron spec for the digest, or "" if disabled.
+func (s *SMTPNotifier) SummarySchedule() string {
+	return s.summarySchedule
+}
+
+// SendSummary mails every event collected since the previous summary. Events
+// are put back if delivery fails so the next digest still includes them.
+func (s *SMTPNotifier) SendSummary(ctx context.Context) error {
+	s.mu.Lock()
+	summary := Summary{From: s.since, To: time.Now(), Events: s.pending}
+	s.pending, s.since = nil, summary.To
+	s.mu.Unlock()
+
+	for _, e := range summary.Events {
+		switch e.Type {
+		case domain.EventBackupSucceeded:
+			summary.Succeeded++
+		case domain.EventBackupPartial:
+			summary.Partial++
+		case domain.EventBackupFailed:
+			summary.Failed++
+		}
+	}
+
+	if err := s.sendTemplated(ctx, s.summaryMail, summary); err != nil {
+		s.mu.Lock()
+		s.pending = append(summary.Events, s.pending...)
+		s.since = summary.From
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *SMTPNotifier) sendTemplated(ctx context.Context, tmpl mailTemplates, data any) error {
+	var subject, text, html bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return fmt.Errorf("failed to render text body: %w", err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return fmt.Errorf("failed to render html body: %w", err)
+	}
+
+	msg, err := s.buildMessage(strings.TrimSpace(subject.String()), text.Bytes(), html.Bytes())
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, msg)
+}
+
+// buildMessage assembles a multipart/alternative message with plain-text and
+// HTML parts.
+func (s *SMTPNotifier) buildMessage(subject string, text, html []byte) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mail part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, fmt.Errorf("failed to encode mail part: %w", err)
+		}
+		qp.Close()
+	}
+	mw.Close()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", messageID(), s.host)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func (s *SMTPNotifier) send(ctx context.Context, msg []byte) error {
+	dialer := &net.Dialer{Timeout: defaultTimeout}
+	tlsConfig := &tls.Config{ServerName: s.host}
+
+	var conn net.Conn
+	var err error
+	if s.tlsMode == "implicit" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create smtp client: %w", err)
+	}
+	defer client.Close()
+
+	if s.tlsMode == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, rcpt := range s.to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func parseMailTemplates(subject, text, html string) (mailTemplates, error) {
+	var t mailTemplates
+	var err error
+
+	if t.subject, err = template.New("subject").Funcs(templateFuncs).Parse(subject); err != nil {
+		return t, fmt.Errorf("failed to parse subject template: %w", err)
+	}
+	if t.text, err = template.New("text").Funcs(templateFuncs).Parse(text); err != nil {
+		return t, fmt.Errorf("failed to parse text template: %w", err)
+	}
+	if t.html, err = htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(html); err != nil {
+		return t, fmt.Errorf("failed to parse html template: %w", err)
+	}
+	return t, nil
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func messageID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,233 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// smtpStub is a minimal SMTP server that records delivered messages.
+type smtpStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	rcpts    []string
+	messages []string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &smtpStub{listener: l}
+	go stub.serve()
+	return stub
+}
+
+func (s *smtpStub) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP stub")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			s.mu.Unlock()
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpStub) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	Convey("Given an SMTPNotifier against a local stub", t, func() {
+		stub := newSMTPStub(t)
+		defer stub.listener.Close()
+
+		cfg := &config.NotificationConfig{
+			Type:     "smtp",
+			Host:     "127.0.0.1",
+			Port:     stub.port(),
+			TLS:      "none",
+			Username: "phylax",
+			Password: "secret",
+			From:     "phylax@example.com",
+			To:       []string{"ops@example.com", "dba@example.com"},
+		}
+
+		failed := domain.Event{
+			Type: domain.EventBackupFailed,
+			Time: time.Now(),
+			Backup: &domain.BackupResult{
+				Database: "prod-mysql",
+				Type:     "mysql",
+				Duration: 3 * time.Second,
+				Error:    "mysqldump failed",
+			},
+		}
+
+		Convey("NewSMTP", func() {
+			Convey("When recipients are missing", func() {
+				cfg.To = nil
+				_, err := NewSMTP(cfg)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("When tls mode is unknown", func() {
+				cfg.TLS = "ssl3"
+				_, err := NewSMTP(cfg)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "unsupported smtp tls mode")
+				})
+			})
+		})
+
+		Convey("Notify method", func() {
+			Convey("When sending an event immediately", func() {
+				n, err := NewSMTP(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), failed)
+
+				Convey("It should deliver a multipart message to every recipient", func() {
+					So(err, ShouldBeNil)
+					So(stub.rcpts, ShouldResemble, cfg.To)
+
+					msgs := stub.received()
+					So(len(msgs), ShouldEqual, 1)
+
+					msg, err := mail.ReadMessage(strings.NewReader(msgs[0]))
+					So(err, ShouldBeNil)
+					So(msg.Header.Get("Subject"), ShouldEqual, "[phylax] backup_failed prod-mysql")
+
+					mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+					So(err, ShouldBeNil)
+					So(mediaType, ShouldEqual, "multipart/alternative")
+
+					mr := multipart.NewReader(msg.Body, params["boundary"])
+					var types []string
+					for {
+						part, err := mr.NextPart()
+						if err != nil {
+							break
+						}
+						types = append(types, part.Header.Get("Content-Type"))
+					}
+					So(types, ShouldResemble, []string{"text/plain; charset=UTF-8", "text/html; charset=UTF-8"})
+					So(msgs[0], ShouldContainSubstring, "mysqldump failed")
+				})
+			})
+
+			Convey("When the event is not subscribed", func() {
+				cfg.Events = []string{string(domain.EventBackupFailed)}
+				n, err := NewSMTP(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), domain.Event{Type: domain.EventBackupSucceeded})
+
+				Convey("It should not send anything", func() {
+					So(err, ShouldBeNil)
+					So(len(stub.received()), ShouldEqual, 0)
+				})
+			})
+		})
+
+		Convey("SendSummary method", func() {
+			cfg.SummarySchedule = "0 0 8 * * *"
+			n, err := NewSMTP(cfg)
+			So(err, ShouldBeNil)
+
+			ctx := context.Background()
+			So(n.Notify(ctx, domain.Event{Type: domain.EventBackupStarted}), ShouldBeNil)
+			So(n.Notify(ctx, failed), ShouldBeNil)
+			So(n.Notify(ctx, domain.Event{
+				Type:   domain.EventBackupSucceeded,
+				Backup: &domain.BackupResult{Database: "analytics"},
+			}), ShouldBeNil)
+
+			Convey("It should batch events until the summary is sent", func() {
+				So(len(stub.received()), ShouldEqual, 0)
+				So(n.SummarySchedule(), ShouldEqual, "0 0 8 * * *")
+
+				So(n.SendSummary(ctx), ShouldBeNil)
+
+				msgs := stub.received()
+				So(len(msgs), ShouldEqual, 1)
+				So(msgs[0], ShouldContainSubstring, "Subject: [phylax] Summary: 1 succeeded, 0 partial, 1 failed")
+				So(msgs[0], ShouldContainSubstring, "analytics")
+				So(len(n.pending), ShouldEqual, 0)
+			})
+		})
+	})
+}
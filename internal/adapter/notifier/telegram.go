@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// maxErrorLength keeps failure messages well under Telegram's 4096 character
+// message limit.
+const maxErrorLength = 1000
+
+// TelegramNotifier sends events to Telegram chats. Failures and partial
+// uploads go to the failure chat, everything else to the success chat; both
+// fall back to the default chat.
+type TelegramNotifier struct {
+	bot           *tgbotapi.BotAPI
+	successChatID int64
+	failureChatID int64
+	events        []string
+}
+
+// NewTelegram creates a new TelegramNotifier.
+func NewTelegram(cfg *config.NotificationConfig) (*TelegramNotifier, error) {
+	if cfg == nil {
+		return nil, errors.New("configuration cannot be nil")
+	}
+
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+
+	defaultChatID, err := parseChatID(cfg.ChatID, 0)
+	if err != nil {
+		return nil, err
+	}
+	successChatID, err := parseChatID(cfg.SuccessChatID, defaultChatID)
+	if err != nil {
+		return nil, err
+	}
+	failureChatID, err := parseChatID(cfg.FailureChatID, defaultChatID)
+	if err != nil {
+		return nil, err
+	}
+	if successChatID == 0 || failureChatID == 0 {
+		return nil, errors.New("chat_id is required unless both success_chat_id and failure_chat_id are set")
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.BotToken, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telegram bot: %w", err)
+	}
+
+	return &TelegramNotifier{
+		bot:           bot,
+		successChatID: successChatID,
+		failureChatID: failureChatID,
+		events:        cfg.Events,
+	}, nil
+}
+
+// Notify formats the event and sends it to the chat it is routed to.
+func (t *TelegramNotifier) Notify(ctx context.Context, event domain.Event) error {
+	if !subscribed(t.events, event.Type) {
+		return nil
+	}
+
+	chatID := t.successChatID
+	if event.Type == domain.EventBackupFailed || event.Type == domain.EventBackupPartial {
+		chatID = t.failureChatID
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatTelegramMessage(event))
+	if _, err := t.bot.Send(msg); err != nil {
+		return fmt.Errorf("failed to send telegram notification: %w", err)
+	}
+	return nil
+}
+
+func formatTelegramMessage(event domain.Event) string {
+	var b strings.Builder
+
+	switch event.Type {
+	case domain.EventBackupStarted:
+		b.WriteString("🚀 Backup Started\n")
+	case domain.EventBackupSucceeded:
+		b.WriteString("✅ Backup Created\n")
+	case domain.EventBackupPartial:
+		b.WriteString("⚠️ Backup Partially Uploaded\n")
+	case domain.EventBackupFailed:
+		b.WriteString("❌ Backup Failed\n")
+	case domain.EventCleanupCompleted:
+		b.WriteString("🧹 Cleanup Completed\n")
+	default:
+		fmt.Fprintf(&b, "ℹ️ %s\n", event.Type)
+	}
+
+	if r := event.Backup; r != nil {
+		fmt.Fprintf(&b, "\n🗄 Database: %s", r.Database)
+		if r.Filename != "" {
+			fmt.Fprintf(&b, "\n📁 File: %s", r.Filename)
+			fmt.Fprintf(&b, "\n📊 Size: %.2f MB", float64(r.Size)/(1024*1024))
+		}
+		if c := r.Compression; c != nil {
+			fmt.Fprintf(&b, "\n🗜 Compression: %s, %.2fx, %.1f MB/s", c.Algorithm, c.Ratio, c.ThroughputMBps)
+		}
+		if event.Type != domain.EventBackupStarted {
+			fmt.Fprintf(&b, "\n⏱ Duration: %s", r.Duration.Round(time.Second))
+		}
+		if r.Error != "" {
+			fmt.Fprintf(&b, "\n⚠️ Error: %s", truncate(redact(r.Error), maxErrorLength))
+		}
+		if len(r.Uploads) > 0 {
+			b.WriteString("\n\n📤 Uploads:")
+			for _, u := range r.Uploads {
+				if u.Error != "" {
+					fmt.Fprintf(&b, "\n  ❌ %s: %s", u.Target, truncate(redact(u.Error), maxErrorLength/4))
+				} else {
+					fmt.Fprintf(&b, "\n  ✅ %s", u.Target)
+				}
+			}
+		}
+	}
+
+	for _, c := range event.Cleanup {
+		fmt.Fprintf(&b, "\n  %s: %d deleted, %d failed", c.Target, c.Deleted, c.Failed)
+		if c.Error != "" {
+			fmt.Fprintf(&b, " (%s)", truncate(redact(c.Error), maxErrorLength/4))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n\n🕐 Time: %s", event.Time.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
+
+func parseChatID(value string, fallback int64) (int64, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid telegram chat id %q: %w", value, err)
+	}
+	return id, nil
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "…"
+}
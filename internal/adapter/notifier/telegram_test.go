@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeBotAPI answers getMe and records sendMessage calls.
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	messages []map[string]string
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getMe"):
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"phylax","username":"phylax_bot"}}`))
+	case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+		r.ParseForm()
+		f.mu.Lock()
+		f.messages = append(f.messages, map[string]string{
+			"chat_id": r.Form.Get("chat_id"),
+			"text":    r.Form.Get("text"),
+		})
+		f.mu.Unlock()
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1}}}`))
+	default:
+		w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	Convey("Given a TelegramNotifier against a fake Bot API", t, func() {
+		api := &fakeBotAPI{}
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		cfg := &config.NotificationConfig{
+			Type:          "telegram",
+			BotToken:      "123:abc",
+			ChatID:        "100",
+			FailureChatID: "200",
+			APIEndpoint:   server.URL + "/bot%s/%s",
+		}
+
+		Convey("NewTelegram", func() {
+			Convey("When no chat is configured", func() {
+				cfg.ChatID, cfg.FailureChatID = "", ""
+				_, err := NewTelegram(cfg)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "chat_id is required")
+				})
+			})
+
+			Convey("When chat id is not numeric", func() {
+				cfg.ChatID = "@channel"
+				_, err := NewTelegram(cfg)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "invalid telegram chat id")
+				})
+			})
+		})
+
+		Convey("Notify method", func() {
+			n, err := NewTelegram(cfg)
+			So(err, ShouldBeNil)
+
+			Convey("When a backup fails", func() {
+				err := n.Notify(context.Background(), domain.Event{
+					Type: domain.EventBackupFailed,
+					Time: time.Now(),
+					Backup: &domain.BackupResult{
+						Database: "prod-mysql",
+						Duration: 90 * time.Second,
+						Error:    "backup: mysqldump failed: exit status 2, output: mysqldump --password=hunter2 denied",
+					},
+				})
+
+				Convey("It should send a redacted message to the failure chat", func() {
+					So(err, ShouldBeNil)
+					So(len(api.messages), ShouldEqual, 1)
+					So(api.messages[0]["chat_id"], ShouldEqual, "200")
+					So(api.messages[0]["text"], ShouldContainSubstring, "❌ Backup Failed")
+					So(api.messages[0]["text"], ShouldContainSubstring, "Duration: 1m30s")
+					So(api.messages[0]["text"], ShouldContainSubstring, "--password=***")
+					So(api.messages[0]["text"], ShouldNotContainSubstring, "hunter2")
+				})
+			})
+
+			Convey("When a backup is partially uploaded", func() {
+				err := n.Notify(context.Background(), domain.Event{
+					Type: domain.EventBackupPartial,
+					Backup: &domain.BackupResult{
+						Database: "prod-mysql",
+						Filename: "prod-mysql_mysql_20250101_000000.sql.gz",
+						Uploads: []domain.UploadResult{
+							{Target: "local"},
+							{Target: "s3", Error: "access denied"},
+						},
+					},
+				})
+
+				Convey("It should list the upload status per target", func() {
+					So(err, ShouldBeNil)
+					So(api.messages[0]["chat_id"], ShouldEqual, "200")
+					So(api.messages[0]["text"], ShouldContainSubstring, "✅ local")
+					So(api.messages[0]["text"], ShouldContainSubstring, "❌ s3: access denied")
+				})
+			})
+
+			Convey("When a backup succeeds", func() {
+				err := n.Notify(context.Background(), domain.Event{
+					Type: domain.EventBackupSucceeded,
+					Backup: &domain.BackupResult{
+						Database: "prod-mysql",
+						Compression: &domain.CompressionResult{
+							Algorithm:      "zstd",
+							Ratio:          4.25,
+							ThroughputMBps: 180.5,
+						},
+					},
+				})
+
+				Convey("It should send to the success chat", func() {
+					So(err, ShouldBeNil)
+					So(api.messages[0]["chat_id"], ShouldEqual, "100")
+					So(api.messages[0]["text"], ShouldContainSubstring, "✅ Backup Created")
+					So(api.messages[0]["text"], ShouldContainSubstring, "Compression: zstd, 4.25x, 180.5 MB/s")
+				})
+			})
+		})
+	})
+}
+
+func TestRedact(t *testing.T) {
+	Convey("Given error text containing credentials", t, func() {
+		cases := map[string]string{
+			"mysqldump --password=secret --user=root":         "mysqldump --password=*** --user=root",
+			"dial mysql://backup:s3cret@db:3306/app":          "dial mysql://backup:***@db:3306/app",
+			"Post https://api.telegram.org/bot123:AAE-x/send": "Post https://api.telegram.org/bot***/send",
+			"invalid token: abc123":                           "invalid token: ***",
+		}
+
+		Convey("It should mask every secret", func() {
+			for input, expected := range cases {
+				So(redact(input), ShouldEqual, expected)
+			}
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+const (
+	defaultSignatureHeader = "X-Phylax-Signature"
+	defaultMaxRetries      = 3
+	defaultRetryDelay      = 2 * time.Second
+	defaultTimeout         = 10 * time.Second
+)
+
+// WebhookNotifier posts events to an HTTP endpoint. The request body is
+// rendered from a text/template, so the same notifier can speak to Slack,
+// Mattermost, Discord or any incident system accepting JSON.
+type WebhookNotifier struct {
+	client          *http.Client
+	url             string
+	method          string
+	headers         map[string]string
+	template        *template.Template
+	secret          []byte
+	signatureHeader string
+	maxRetries      int
+	retryDelay      time.Duration
+	events          []string
+}
+
+// NewWebhook creates a new WebhookNotifier. Without a template the event is
+// sent as plain JSON.
+func NewWebhook(cfg *config.NotificationConfig) (*WebhookNotifier, error) {
+	if cfg == nil {
+		return nil, errors.New("configuration cannot be nil")
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("webhook url is required")
+	}
+
+	var tmpl *template.Template
+	if cfg.Template != "" {
+		var err error
+		tmpl, err = template.New("webhook").Funcs(templateFuncs).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+		}
+	}
+
+	method := strings.ToUpper(cfg.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	signatureHeader := cfg.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = defaultSignatureHeader
+	}
+
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	retryDelay := cfg.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = defaultRetryDelay
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &WebhookNotifier{
+		client:          &http.Client{Timeout: timeout},
+		url:             cfg.URL,
+		method:          method,
+		headers:         cfg.Headers,
+		template:        tmpl,
+		secret:          []byte(cfg.Secret),
+		signatureHeader: signatureHeader,
+		maxRetries:      maxRetries,
+		retryDelay:      retryDelay,
+		events:          cfg.Events,
+	}, nil
+}
+
+// Notify renders the payload for the event and delivers it, retrying with
+// exponential backoff on network errors, 429 and 5xx responses.
+func (w *WebhookNotifier) Notify(ctx context.Context, event domain.Event) error {
+	if !subscribed(w.events, event.Type) {
+		return nil
+	}
+
+	body, err := w.render(event)
+	if err != nil {
+		return err
+	}
+
+	delay := w.retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := w.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt > w.maxRetries {
+			return fmt.Errorf("webhook delivery failed after %d attempt(s): %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (w *WebhookNotifier) render(event domain.Event) ([]byte, error) {
+	if w.template == nil {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event: %w", err)
+		}
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, event); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// send performs a single delivery attempt and reports whether a failure is
+// worth retrying.
+func (w *WebhookNotifier) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+	if len(w.secret) > 0 {
+		req.Header.Set(w.signatureHeader, "sha256="+sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+// sign returns the hex-encoded HMAC-SHA256 of body.
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	Convey("Given a WebhookNotifier", t, func() {
+		event := domain.Event{
+			Type: domain.EventBackupFailed,
+			Time: time.Now(),
+			Backup: &domain.BackupResult{
+				Database: "prod-mysql",
+				Error:    "mysqldump failed",
+			},
+		}
+
+		var hits atomic.Int32
+		var body, signature string
+		status := http.StatusOK
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			b, _ := io.ReadAll(r.Body)
+			body = string(b)
+			signature = r.Header.Get("X-Phylax-Signature")
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		cfg := &config.NotificationConfig{
+			Type:       "webhook",
+			URL:        server.URL,
+			RetryDelay: time.Millisecond,
+		}
+
+		Convey("NewWebhook", func() {
+			Convey("When url is missing", func() {
+				_, err := NewWebhook(&config.NotificationConfig{Type: "webhook"})
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "url is required")
+				})
+			})
+
+			Convey("When template is invalid", func() {
+				cfg.Template = "{{ .Type"
+				_, err := NewWebhook(cfg)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "failed to parse webhook template")
+				})
+			})
+		})
+
+		Convey("Notify method", func() {
+			Convey("When no template is configured", func() {
+				n, err := NewWebhook(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), event)
+
+				Convey("It should post the event as JSON", func() {
+					So(err, ShouldBeNil)
+					So(body, ShouldContainSubstring, `"type":"backup_failed"`)
+					So(body, ShouldContainSubstring, `"database":"prod-mysql"`)
+					So(signature, ShouldBeEmpty)
+				})
+			})
+
+			Convey("When a template and secret are configured", func() {
+				cfg.Template = `{"text": {{ json (printf "%s: %s" .Backup.Database .Backup.Error) }}}`
+				cfg.Secret = "s3cr3t"
+				n, err := NewWebhook(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), event)
+
+				Convey("It should render the template and sign the body", func() {
+					So(err, ShouldBeNil)
+					So(body, ShouldEqual, `{"text": "prod-mysql: mysqldump failed"}`)
+					So(signature, ShouldEqual, "sha256="+sign([]byte("s3cr3t"), []byte(body)))
+				})
+			})
+
+			Convey("When the endpoint keeps failing", func() {
+				status = http.StatusBadGateway
+				cfg.MaxRetries = 2
+				n, err := NewWebhook(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), event)
+
+				Convey("It should retry and then return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "after 3 attempt(s)")
+					So(hits.Load(), ShouldEqual, 3)
+				})
+			})
+
+			Convey("When the endpoint rejects the request", func() {
+				status = http.StatusBadRequest
+				n, err := NewWebhook(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), event)
+
+				Convey("It should not retry", func() {
+					So(err, ShouldNotBeNil)
+					So(hits.Load(), ShouldEqual, 1)
+				})
+			})
+
+			Convey("When the event is not subscribed", func() {
+				cfg.Events = []string{"backup_succeeded"}
+				n, err := NewWebhook(cfg)
+				So(err, ShouldBeNil)
+
+				err = n.Notify(context.Background(), event)
+
+				Convey("It should skip delivery", func() {
+					So(err, ShouldBeNil)
+					So(hits.Load(), ShouldEqual, 0)
+				})
+			})
+		})
+	})
+}
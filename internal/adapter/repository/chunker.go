@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"bufio"
+	"io"
+)
+
+// Chunk size bounds for content-defined chunking. Boundaries depend only on
+// the bytes around them, so an insert early in a dump shifts a single chunk
+// instead of every chunk after it.
+const (
+	minChunkSize = 512 << 10
+	avgChunkBits = 21 // 2 MiB average
+	maxChunkSize = 8 << 20
+)
+
+// chunkMask selects the top avgChunkBits of the gear hash; with a left-shift
+// rolling hash the high bits cover the widest window of recent bytes.
+const chunkMask = uint64(1<<avgChunkBits-1) << (64 - avgChunkBits)
+
+// gearTable maps each byte to a pseudo-random value. It must never change:
+// different tables cut different boundaries and defeat deduplication
+// against existing snapshots.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x7068796c61780001)
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker splits a stream into content-defined chunks using a gear hash.
+type chunker struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func newChunker(r io.Reader) *chunker {
+	return &chunker{
+		r:   bufio.NewReaderSize(r, 1<<20),
+		buf: make([]byte, 0, maxChunkSize),
+	}
+}
+
+// Next returns the next chunk, or io.EOF once the stream is exhausted. The
+// returned slice is only valid until the following call.
+func (c *chunker) Next() ([]byte, error) {
+	c.buf = c.buf[:0]
+	var hash uint64
+
+	for len(c.buf) < maxChunkSize {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		c.buf = append(c.buf, b)
+		hash = hash<<1 + gearTable[b]
+		if len(c.buf) >= minChunkSize && hash&chunkMask == 0 {
+			break
+		}
+	}
+
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+	return c.buf, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lockPrefix = "lock-"
+
+	// lockStaleAfter is how long a lock survives without a refresh before
+	// other processes ignore it, e.g. after a crash.
+	lockStaleAfter = 30 * time.Minute
+	// lockRefreshInterval keeps long uploads well inside lockStaleAfter.
+	lockRefreshInterval = 5 * time.Minute
+)
+
+// lockInfo is the content of a lock file. Uploads and deletes take shared
+// locks; prune takes an exclusive lock so it never removes chunks that a
+// concurrent upload has decided to reuse.
+type lockInfo struct {
+	ID        string    `json:"id"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Exclusive bool      `json:"exclusive"`
+	Time      time.Time `json:"time"`
+}
+
+func (l lockInfo) name() string {
+	return lockPrefix + l.ID + ".json"
+}
+
+func (l lockInfo) stale(now time.Time) bool {
+	return now.Sub(l.Time) > lockStaleAfter
+}
+
+// conflicts reports whether l and other may not be held at the same time.
+func (l lockInfo) conflicts(other lockInfo) bool {
+	return l.ID != other.ID && (l.Exclusive || other.Exclusive)
+}
+
+// repoLock is a held lock that is refreshed in the background until released.
+type repoLock struct {
+	repo *Repository
+	info lockInfo
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// lock acquires a shared or exclusive lock on the repository.
+func (r *Repository) lock(ctx context.Context, exclusive bool) (*repoLock, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate lock id: %w", err)
+	}
+	host, _ := os.Hostname()
+
+	info := lockInfo{
+		ID:        hex.EncodeToString(id),
+		Host:      host,
+		PID:       os.Getpid(),
+		Exclusive: exclusive,
+		Time:      time.Now().UTC(),
+	}
+
+	if err := r.checkLocks(ctx, info); err != nil {
+		return nil, err
+	}
+	if err := r.writeLock(ctx, info); err != nil {
+		return nil, err
+	}
+	// Another process may have written its lock between our check and
+	// write; checking again after ours is visible closes that window.
+	if err := r.checkLocks(ctx, info); err != nil {
+		r.storage.Delete(context.WithoutCancel(ctx), info.name())
+		return nil, err
+	}
+
+	l := &repoLock{repo: r, info: info, stop: make(chan struct{})}
+	l.done.Add(1)
+	go l.refresh(ctx)
+	return l, nil
+}
+
+func (r *Repository) checkLocks(ctx context.Context, own lockInfo) error {
+	locks, err := r.readLocks(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, other := range locks {
+		if other.stale(now) || !own.conflicts(other) {
+			continue
+		}
+		return fmt.Errorf("repository is locked by %s (pid %d, exclusive=%t) since %s",
+			other.Host, other.PID, other.Exclusive, other.Time.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (r *Repository) readLocks(ctx context.Context) ([]lockInfo, error) {
+	files, err := r.storage.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository: %w", err)
+	}
+
+	var locks []lockInfo
+	for _, name := range files {
+		if !strings.HasPrefix(name, lockPrefix) {
+			continue
+		}
+		var info lockInfo
+		if err := r.readJSON(ctx, name, &info); err != nil {
+			// The holder may have released it while we were listing.
+			continue
+		}
+		locks = append(locks, info)
+	}
+	return locks, nil
+}
+
+func (r *Repository) writeLock(ctx context.Context, info lockInfo) error {
+	if err := r.writeJSON(ctx, info.name(), info); err != nil {
+		return fmt.Errorf("failed to write lock: %w", err)
+	}
+	return nil
+}
+
+func (l *repoLock) refresh(ctx context.Context) {
+	defer l.done.Done()
+
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.info.Time = time.Now().UTC()
+			if err := l.repo.writeLock(ctx, l.info); err != nil {
+				l.repo.logger.Warnf("Failed to refresh repository lock: %v", err)
+			}
+		}
+	}
+}
+
+// Release stops refreshing and removes the lock file.
+func (l *repoLock) Release(ctx context.Context) {
+	close(l.stop)
+	l.done.Wait()
+
+	if err := l.repo.storage.Delete(context.WithoutCancel(ctx), l.info.name()); err != nil {
+		l.repo.logger.Warnf("Failed to remove repository lock %s: %v", l.info.name(), err)
+	}
+}
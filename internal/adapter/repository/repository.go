@@ -0,0 +1,508 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/semmidev/phylax/internal/domain"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+)
+
+const (
+	dataPrefix     = "data-"
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+
+	defaultWorkers = 4
+)
+
+// Backend is a storage that can hold a repository: every file is written
+// and read back by name.
+type Backend interface {
+	domain.Storage
+	domain.Downloader
+}
+
+// Repository stores backups as deduplicated, content-addressed chunks on top
+// of another storage. Each backup becomes a snapshot index listing its
+// chunks; chunks shared between snapshots are stored once.
+//
+// Layout (all files flat, so any storage can hold it):
+//
+//	data-<sha256>            zstd-compressed chunk
+//	snapshot-<name>.json     snapshot index for backup <name>
+//	lock-<id>.json           shared or exclusive lock
+type Repository struct {
+	storage Backend
+	logger  *logger.Logger
+	workers int
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// Snapshot is the index of one backup stored in the repository.
+type Snapshot struct {
+	Name   string      `json:"name"`
+	Time   time.Time   `json:"time"`
+	Size   int64       `json:"size"`
+	SHA256 string      `json:"sha256"`
+	Chunks []ChunkInfo `json:"chunks"`
+}
+
+// ChunkInfo references a chunk by the SHA-256 of its uncompressed content.
+type ChunkInfo struct {
+	ID   string `json:"id"`
+	Size int    `json:"size"`
+}
+
+// New wraps storage in a Repository. The storage must support downloads so
+// snapshots and locks can be read back.
+func New(storage domain.Storage, logger *logger.Logger) (*Repository, error) {
+	if logger == nil {
+		return nil, errors.New("logger cannot be nil")
+	}
+	backend, ok := storage.(Backend)
+	if !ok {
+		return nil, fmt.Errorf("storage %T does not support downloads", storage)
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk encoder: %w", err)
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk decoder: %w", err)
+	}
+
+	return &Repository{
+		storage: backend,
+		logger:  logger,
+		workers: defaultWorkers,
+		encoder: encoder,
+		decoder: decoder,
+	}, nil
+}
+
+// Upload splits localPath into chunks, stores those the repository does not
+// have yet and records a snapshot named remoteName.
+func (r *Repository) Upload(ctx context.Context, localPath string, remoteName string) error {
+	lock, err := r.lock(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(ctx)
+
+	files, err := r.storage.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list repository: %w", err)
+	}
+	existing := make(map[string]bool)
+	for _, name := range files {
+		if id, ok := strings.CutPrefix(name, dataPrefix); ok {
+			existing[id] = true
+		}
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer file.Close()
+
+	tempDir, err := os.MkdirTemp("", "phylax-repo")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		id   string
+		data []byte
+	}
+	jobs := make(chan job, r.workers)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for range r.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := r.writeChunk(ctx, tempDir, j.id, j.data); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	snapshot := Snapshot{Name: remoteName, Time: time.Now().UTC()}
+	hasher := sha256.New()
+	chunker := newChunker(io.TeeReader(file, hasher))
+	var newChunks, newBytes int64
+
+	for ctx.Err() == nil {
+		data, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(fmt.Errorf("failed to read source file: %w", err))
+			break
+		}
+
+		sum := sha256.Sum256(data)
+		id := hex.EncodeToString(sum[:])
+		snapshot.Chunks = append(snapshot.Chunks, ChunkInfo{ID: id, Size: len(data)})
+		snapshot.Size += int64(len(data))
+
+		if existing[id] {
+			continue
+		}
+		existing[id] = true
+		newChunks++
+		newBytes += int64(len(data))
+		jobs <- job{id: id, data: append([]byte(nil), data...)}
+	}
+
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	snapshot.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	if err := r.writeJSON(ctx, snapshotFile(remoteName), snapshot); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	r.logger.Infof("Repository snapshot %s: %d chunk(s), %d new (%.2f MB new of %.2f MB)",
+		remoteName, len(snapshot.Chunks), newChunks,
+		float64(newBytes)/(1024*1024), float64(snapshot.Size)/(1024*1024))
+	return nil
+}
+
+// Download restores the snapshot remoteName to localPath, verifying every
+// chunk and the whole file against their recorded hashes.
+func (r *Repository) Download(ctx context.Context, remoteName string, localPath string) error {
+	snapshot, err := r.Snapshot(ctx, remoteName)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "phylax-repo")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer dest.Close()
+
+	hasher := sha256.New()
+	out := io.MultiWriter(dest, hasher)
+	for _, chunk := range snapshot.Chunks {
+		data, err := r.readChunk(ctx, tempDir, chunk.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != snapshot.SHA256 {
+		return fmt.Errorf("snapshot %s is corrupt: checksum %s, expected %s", remoteName, sum, snapshot.SHA256)
+	}
+	return dest.Close()
+}
+
+// List returns the names of all snapshots.
+func (r *Repository) List(ctx context.Context) ([]string, error) {
+	files, err := r.storage.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository: %w", err)
+	}
+	return snapshotNames(files), nil
+}
+
+// Delete forgets the snapshot remoteName. Its chunks stay until Prune.
+func (r *Repository) Delete(ctx context.Context, remoteName string) error {
+	lock, err := r.lock(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(ctx)
+
+	return r.storage.Delete(ctx, snapshotFile(remoteName))
+}
+
+// GetOldFiles returns snapshots last written before cutoffTime. Chunks are
+// never returned; they are removed by Prune once unreferenced.
+func (r *Repository) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	files, err := r.storage.GetOldFiles(ctx, cutoffTime)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotNames(files), nil
+}
+
+// Snapshot reads the index of the snapshot name.
+func (r *Repository) Snapshot(ctx context.Context, name string) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := r.readJSON(ctx, snapshotFile(name), &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	return &snapshot, nil
+}
+
+// Prune removes chunks no snapshot references and locks that went stale.
+// It holds an exclusive lock, so no upload can reuse a chunk being removed.
+func (r *Repository) Prune(ctx context.Context) error {
+	lock, err := r.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(ctx)
+
+	files, err := r.storage.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list repository: %w", err)
+	}
+
+	referenced, err := r.referencedChunks(ctx, snapshotNames(files))
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]bool)
+	var removed, failed int
+	for _, name := range files {
+		id, ok := strings.CutPrefix(name, dataPrefix)
+		if !ok {
+			continue
+		}
+		stored[id] = true
+		if referenced[id] {
+			continue
+		}
+		if err := r.storage.Delete(ctx, name); err != nil {
+			r.logger.Errorf("Failed to remove unreferenced chunk %s: %v", id, err)
+			failed++
+			continue
+		}
+		removed++
+	}
+
+	for id := range referenced {
+		if !stored[id] {
+			r.logger.Warnf("Repository is missing chunk %s referenced by a snapshot", id)
+		}
+	}
+
+	locks, err := r.readLocks(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, l := range locks {
+		if l.stale(now) {
+			r.logger.Warnf("Removing stale repository lock held by %s (pid %d)", l.Host, l.PID)
+			r.storage.Delete(ctx, l.name())
+		}
+	}
+
+	r.logger.Infof("Repository prune removed %d unreferenced chunk(s), kept %d", removed, len(stored)-removed)
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d chunk(s)", failed)
+	}
+	return nil
+}
+
+// Check verifies that every chunk referenced by a snapshot exists. With
+// readData it also downloads each chunk and verifies its hash.
+func (r *Repository) Check(ctx context.Context, readData bool) error {
+	lock, err := r.lock(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(ctx)
+
+	files, err := r.storage.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list repository: %w", err)
+	}
+	stored := make(map[string]bool)
+	for _, name := range files {
+		if id, ok := strings.CutPrefix(name, dataPrefix); ok {
+			stored[id] = true
+		}
+	}
+
+	referenced, err := r.referencedChunks(ctx, snapshotNames(files))
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "phylax-repo")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ids := make([]string, 0, len(referenced))
+	for id := range referenced {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var errs []error
+	for _, id := range ids {
+		if !stored[id] {
+			errs = append(errs, fmt.Errorf("chunk %s is missing", id))
+			continue
+		}
+		if readData {
+			if _, err := r.readChunk(ctx, tempDir, id); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Repository) referencedChunks(ctx context.Context, snapshots []string) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	for _, name := range snapshots {
+		snapshot, err := r.Snapshot(ctx, name)
+		if err != nil {
+			// Pruning without every index could delete live chunks.
+			return nil, err
+		}
+		for _, chunk := range snapshot.Chunks {
+			referenced[chunk.ID] = true
+		}
+	}
+	return referenced, nil
+}
+
+func (r *Repository) writeChunk(ctx context.Context, tempDir, id string, data []byte) error {
+	path := filepath.Join(tempDir, dataPrefix+id)
+	if err := os.WriteFile(path, r.encoder.EncodeAll(data, nil), 0600); err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+	defer os.Remove(path)
+
+	if err := r.storage.Upload(ctx, path, dataPrefix+id); err != nil {
+		return fmt.Errorf("failed to upload chunk %s: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repository) readChunk(ctx context.Context, tempDir, id string) ([]byte, error) {
+	path := filepath.Join(tempDir, dataPrefix+id)
+	if err := r.storage.Download(ctx, dataPrefix+id, path); err != nil {
+		return nil, fmt.Errorf("failed to download chunk %s: %w", id, err)
+	}
+	defer os.Remove(path)
+
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %w", id, err)
+	}
+	data, err := r.decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s is corrupt: %w", id, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != id {
+		return nil, fmt.Errorf("chunk %s is corrupt: checksum mismatch", id)
+	}
+	return data, nil
+}
+
+func (r *Repository) readJSON(ctx context.Context, name string, v any) error {
+	file, err := os.CreateTemp("", "phylax-repo-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := r.storage.Download(ctx, name, file.Name()); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (r *Repository) writeJSON(ctx context.Context, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "phylax-repo-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return r.storage.Upload(ctx, file.Name(), name)
+}
+
+func snapshotFile(name string) string {
+	return snapshotPrefix + name + snapshotSuffix
+}
+
+func snapshotNames(files []string) []string {
+	names := make([]string, 0)
+	for _, file := range files {
+		if strings.HasPrefix(file, snapshotPrefix) && strings.HasSuffix(file, snapshotSuffix) {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(file, snapshotPrefix), snapshotSuffix))
+		}
+	}
+	return names
+}
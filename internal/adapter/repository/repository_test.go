@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/adapter/storage"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChunker(t *testing.T) {
+	Convey("Given random data", t, func() {
+		data := make([]byte, 24<<20)
+		rand.New(rand.NewSource(1)).Read(data)
+
+		split := func(b []byte) [][]byte {
+			var chunks [][]byte
+			c := newChunker(bytes.NewReader(b))
+			for {
+				chunk, err := c.Next()
+				if err != nil {
+					break
+				}
+				chunks = append(chunks, append([]byte(nil), chunk...))
+			}
+			return chunks
+		}
+
+		Convey("Chunks should respect the size bounds and join back to the input", func() {
+			chunks := split(data)
+			So(len(chunks), ShouldBeGreaterThan, 2)
+			for i, chunk := range chunks {
+				So(len(chunk), ShouldBeLessThanOrEqualTo, maxChunkSize)
+				if i < len(chunks)-1 {
+					So(len(chunk), ShouldBeGreaterThanOrEqualTo, minChunkSize)
+				}
+			}
+			So(bytes.Join(chunks, nil), ShouldResemble, data)
+		})
+
+		Convey("An insertion should only change the chunks around it", func() {
+			edited := append(append(append([]byte(nil), data[:1000]...), []byte("inserted")...), data[1000:]...)
+
+			original := make(map[string]bool)
+			for _, chunk := range split(data) {
+				original[string(chunk)] = true
+			}
+			changed := 0
+			for _, chunk := range split(edited) {
+				if !original[string(chunk)] {
+					changed++
+				}
+			}
+			So(changed, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestRepository(t *testing.T) {
+	Convey("Given a repository on local storage", t, func() {
+		ctx := context.Background()
+		tempDir, err := os.MkdirTemp("", "repository_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		local, err := storage.NewLocal(filepath.Join(tempDir, "repo"))
+		So(err, ShouldBeNil)
+		log, _ := logger.New("fatal", "")
+		repo, err := New(local, log)
+		So(err, ShouldBeNil)
+
+		data := make([]byte, 12<<20)
+		rand.New(rand.NewSource(2)).Read(data)
+		first := filepath.Join(tempDir, "first.sql")
+		So(os.WriteFile(first, data, 0644), ShouldBeNil)
+
+		edited := append([]byte("-- header changed\n"), data...)
+		second := filepath.Join(tempDir, "second.sql")
+		So(os.WriteFile(second, edited, 0644), ShouldBeNil)
+
+		countChunks := func() int {
+			files, _ := local.List(ctx)
+			n := 0
+			for _, f := range files {
+				if strings.HasPrefix(f, dataPrefix) {
+					n++
+				}
+			}
+			return n
+		}
+
+		So(repo.Upload(ctx, first, "db_20240101_000000.sql"), ShouldBeNil)
+		afterFirst := countChunks()
+		So(repo.Upload(ctx, second, "db_20240102_000000.sql"), ShouldBeNil)
+
+		Convey("The second snapshot should reuse unchanged chunks", func() {
+			So(countChunks(), ShouldBeLessThanOrEqualTo, afterFirst+1)
+
+			names, err := repo.List(ctx)
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"db_20240101_000000.sql", "db_20240102_000000.sql"})
+
+			files, _ := local.List(ctx)
+			for _, f := range files {
+				So(f, ShouldNotStartWith, lockPrefix)
+			}
+		})
+
+		Convey("Download should restore each snapshot byte for byte", func() {
+			restored := filepath.Join(tempDir, "restored.sql")
+			So(repo.Download(ctx, "db_20240102_000000.sql", restored), ShouldBeNil)
+			content, err := os.ReadFile(restored)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(content, edited), ShouldBeTrue)
+			So(repo.Check(ctx, true), ShouldBeNil)
+		})
+
+		Convey("Prune after forgetting a snapshot should only remove its unique chunks", func() {
+			So(repo.Delete(ctx, "db_20240102_000000.sql"), ShouldBeNil)
+			So(repo.Prune(ctx), ShouldBeNil)
+			So(countChunks(), ShouldEqual, afterFirst)
+
+			restored := filepath.Join(tempDir, "restored.sql")
+			So(repo.Download(ctx, "db_20240101_000000.sql", restored), ShouldBeNil)
+			content, _ := os.ReadFile(restored)
+			So(bytes.Equal(content, data), ShouldBeTrue)
+
+			Convey("And prune with no snapshots left should empty the repository", func() {
+				So(repo.Delete(ctx, "db_20240101_000000.sql"), ShouldBeNil)
+				So(repo.Prune(ctx), ShouldBeNil)
+				So(countChunks(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("A corrupted chunk should fail Check and Download", func() {
+			files, _ := local.List(ctx)
+			for _, f := range files {
+				if strings.HasPrefix(f, dataPrefix) {
+					So(os.WriteFile(local.GetPath(f), []byte("garbage"), 0644), ShouldBeNil)
+					break
+				}
+			}
+
+			So(repo.Check(ctx, false), ShouldBeNil)
+			So(repo.Check(ctx, true), ShouldNotBeNil)
+		})
+
+		Convey("A missing chunk should fail Check", func() {
+			files, _ := local.List(ctx)
+			for _, f := range files {
+				if strings.HasPrefix(f, dataPrefix) {
+					So(local.Delete(ctx, f), ShouldBeNil)
+					break
+				}
+			}
+
+			err := repo.Check(ctx, false)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "is missing")
+		})
+
+		Convey("An exclusive lock should block uploads until released", func() {
+			lock, err := repo.lock(ctx, true)
+			So(err, ShouldBeNil)
+
+			err = repo.Upload(ctx, first, "db_20240103_000000.sql")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "repository is locked")
+
+			lock.Release(ctx)
+			So(repo.Upload(ctx, first, "db_20240103_000000.sql"), ShouldBeNil)
+		})
+
+		Convey("A stale lock should be ignored and removed by prune", func() {
+			stale := lockInfo{ID: "stale", Host: "old-host", Exclusive: true, Time: time.Now().Add(-time.Hour)}
+			So(repo.writeLock(ctx, stale), ShouldBeNil)
+
+			So(repo.Upload(ctx, first, "db_20240103_000000.sql"), ShouldBeNil)
+			So(repo.Prune(ctx), ShouldBeNil)
+
+			files, _ := local.List(ctx)
+			So(files, ShouldNotContain, stale.name())
+		})
+	})
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/semmidev/phylax/internal/config"
+)
+
+// defaultAzureBlockSize is the size of each staged block. Azure allows up to
+// 50,000 blocks per blob, so 8 MiB blocks cover backups of roughly 390 GiB.
+const defaultAzureBlockSize = 8 << 20
+
+// AzureStorage implements the Storage interface for an Azure Blob Storage
+// container. Files are uploaded as block blobs, one staged block at a time.
+type AzureStorage struct {
+	client    *container.Client
+	prefix    string
+	blockSize int64
+	commit    blockblob.CommitBlockListOptions
+	immutable time.Duration
+}
+
+// NewAzure creates a new AzureStorage instance. Credentials come from, in
+// order of preference, a connection string, a SAS token or an account name
+// and shared key.
+func NewAzure(cfg *config.UploadTarget) (*AzureStorage, error) {
+	if cfg.Container == "" {
+		return nil, errors.New("azure container is required")
+	}
+
+	client, err := newAzureContainerClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AzureStorage{
+		client:    client,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		blockSize: defaultAzureBlockSize,
+		commit:    blockblob.CommitBlockListOptions{Tags: cfg.Tags},
+	}
+	if cfg.BlockSizeMB > 0 {
+		s.blockSize = int64(cfg.BlockSizeMB) << 20
+	}
+	if s.blockSize > blockblob.MaxStageBlockBytes {
+		return nil, fmt.Errorf("block_size_mb must be at most %d", blockblob.MaxStageBlockBytes>>20)
+	}
+
+	if cfg.AccessTier != "" {
+		tier, ok := matchFold(blob.PossibleAccessTierValues(), cfg.AccessTier)
+		if !ok {
+			return nil, fmt.Errorf("unsupported access_tier: %s", cfg.AccessTier)
+		}
+		s.commit.Tier = &tier
+	}
+
+	switch {
+	case cfg.ImmutabilityMode != "":
+		mode, ok := matchFold(blob.PossibleImmutabilityPolicySettingValues(), cfg.ImmutabilityMode)
+		if !ok {
+			return nil, fmt.Errorf("unsupported immutability_mode: %s", cfg.ImmutabilityMode)
+		}
+		if cfg.ImmutabilityDays <= 0 {
+			return nil, errors.New("immutability_days must be positive when immutability_mode is set")
+		}
+		s.commit.ImmutabilityPolicyMode = &mode
+		s.immutable = time.Duration(cfg.ImmutabilityDays) * 24 * time.Hour
+	case cfg.ImmutabilityDays != 0:
+		return nil, errors.New("immutability_days requires immutability_mode")
+	}
+
+	return s, nil
+}
+
+func newAzureContainerClient(cfg *config.UploadTarget) (*container.Client, error) {
+	if cfg.ConnectionString != "" {
+		client, err := container.NewClientFromConnectionString(cfg.ConnectionString, cfg.Container, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse azure connection string: %w", err)
+		}
+		return client, nil
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		if cfg.AccountName == "" {
+			return nil, errors.New("azure requires connection_string, account_name or endpoint")
+		}
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.AccountName)
+	}
+	containerURL := strings.TrimSuffix(endpoint, "/") + "/" + cfg.Container
+
+	switch {
+	case cfg.SASToken != "":
+		client, err := container.NewClientWithNoCredential(containerURL+"?"+strings.TrimPrefix(cfg.SASToken, "?"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create azure client: %w", err)
+		}
+		return client, nil
+
+	case cfg.AccountKey != "":
+		if cfg.AccountName == "" {
+			return nil, errors.New("account_key requires account_name")
+		}
+		cred, err := container.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid azure account key: %w", err)
+		}
+		client, err := container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create azure client: %w", err)
+		}
+		return client, nil
+	}
+
+	return nil, errors.New("azure requires connection_string, sas_token or account_key")
+}
+
+// Upload stages the file as fixed-size blocks and commits them in a single
+// block list, so an interrupted upload never leaves a partial blob behind.
+func (s *AzureStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() > s.blockSize*blockblob.MaxBlocks {
+		return fmt.Errorf("file too large for block_size_mb %d", s.blockSize>>20)
+	}
+
+	client := s.client.NewBlockBlobClient(s.key(remoteName))
+
+	var blockIDs []string
+	for offset := int64(0); offset < info.Size(); offset += s.blockSize {
+		blockID := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%08d", len(blockIDs)))
+		section := io.NewSectionReader(file, offset, min(s.blockSize, info.Size()-offset))
+
+		if _, err := client.StageBlock(ctx, blockID, streaming.NopCloser(section), nil); err != nil {
+			return fmt.Errorf("failed to stage block %d: %w", len(blockIDs), err)
+		}
+		blockIDs = append(blockIDs, blockID)
+	}
+
+	opts := s.commit
+	if s.immutable > 0 {
+		expiry := time.Now().Add(s.immutable)
+		opts.ImmutabilityPolicyExpiryTime = &expiry
+	}
+
+	if _, err := client.CommitBlockList(ctx, blockIDs, &opts); err != nil {
+		return fmt.Errorf("failed to commit block list: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all blobs under the prefix
+func (s *AzureStorage) List(ctx context.Context) ([]string, error) {
+	var files []string
+	err := s.listBlobs(ctx, func(name string, _ time.Time) {
+		files = append(files, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Delete removes a blob. Blobs still under an immutability policy cannot be
+// deleted until it expires.
+func (s *AzureStorage) Delete(ctx context.Context, remoteName string) error {
+	if _, err := s.client.NewBlobClient(s.key(remoteName)).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("failed to delete from Azure: %w", err)
+	}
+
+	return nil
+}
+
+// GetOldFiles returns blobs last modified before cutoffTime
+func (s *AzureStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	var oldFiles []string
+	err := s.listBlobs(ctx, func(name string, modified time.Time) {
+		if modified.Before(cutoffTime) {
+			oldFiles = append(oldFiles, name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return oldFiles, nil
+}
+
+// listBlobs calls fn for every blob under the prefix, following the
+// continuation marker across listing pages.
+func (s *AzureStorage) listBlobs(ctx context.Context, fn func(name string, modified time.Time)) error {
+	opts := &container.ListBlobsFlatOptions{}
+	if s.prefix != "" {
+		prefix := s.prefix + "/"
+		opts.Prefix = &prefix
+	}
+
+	pager := s.client.NewListBlobsFlatPager(opts)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list Azure blobs: %w", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			var modified time.Time
+			if item.Properties != nil && item.Properties.LastModified != nil {
+				modified = *item.Properties.LastModified
+			}
+			fn(s.name(*item.Name), modified)
+		}
+	}
+
+	return nil
+}
+
+func (s *AzureStorage) key(remoteName string) string {
+	return path.Join(s.prefix, remoteName)
+}
+
+func (s *AzureStorage) name(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.prefix+"/")
+}
+
+// matchFold returns the value equal to s under case folding, so config can
+// use "cool" for the SDK's "Cool".
+func matchFold[T ~string](values []T, s string) (T, bool) {
+	i := slices.IndexFunc(values, func(v T) bool { return strings.EqualFold(string(v), s) })
+	if i < 0 {
+		return "", false
+	}
+	return values[i], true
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewAzure(t *testing.T) {
+	Convey("Given Azure upload settings", t, func() {
+		cfg := &config.UploadTarget{
+			Type:        "azure",
+			Container:   "backups",
+			AccountName: "phylax",
+			SASToken:    "?sv=2024-01-01&sig=abc",
+		}
+
+		Convey("When access tier and immutability are set", func() {
+			cfg.AccessTier = "cool"
+			cfg.ImmutabilityMode = "unlocked"
+			cfg.ImmutabilityDays = 30
+			storage, err := NewAzure(cfg)
+
+			Convey("It should normalize them", func() {
+				So(err, ShouldBeNil)
+				So(string(*storage.commit.Tier), ShouldEqual, "Cool")
+				So(string(*storage.commit.ImmutabilityPolicyMode), ShouldEqual, "Unlocked")
+				So(storage.immutable, ShouldEqual, 30*24*time.Hour)
+			})
+		})
+
+		Convey("When the access tier is unknown", func() {
+			cfg.AccessTier = "Lukewarm"
+			_, err := NewAzure(cfg)
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "unsupported access_tier")
+			})
+		})
+
+		Convey("When immutability has no retention", func() {
+			cfg.ImmutabilityMode = "Locked"
+			_, err := NewAzure(cfg)
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "immutability_days must be positive")
+			})
+		})
+
+		Convey("When no credentials are set", func() {
+			cfg.SASToken = ""
+			_, err := NewAzure(cfg)
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "requires connection_string, sas_token or account_key")
+			})
+		})
+
+		Convey("When the container is missing", func() {
+			cfg.Container = ""
+			_, err := NewAzure(cfg)
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestAzureStorage(t *testing.T) {
+	Convey("Given an AzureStorage against a fake Blob service", t, func() {
+		var (
+			mu      sync.Mutex
+			staged  = map[string]int{}
+			commits []*http.Request
+			blocks  []string
+			markers []string
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			q := r.URL.Query()
+			switch {
+			case r.Method == http.MethodPut && q.Get("comp") == "block":
+				body, _ := io.ReadAll(r.Body)
+				staged[q.Get("blockid")] = len(body)
+				w.WriteHeader(http.StatusCreated)
+
+			case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+				var list struct {
+					Latest []string `xml:"Latest"`
+				}
+				body, _ := io.ReadAll(r.Body)
+				xml.Unmarshal(body, &list)
+				blocks = list.Latest
+				commits = append(commits, r)
+				w.WriteHeader(http.StatusCreated)
+
+			case r.Method == http.MethodGet && q.Get("comp") == "list":
+				markers = append(markers, q.Get("marker"))
+				w.Header().Set("Content-Type", "application/xml")
+				if q.Get("marker") == "" {
+					fmt.Fprint(w, `<EnumerationResults><Blobs>`+
+						`<Blob><Name>db/a.sql.gz</Name><Properties><Last-Modified>Wed, 01 Jan 2020 00:00:00 GMT</Last-Modified></Properties></Blob>`+
+						`<Blob><Name>db/b.sql.gz</Name><Properties><Last-Modified>Wed, 01 Jan 2020 00:00:00 GMT</Last-Modified></Properties></Blob>`+
+						`</Blobs><NextMarker>page2</NextMarker></EnumerationResults>`)
+					return
+				}
+				fmt.Fprint(w, `<EnumerationResults><Blobs>`+
+					`<Blob><Name>db/c.sql.gz</Name><Properties><Last-Modified>Thu, 01 Jan 2099 00:00:00 GMT</Last-Modified></Properties></Blob>`+
+					`</Blobs><NextMarker/></EnumerationResults>`)
+
+			case r.Method == http.MethodDelete:
+				commits = append(commits, r)
+				w.WriteHeader(http.StatusAccepted)
+
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer server.Close()
+
+		storage, err := NewAzure(&config.UploadTarget{
+			Type:             "azure",
+			Endpoint:         server.URL + "/devstoreaccount1",
+			Container:        "backups",
+			SASToken:         "sv=2024-01-01&sig=abc",
+			Prefix:           "/db/",
+			BlockSizeMB:      1,
+			AccessTier:       "Archive",
+			ImmutabilityMode: "Locked",
+			ImmutabilityDays: 7,
+			Tags:             map[string]string{"env": "prod"},
+		})
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("Upload should stage blocks and commit them in order", func() {
+			tempDir, err := os.MkdirTemp("", "azure_storage_test")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(tempDir)
+
+			sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+			So(os.WriteFile(sourceFile, make([]byte, 2<<20+512), 0644), ShouldBeNil)
+
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			So(blocks, ShouldHaveLength, 3)
+			So(staged[blocks[0]], ShouldEqual, 1<<20)
+			So(staged[blocks[1]], ShouldEqual, 1<<20)
+			So(staged[blocks[2]], ShouldEqual, 512)
+
+			So(commits, ShouldHaveLength, 1)
+			commit := commits[0]
+			So(commit.URL.Path, ShouldEqual, "/devstoreaccount1/backups/db/backup.sql.gz")
+			So(commit.Header.Get("x-ms-access-tier"), ShouldEqual, "Archive")
+			So(commit.Header.Get("x-ms-immutability-policy-mode"), ShouldEqual, "Locked")
+			So(commit.Header.Get("x-ms-immutability-policy-until-date"), ShouldNotBeEmpty)
+			So(commit.Header.Get("x-ms-tags"), ShouldEqual, "env=prod")
+		})
+
+		Convey("List should follow continuation markers", func() {
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"a.sql.gz", "b.sql.gz", "c.sql.gz"})
+			So(markers, ShouldResemble, []string{"", "page2"})
+		})
+
+		Convey("GetOldFiles should check every page", func() {
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now())
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"a.sql.gz", "b.sql.gz"})
+		})
+
+		Convey("Delete should remove the prefixed blob", func() {
+			So(storage.Delete(ctx, "a.sql.gz"), ShouldBeNil)
+			So(commits, ShouldHaveLength, 1)
+			So(strings.HasSuffix(commits[0].URL.Path, "/backups/db/a.sql.gz"), ShouldBeTrue)
+		})
+	})
+}
+
+// TestAzureStorageIntegration runs against Azurite:
+//
+//	azurite-blob --location /tmp/azurite &
+//	export PHYLAX_TEST_AZURE_CONNECTION_STRING="DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;\
+//	AccountKey=Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==;\
+//	BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;"
+//	go test ./internal/adapter/storage -run Azure
+func TestAzureStorageIntegration(t *testing.T) {
+	connectionString := os.Getenv("PHYLAX_TEST_AZURE_CONNECTION_STRING")
+	if connectionString == "" {
+		t.Skip("PHYLAX_TEST_AZURE_CONNECTION_STRING not set")
+	}
+
+	Convey("Given an AzureStorage against Azurite", t, func() {
+		storage, err := NewAzure(&config.UploadTarget{
+			Type:             "azure",
+			ConnectionString: connectionString,
+			Container:        envOrDefault("PHYLAX_TEST_AZURE_CONTAINER", "phylax-test"),
+			Prefix:           "it/",
+		})
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		storage.client.Create(ctx, nil)
+
+		tempDir, err := os.MkdirTemp("", "azure_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, []byte("backup"), 0644), ShouldBeNil)
+
+		Convey("It should upload, list, age and delete blobs", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldContain, "backup.sql.gz")
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldContain, "backup.sql.gz")
+
+			So(storage.Delete(ctx, "backup.sql.gz"), ShouldBeNil)
+
+			files, err = storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldNotContain, "backup.sql.gz")
+		})
+	})
+}
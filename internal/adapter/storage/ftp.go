@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/semmidev/phylax/internal/config"
+)
+
+// ftpUploadAttempts bounds how often an interrupted upload is resumed.
+const ftpUploadAttempts = 3
+
+// FTPStorage implements the Storage interface for an FTP or FTPS server.
+// Transfers always use passive mode; like SFTPStorage, a new connection is
+// opened per operation.
+type FTPStorage struct {
+	addr      string
+	username  string
+	password  string
+	basePath  string
+	tlsMode   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+// NewFTP creates a new FTPStorage instance. tls selects "explicit" (AUTH
+// TLS, the default), "implicit" (FTPS on port 990) or "none".
+func NewFTP(cfg *config.UploadTarget) (*FTPStorage, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("ftp host is required")
+	}
+
+	s := &FTPStorage{
+		username: cfg.Username,
+		password: cfg.Password,
+		basePath: cfg.Path,
+		tlsMode:  strings.ToLower(cfg.TLS),
+		timeout:  30 * time.Second,
+	}
+	if s.username == "" {
+		s.username = "anonymous"
+	}
+	if s.tlsMode == "" {
+		s.tlsMode = "explicit"
+	}
+
+	port := cfg.Port
+	switch s.tlsMode {
+	case "explicit", "implicit":
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = cfg.Host
+		s.tlsConfig = tlsConfig
+		if port == 0 && s.tlsMode == "implicit" {
+			port = 990
+		}
+	case "none":
+	default:
+		return nil, fmt.Errorf("unsupported ftp tls mode: %s", cfg.TLS)
+	}
+	if port == 0 {
+		port = 21
+	}
+	s.addr = net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	return s, nil
+}
+
+// Upload stores the file under a temporary name, resuming with REST from the
+// bytes already on the server if the transfer is interrupted, and renames it
+// into place once the remote size matches.
+func (s *FTPStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	destPath := path.Join(s.basePath, remoteName)
+	tempPath := path.Join(s.basePath, tempUploadPrefix+remoteName)
+
+	var lastErr error
+	prepared := false
+	for attempt := 1; attempt <= ftpUploadAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		lastErr = s.withConn(ctx, func(c *ftp.ServerConn) error {
+			// Prepare on the first connection that succeeds, not on the
+			// first attempt, which may have failed to dial.
+			if !prepared {
+				s.mkdirAll(c)
+				// Never resume a leftover from an earlier, unrelated run.
+				_ = c.Delete(tempPath)
+				prepared = true
+			}
+			return s.storResumable(c, file, info.Size(), tempPath)
+		})
+		if lastErr == nil {
+			break
+		}
+	}
+	if lastErr != nil {
+		return fmt.Errorf("failed to upload: %w", lastErr)
+	}
+
+	return s.withConn(ctx, func(c *ftp.ServerConn) error {
+		if err := c.Rename(tempPath, destPath); err != nil {
+			// Some servers refuse to rename over an existing file.
+			_ = c.Delete(destPath)
+			if err := c.Rename(tempPath, destPath); err != nil {
+				return fmt.Errorf("failed to rename uploaded file: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// storResumable continues the upload from however many bytes the server
+// already holds and verifies the final size.
+func (s *FTPStorage) storResumable(c *ftp.ServerConn, file *os.File, size int64, tempPath string) error {
+	offset, err := c.FileSize(tempPath)
+	if err != nil || offset > size {
+		offset = 0
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek source: %w", err)
+	}
+	if err := c.StorFrom(tempPath, file, uint64(offset)); err != nil {
+		return err
+	}
+
+	remoteSize, err := c.FileSize(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to verify upload size: %w", err)
+	}
+	if remoteSize != size {
+		return fmt.Errorf("upload incomplete: %d of %d bytes", remoteSize, size)
+	}
+
+	return nil
+}
+
+func (s *FTPStorage) List(ctx context.Context) ([]string, error) {
+	entries, err := s.readDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name)
+	}
+	return files, nil
+}
+
+func (s *FTPStorage) Delete(ctx context.Context, remoteName string) error {
+	return s.withConn(ctx, func(c *ftp.ServerConn) error {
+		if err := c.Delete(path.Join(s.basePath, remoteName)); err != nil {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetOldFiles returns files modified before cutoffTime.
+func (s *FTPStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	entries, err := s.readDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldFiles []string
+	for _, entry := range entries {
+		if entry.Time.Before(cutoffTime) {
+			oldFiles = append(oldFiles, entry.Name)
+		}
+	}
+	return oldFiles, nil
+}
+
+// readDir lists regular files in the remote directory, skipping in-progress
+// uploads. Modification times come from MLSD when the server supports it.
+// Plain LIST output only carries minute precision for recent files and day
+// precision for older ones, so MDTM is used instead where available.
+func (s *FTPStorage) readDir(ctx context.Context) ([]*ftp.Entry, error) {
+	var files []*ftp.Entry
+	err := s.withConn(ctx, func(c *ftp.ServerConn) error {
+		entries, err := c.List(s.basePath)
+		if err != nil {
+			return fmt.Errorf("failed to list remote directory: %w", err)
+		}
+
+		precise := c.IsTimePreciseInList() || !c.IsGetTimeSupported()
+		for _, entry := range entries {
+			if entry.Type != ftp.EntryTypeFile || strings.HasPrefix(entry.Name, tempUploadPrefix) {
+				continue
+			}
+			if !precise {
+				if t, err := c.GetTime(path.Join(s.basePath, entry.Name)); err == nil {
+					entry.Time = t
+				}
+			}
+			files = append(files, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// mkdirAll creates each directory along the base path. Errors are ignored
+// because servers report existing directories in different ways; a
+// genuinely missing directory surfaces when the upload itself fails.
+func (s *FTPStorage) mkdirAll(c *ftp.ServerConn) {
+	var dir string
+	if strings.HasPrefix(s.basePath, "/") {
+		dir = "/"
+	}
+	for _, segment := range strings.Split(strings.Trim(s.basePath, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		dir = path.Join(dir, segment)
+		_ = c.MakeDir(dir)
+	}
+}
+
+// withConn dials, logs in, runs fn and closes the connection.
+func (s *FTPStorage) withConn(ctx context.Context, fn func(*ftp.ServerConn) error) error {
+	opts := []ftp.DialOption{
+		ftp.DialWithContext(ctx),
+		ftp.DialWithTimeout(s.timeout),
+	}
+	switch s.tlsMode {
+	case "explicit":
+		opts = append(opts, ftp.DialWithExplicitTLS(s.tlsConfig))
+	case "implicit":
+		opts = append(opts, ftp.DialWithTLS(s.tlsConfig))
+	}
+
+	c, err := ftp.Dial(s.addr, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", s.addr, err)
+	}
+	defer c.Quit()
+
+	if err := c.Login(s.username, s.password); err != nil {
+		return fmt.Errorf("ftp login failed: %w", err)
+	}
+
+	return fn(c)
+}
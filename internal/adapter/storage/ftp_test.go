@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeFTPServer is a minimal passive-mode FTP server backed by a local
+// directory. It can advertise MLSD or only LIST+MDTM, and can cut the first
+// STOR short to exercise resumption.
+type fakeFTPServer struct {
+	listener net.Listener
+	root     string
+	mlsd     bool
+
+	mu         sync.Mutex
+	dropAfter  int64
+	restOffset []int64
+	// refuse turns away this many connections before serving any.
+	refuse int
+}
+
+func newFakeFTPServer(t *testing.T, root string, mlsd bool) *fakeFTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	s := &fakeFTPServer{listener: listener, root: root, mlsd: mlsd}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeFTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeFTPServer) local(p string) string {
+	return filepath.Join(s.root, path.Clean("/"+p))
+}
+
+func (s *fakeFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+
+	var (
+		data   net.Listener
+		rest   int64
+		rnfr   string
+		authOK bool
+	)
+	openData := func() (net.Conn, error) {
+		defer data.Close()
+		return data.Accept()
+	}
+
+	s.mu.Lock()
+	refused := s.refuse > 0
+	if refused {
+		s.refuse--
+	}
+	s.mu.Unlock()
+	if refused {
+		reply("421 too many connections")
+		return
+	}
+
+	reply("220 fake ftp ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		switch strings.ToUpper(cmd) {
+		case "FEAT":
+			if s.mlsd {
+				reply("211-Features:\r\n MLST type*;size*;modify*;\r\n SIZE\r\n REST STREAM\r\n EPSV\r\n211 End")
+			} else {
+				reply("211-Features:\r\n MDTM\r\n SIZE\r\n REST STREAM\r\n EPSV\r\n211 End")
+			}
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			authOK = arg == "secret"
+			if !authOK {
+				reply("530 login incorrect")
+				continue
+			}
+			reply("230 logged in")
+		case "TYPE", "OPTS":
+			reply("200 ok")
+		case "EPSV":
+			data, _ = net.Listen("tcp", "127.0.0.1:0")
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "MKD":
+			if err := os.Mkdir(s.local(arg), 0755); err != nil {
+				reply("550 exists")
+				continue
+			}
+			reply("257 created")
+		case "DELE":
+			if err := os.Remove(s.local(arg)); err != nil {
+				reply("550 no such file")
+				continue
+			}
+			reply("250 deleted")
+		case "SIZE":
+			info, err := os.Stat(s.local(arg))
+			if err != nil {
+				reply("550 no such file")
+				continue
+			}
+			reply("213 %d", info.Size())
+		case "MDTM":
+			info, err := os.Stat(s.local(arg))
+			if err != nil {
+				reply("550 no such file")
+				continue
+			}
+			reply("213 %s", info.ModTime().UTC().Format("20060102150405"))
+		case "REST":
+			rest, _ = strconv.ParseInt(arg, 10, 64)
+			s.mu.Lock()
+			s.restOffset = append(s.restOffset, rest)
+			s.mu.Unlock()
+			reply("350 restarting")
+		case "STOR":
+			reply("150 opening data connection")
+			dc, err := openData()
+			if err != nil {
+				reply("425 no data connection")
+				continue
+			}
+			flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			if rest > 0 {
+				flags = os.O_WRONLY | os.O_APPEND
+			}
+			f, _ := os.OpenFile(s.local(arg), flags, 0644)
+
+			s.mu.Lock()
+			drop := s.dropAfter
+			s.dropAfter = 0
+			s.mu.Unlock()
+
+			if drop > 0 {
+				io.CopyN(f, dc, drop)
+				f.Close()
+				dc.Close()
+				reply("426 connection closed; transfer aborted")
+				return
+			}
+			io.Copy(f, dc)
+			f.Close()
+			dc.Close()
+			rest = 0
+			reply("226 transfer complete")
+		case "RNFR":
+			rnfr = arg
+			reply("350 ready for RNTO")
+		case "RNTO":
+			if _, err := os.Stat(s.local(arg)); err == nil {
+				reply("553 file exists")
+				continue
+			}
+			if err := os.Rename(s.local(rnfr), s.local(arg)); err != nil {
+				reply("550 rename failed")
+				continue
+			}
+			reply("250 renamed")
+		case "MLSD", "LIST":
+			entries, err := os.ReadDir(s.local(arg))
+			if err != nil {
+				reply("550 no such directory")
+				continue
+			}
+			reply("150 listing")
+			dc, err := openData()
+			if err != nil {
+				reply("425 no data connection")
+				continue
+			}
+			for _, e := range entries {
+				info, _ := e.Info()
+				switch {
+				case cmd == "MLSD" && e.IsDir():
+					fmt.Fprintf(dc, "type=dir;modify=%s; %s\r\n", info.ModTime().UTC().Format("20060102150405"), e.Name())
+				case cmd == "MLSD":
+					fmt.Fprintf(dc, "type=file;size=%d;modify=%s; %s\r\n", info.Size(), info.ModTime().UTC().Format("20060102150405"), e.Name())
+				case e.IsDir():
+					fmt.Fprintf(dc, "drwxr-xr-x 1 ftp ftp 0 %s %s\r\n", info.ModTime().UTC().Format("Jan 02 2006"), e.Name())
+				default:
+					fmt.Fprintf(dc, "-rw-r--r-- 1 ftp ftp %d %s %s\r\n", info.Size(), info.ModTime().UTC().Format("Jan 02 2006"), e.Name())
+				}
+			}
+			dc.Close()
+			reply("226 listing complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func TestFTPStorage(t *testing.T) {
+	for _, mlsd := range []bool{true, false} {
+		Convey(fmt.Sprintf("Given an FTPStorage against a fake FTP server (MLSD: %v)", mlsd), t, func() {
+			root, err := os.MkdirTemp("", "ftp_storage_test")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(root)
+
+			server := newFakeFTPServer(t, root, mlsd)
+			defer server.listener.Close()
+
+			cfg := &config.UploadTarget{
+				Type:     "ftp",
+				Host:     "127.0.0.1",
+				Port:     server.port(),
+				Username: "backup",
+				Password: "secret",
+				TLS:      "none",
+				Path:     "/backups/mysql",
+			}
+			remoteDir := filepath.Join(root, "backups", "mysql")
+
+			sourceDir, err := os.MkdirTemp("", "ftp_source")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(sourceDir)
+
+			sourceFile := filepath.Join(sourceDir, "backup.sql.gz")
+			content := strings.Repeat("backup content ", 10000)
+			So(os.WriteFile(sourceFile, []byte(content), 0644), ShouldBeNil)
+
+			storage, err := NewFTP(cfg)
+			So(err, ShouldBeNil)
+			ctx := context.Background()
+
+			Convey("Upload should create directories and rename into place", func() {
+				So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+				uploaded, err := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+				So(err, ShouldBeNil)
+				So(string(uploaded), ShouldEqual, content)
+
+				_, err = os.Stat(filepath.Join(remoteDir, tempUploadPrefix+"backup.sql.gz"))
+				So(os.IsNotExist(err), ShouldBeTrue)
+
+				Convey("And overwrite an existing file", func() {
+					So(os.WriteFile(sourceFile, []byte("newer"), 0644), ShouldBeNil)
+					So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+					uploaded, _ := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+					So(string(uploaded), ShouldEqual, "newer")
+				})
+			})
+
+			Convey("An interrupted upload should resume with REST", func() {
+				server.dropAfter = 4096
+
+				So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+				uploaded, err := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+				So(err, ShouldBeNil)
+				So(string(uploaded), ShouldEqual, content)
+				So(server.restOffset, ShouldResemble, []int64{4096})
+			})
+
+			Convey("A failed first connection should still discard a stale partial upload", func() {
+				// Same size as the new upload, so a resume would pass the
+				// size check with the wrong content.
+				So(os.MkdirAll(remoteDir, 0755), ShouldBeNil)
+				stale := strings.Repeat("x", len(content))
+				So(os.WriteFile(filepath.Join(remoteDir, tempUploadPrefix+"backup.sql.gz"), []byte(stale), 0644), ShouldBeNil)
+				server.refuse = 1
+
+				So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+				uploaded, err := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+				So(err, ShouldBeNil)
+				So(string(uploaded), ShouldEqual, content)
+			})
+
+			Convey("List, GetOldFiles and Delete should skip directories and partial uploads", func() {
+				So(os.MkdirAll(filepath.Join(remoteDir, "subdir"), 0755), ShouldBeNil)
+				os.WriteFile(filepath.Join(remoteDir, "old.sql.gz"), []byte("old"), 0644)
+				os.WriteFile(filepath.Join(remoteDir, "new.sql.gz"), []byte("new"), 0644)
+				os.WriteFile(filepath.Join(remoteDir, tempUploadPrefix+"partial.sql.gz"), []byte("x"), 0644)
+				oldTime := time.Now().Add(-10 * 24 * time.Hour)
+				os.Chtimes(filepath.Join(remoteDir, "old.sql.gz"), oldTime, oldTime)
+
+				// Only a minute ago: LIST shows today's date, so this relies on
+				// MLSD or MDTM for precision.
+				recent := time.Now().Add(-time.Minute)
+				os.Chtimes(filepath.Join(remoteDir, "new.sql.gz"), recent, recent)
+
+				files, err := storage.List(ctx)
+				So(err, ShouldBeNil)
+				So(files, ShouldResemble, []string{"new.sql.gz", "old.sql.gz"})
+
+				oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(-7*24*time.Hour))
+				So(err, ShouldBeNil)
+				So(oldFiles, ShouldResemble, []string{"old.sql.gz"})
+
+				oldFiles, err = storage.GetOldFiles(ctx, time.Now().Add(-30*time.Second))
+				So(err, ShouldBeNil)
+				So(oldFiles, ShouldResemble, []string{"new.sql.gz", "old.sql.gz"})
+
+				So(storage.Delete(ctx, "old.sql.gz"), ShouldBeNil)
+				_, err = os.Stat(filepath.Join(remoteDir, "old.sql.gz"))
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+
+			Convey("When the password is wrong", func() {
+				cfg.Password = "wrong"
+				storage, err := NewFTP(cfg)
+				So(err, ShouldBeNil)
+
+				_, err = storage.List(ctx)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "ftp login failed")
+				})
+			})
+		})
+	}
+
+	Convey("Given FTP TLS settings", t, func() {
+		cfg := &config.UploadTarget{Type: "ftp", Host: "ftp.example.com"}
+
+		Convey("Explicit TLS should be the default", func() {
+			storage, err := NewFTP(cfg)
+			So(err, ShouldBeNil)
+			So(storage.tlsMode, ShouldEqual, "explicit")
+			So(storage.tlsConfig.ServerName, ShouldEqual, "ftp.example.com")
+			So(storage.addr, ShouldEqual, "ftp.example.com:21")
+		})
+
+		Convey("Implicit TLS should default to port 990", func() {
+			cfg.TLS = "implicit"
+			storage, err := NewFTP(cfg)
+			So(err, ShouldBeNil)
+			So(storage.addr, ShouldEqual, "ftp.example.com:990")
+		})
+
+		Convey("An unknown mode should return error", func() {
+			cfg.TLS = "ssl3"
+			_, err := NewFTP(cfg)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/semmidev/phylax/internal/config"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// defaultGCSChunkSize is the size of each resumable upload request. An
+// interrupted chunk is retried on its own instead of restarting the upload.
+const defaultGCSChunkSize = 16 << 20
+
+var gcsStorageClasses = []string{
+	"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE",
+	"MULTI_REGIONAL", "REGIONAL", "DURABLE_REDUCED_AVAILABILITY",
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// GCSStorage implements the Storage interface for a Google Cloud Storage
+// bucket.
+type GCSStorage struct {
+	client       *storage.Client
+	bucket       *storage.BucketHandle
+	prefix       string
+	chunkSize    int
+	storageClass string
+}
+
+// NewGCS creates a new GCSStorage instance. credentials_file selects a
+// service-account JSON key; without it Application Default Credentials are
+// used. A custom endpoint selects an emulator such as fake-gcs-server.
+func NewGCS(ctx context.Context, cfg *config.UploadTarget) (*GCSStorage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("gcs bucket is required")
+	}
+
+	storageClass := strings.ToUpper(cfg.StorageClass)
+	if storageClass != "" && !slices.Contains(gcsStorageClasses, storageClass) {
+		return nil, fmt.Errorf("unsupported storage_class: %s", cfg.StorageClass)
+	}
+
+	var opts []option.ClientOption
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	if cfg.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.Endpoint))
+		if cfg.CredentialsFile == "" {
+			opts = append(opts, option.WithoutAuthentication())
+		}
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	chunkSize := defaultGCSChunkSize
+	if cfg.BlockSizeMB > 0 {
+		chunkSize = cfg.BlockSizeMB << 20
+	}
+
+	return &GCSStorage{
+		client:       client,
+		bucket:       client.Bucket(cfg.Bucket),
+		prefix:       strings.Trim(cfg.Prefix, "/"),
+		chunkSize:    chunkSize,
+		storageClass: storageClass,
+	}, nil
+}
+
+// Upload streams the file as a resumable upload. The CRC32C of the local file
+// is sent with the final chunk so GCS rejects corrupted uploads, and the
+// checksum of the stored object is verified once more afterwards.
+func (g *GCSStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	checksum, err := fileCRC32C(file)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := g.bucket.Object(g.key(remoteName)).NewWriter(ctx)
+	w.ChunkSize = g.chunkSize
+	w.StorageClass = g.storageClass
+	w.CRC32C = checksum
+	w.SendCRC32C = true
+
+	if _, err := io.Copy(w, file); err != nil {
+		// Cancelling the context aborts the upload instead of finalizing it.
+		cancel()
+		w.Close()
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+
+	if attrs := w.Attrs(); attrs != nil && attrs.CRC32C != checksum {
+		return fmt.Errorf("crc32c mismatch for %s: local %08x, remote %08x", remoteName, checksum, attrs.CRC32C)
+	}
+
+	return nil
+}
+
+// List returns all objects under the prefix
+func (g *GCSStorage) List(ctx context.Context) ([]string, error) {
+	var files []string
+	err := g.listObjects(ctx, func(attrs *storage.ObjectAttrs) {
+		files = append(files, g.name(attrs.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Delete removes an object
+func (g *GCSStorage) Delete(ctx context.Context, remoteName string) error {
+	if err := g.bucket.Object(g.key(remoteName)).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete from GCS: %w", err)
+	}
+
+	return nil
+}
+
+// GetOldFiles returns objects created before cutoffTime. Objects that a
+// bucket retention policy, object retention or hold still protects are left
+// out, since deleting them would only fail.
+func (g *GCSStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	now := time.Now()
+
+	var oldFiles []string
+	err := g.listObjects(ctx, func(attrs *storage.ObjectAttrs) {
+		if attrs.Created.Before(cutoffTime) && !gcsRetained(attrs, now) {
+			oldFiles = append(oldFiles, g.name(attrs.Name))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return oldFiles, nil
+}
+
+// listObjects calls fn for every object under the prefix; the iterator
+// follows page tokens.
+func (g *GCSStorage) listObjects(ctx context.Context, fn func(*storage.ObjectAttrs)) error {
+	query := &storage.Query{}
+	if g.prefix != "" {
+		query.Prefix = g.prefix + "/"
+	}
+
+	it := g.bucket.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list GCS objects: %w", err)
+		}
+		fn(attrs)
+	}
+}
+
+func (g *GCSStorage) key(remoteName string) string {
+	return path.Join(g.prefix, remoteName)
+}
+
+func (g *GCSStorage) name(key string) string {
+	if g.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, g.prefix+"/")
+}
+
+// gcsRetained reports whether an object cannot be deleted yet.
+func gcsRetained(attrs *storage.ObjectAttrs, now time.Time) bool {
+	if attrs.TemporaryHold || attrs.EventBasedHold {
+		return true
+	}
+	if attrs.RetentionExpirationTime.After(now) {
+		return true
+	}
+	return attrs.Retention != nil && attrs.Retention.RetainUntil.After(now)
+}
+
+// fileCRC32C computes the Castagnoli CRC32 of a file and rewinds it.
+func fileCRC32C(file *os.File) (uint32, error) {
+	hash := crc32.New(crc32cTable)
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, fmt.Errorf("failed to checksum file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return hash.Sum32(), nil
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeGCS implements enough of the GCS JSON API for resumable uploads,
+// paginated listings and deletes.
+type fakeGCS struct {
+	mu        sync.Mutex
+	uploads   map[string][]byte
+	objects   map[string][]byte
+	metadata  map[string]map[string]any
+	chunks    int
+	corrupt   bool
+	pageToken []string
+	deleted   []string
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Get("uploadType") == "resumable":
+		var meta map[string]any
+		json.NewDecoder(r.Body).Decode(&meta)
+		name := meta["name"].(string)
+		f.metadata[name] = meta
+		f.uploads[name] = nil
+		w.Header().Set("Location", "http://"+r.Host+"/resumable?name="+name)
+		w.WriteHeader(http.StatusOK)
+
+	case r.URL.Path == "/resumable":
+		name := q.Get("name")
+		body, _ := io.ReadAll(r.Body)
+		f.uploads[name] = append(f.uploads[name], body...)
+		f.chunks++
+
+		if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+			// Like GCS, report "resume incomplete" without a real 308 so the
+			// HTTP client does not treat it as a redirect.
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(f.uploads[name])-1))
+			w.Header().Set("X-Http-Status-Code-Override", "308")
+			return
+		}
+
+		data := f.uploads[name]
+		if f.corrupt {
+			data = append([]byte{}, data...)
+			data[0] ^= 0xff
+		}
+		f.objects[name] = data
+
+		sum := make([]byte, 4)
+		binary.BigEndian.PutUint32(sum, crc32.Checksum(data, crc32cTable))
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":         name,
+			"bucket":       "backups",
+			"size":         fmt.Sprint(len(data)),
+			"crc32c":       base64.StdEncoding.EncodeToString(sum),
+			"storageClass": f.metadata[name]["storageClass"],
+		})
+
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/b/backups/o"):
+		f.pageToken = append(f.pageToken, q.Get("pageToken"))
+		if q.Get("pageToken") == "" {
+			fmt.Fprint(w, `{"nextPageToken":"page2","items":[`+
+				`{"name":"db/a.sql.gz","timeCreated":"2020-01-01T00:00:00Z"},`+
+				`{"name":"db/held.sql.gz","timeCreated":"2020-01-01T00:00:00Z","temporaryHold":true},`+
+				`{"name":"db/locked.sql.gz","timeCreated":"2020-01-01T00:00:00Z","retentionExpirationTime":"2099-01-01T00:00:00Z"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"items":[`+
+			`{"name":"db/b.sql.gz","timeCreated":"2020-01-01T00:00:00Z","retention":{"mode":"Locked","retainUntilTime":"2000-01-01T00:00:00Z"}},`+
+			`{"name":"db/c.sql.gz","timeCreated":"2099-01-01T00:00:00Z"}]}`)
+
+	case r.Method == http.MethodDelete:
+		f.deleted = append(f.deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, r.Method+" "+r.URL.String(), http.StatusBadRequest)
+	}
+}
+
+func TestGCSStorage(t *testing.T) {
+	Convey("Given a GCSStorage against a fake GCS server", t, func() {
+		fake := &fakeGCS{
+			uploads:  map[string][]byte{},
+			objects:  map[string][]byte{},
+			metadata: map[string]map[string]any{},
+		}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		storage, err := NewGCS(context.Background(), &config.UploadTarget{
+			Type:         "gcs",
+			Endpoint:     server.URL + "/storage/v1/",
+			Bucket:       "backups",
+			Prefix:       "/db/",
+			StorageClass: "nearline",
+			BlockSizeMB:  1,
+		})
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		tempDir, err := os.MkdirTemp("", "gcs_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		content := []byte(strings.Repeat("backup data ", 250000))
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, content, 0644), ShouldBeNil)
+
+		Convey("Upload should send the file in resumable chunks", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			So(fake.chunks, ShouldEqual, 3)
+			So(fake.objects["db/backup.sql.gz"], ShouldResemble, content)
+			So(fake.metadata["db/backup.sql.gz"]["storageClass"], ShouldEqual, "NEARLINE")
+			So(fake.metadata["db/backup.sql.gz"]["crc32c"], ShouldNotBeEmpty)
+		})
+
+		Convey("Upload should fail when the stored checksum differs", func() {
+			fake.corrupt = true
+			err := storage.Upload(ctx, sourceFile, "backup.sql.gz")
+
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "crc32c mismatch")
+		})
+
+		Convey("List should follow page tokens", func() {
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"a.sql.gz", "held.sql.gz", "locked.sql.gz", "b.sql.gz", "c.sql.gz"})
+			So(fake.pageToken, ShouldResemble, []string{"", "page2"})
+		})
+
+		Convey("GetOldFiles should skip objects under retention or hold", func() {
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now())
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"a.sql.gz", "b.sql.gz"})
+		})
+
+		Convey("Delete should remove the prefixed object", func() {
+			So(storage.Delete(ctx, "a.sql.gz"), ShouldBeNil)
+			So(fake.deleted, ShouldHaveLength, 1)
+			So(fake.deleted[0], ShouldEndWith, "/b/backups/o/db/a.sql.gz")
+		})
+	})
+
+	Convey("Given an unknown storage class", t, func() {
+		_, err := NewGCS(context.Background(), &config.UploadTarget{
+			Type:         "gcs",
+			Bucket:       "backups",
+			StorageClass: "FROZEN",
+		})
+
+		Convey("It should return error", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unsupported storage_class")
+		})
+	})
+}
+
+// TestGCSStorageIntegration runs against fake-gcs-server:
+//
+//	fake-gcs-server -scheme http -port 4443 -public-host 127.0.0.1:4443 &
+//	PHYLAX_TEST_GCS_ENDPOINT=http://127.0.0.1:4443/storage/v1/ go test ./internal/adapter/storage -run GCS
+func TestGCSStorageIntegration(t *testing.T) {
+	endpoint := os.Getenv("PHYLAX_TEST_GCS_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("PHYLAX_TEST_GCS_ENDPOINT not set")
+	}
+
+	Convey("Given a GCSStorage against a GCS emulator", t, func() {
+		ctx := context.Background()
+		storage, err := NewGCS(ctx, &config.UploadTarget{
+			Type:     "gcs",
+			Endpoint: endpoint,
+			Bucket:   envOrDefault("PHYLAX_TEST_GCS_BUCKET", "phylax-test"),
+			Prefix:   "it/",
+		})
+		So(err, ShouldBeNil)
+
+		storage.bucket.Create(ctx, "phylax", nil)
+
+		tempDir, err := os.MkdirTemp("", "gcs_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, []byte("backup"), 0644), ShouldBeNil)
+
+		Convey("It should upload, list, age and delete objects", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldContain, "backup.sql.gz")
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldContain, "backup.sql.gz")
+
+			So(storage.Delete(ctx, "backup.sql.gz"), ShouldBeNil)
+
+			files, err = storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldNotContain, "backup.sql.gz")
+		})
+	})
+}
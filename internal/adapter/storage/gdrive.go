@@ -4,49 +4,66 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/semmidev/phylax/internal/config"
 	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
+// DefaultGDriveClientSecret is the OAuth client secret used when a gdrive
+// target does not set credentials_file.
+const DefaultGDriveClientSecret = "client_secret.json"
+
+// defaultGDriveChunkSize is the size of each resumable upload request.
+const defaultGDriveChunkSize = 16 << 20
+
 // GDriveStorage implements the Storage interface for Google Drive.
 type GDriveStorage struct {
-	service  *drive.Service
-	folderID string
-	logger   *logger.Logger
+	service   *drive.Service
+	folderID  string
+	driveID   string
+	chunkSize int
+	index     *fileIndex
+	logger    *logger.Logger
 }
 
 // NewGDrive creates a new GDriveStorage instance.
 // The cfg.FolderID must be non-empty; otherwise, an error is returned.
-func NewGDrive(ctx context.Context, cfg *config.UploadTarget, oauthConfig *oauth2.Config, logger *logger.Logger) (*GDriveStorage, error) {
+// The IDs of uploaded files are recorded in an index under dataDir so they
+// can be deleted by ID even when several files share a name. OAuth targets
+// read their token from tokens, which may be authorized after startup.
+func NewGDrive(ctx context.Context, cfg *config.UploadTarget, dataDir string, tokens *tokenstore.Store, logger *logger.Logger) (*GDriveStorage, error) {
 	if cfg == nil {
 		return nil, errors.New("configuration cannot be nil")
 	}
-	if oauthConfig == nil {
-		return nil, errors.New("oauth config cannot be nil")
-	}
 	if logger == nil {
 		return nil, errors.New("logger cannot be nil")
 	}
-	if cfg.RefreshToken == "" {
-		return nil, errors.New("refresh token is required")
-	}
 	if cfg.FolderID == "" {
 		return nil, errors.New("folder ID is required and cannot be empty")
 	}
 
-	// Create token source with refresh token
-	token := &oauth2.Token{
-		RefreshToken: cfg.RefreshToken,
-		TokenType:    "Bearer",
+	tokenSource, err := gdriveTokenSource(ctx, cfg, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Auth != "service_account" && tokens != nil {
+		if _, ok, err := tokens.Get(GDriveTokenName(cfg)); err == nil && !ok {
+			logger.Warnf("Google Drive folder %s is not authorized yet; run `phylax auth gdrive` or open /auth/google/drive", cfg.FolderID)
+		}
 	}
-	tokenSource := oauthConfig.TokenSource(ctx, token)
 
 	// Initialize Google Drive service
 	service, err := drive.NewService(ctx, option.WithTokenSource(tokenSource))
@@ -55,15 +72,125 @@ func NewGDrive(ctx context.Context, cfg *config.UploadTarget, oauthConfig *oauth
 		return nil, fmt.Errorf("failed to create drive service: %w", err)
 	}
 
+	storage, err := newGDriveStorage(service, cfg, dataDir, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Infof("Initialized Google Drive storage with folder ID: %s", cfg.FolderID)
+	return storage, nil
+}
+
+// GDriveTokenName is the key under which the OAuth token of a gdrive target
+// is kept in the token store.
+func GDriveTokenName(cfg *config.UploadTarget) string {
+	return "gdrive:" + cfg.FolderID
+}
+
+// GDriveOAuthConfig reads the OAuth client secret of a gdrive target from
+// its credentials_file.
+func GDriveOAuthConfig(cfg *config.UploadTarget) (*oauth2.Config, error) {
+	credentialsFile := cfg.CredentialsFile
+	if credentialsFile == "" {
+		credentialsFile = DefaultGDriveClientSecret
+	}
+
+	b, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	oauthConfig, err := google.ConfigFromJSON(b, drive.DriveFileScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client secret: %w", err)
+	}
+	if oauthConfig.Endpoint.DeviceAuthURL == "" {
+		oauthConfig.Endpoint.DeviceAuthURL = google.Endpoint.DeviceAuthURL
+	}
+	return oauthConfig, nil
+}
+
+// gdriveTokenSource builds the credentials for a gdrive target from its
+// credentials_file. With auth "oauth" (the default) the file is an OAuth
+// client secret and the token comes from tokens; a refresh_token in the
+// config is imported into the store on first use. With auth
+// "service_account" the file is a service account key; impersonate_user
+// makes the account act as a Workspace user through domain-wide delegation.
+func gdriveTokenSource(ctx context.Context, cfg *config.UploadTarget, tokens *tokenstore.Store) (oauth2.TokenSource, error) {
+	switch cfg.Auth {
+	case "", "oauth":
+		oauthConfig, err := GDriveOAuthConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if tokens == nil {
+			if cfg.RefreshToken == "" {
+				return nil, errors.New("refresh token is required")
+			}
+			return oauthConfig.TokenSource(ctx, &oauth2.Token{RefreshToken: cfg.RefreshToken, TokenType: "Bearer"}), nil
+		}
+
+		name := GDriveTokenName(cfg)
+		if cfg.RefreshToken != "" {
+			if _, ok, err := tokens.Get(name); err != nil {
+				return nil, err
+			} else if !ok {
+				if err := tokens.Put(name, &oauth2.Token{RefreshToken: cfg.RefreshToken, TokenType: "Bearer"}); err != nil {
+					return nil, fmt.Errorf("failed to import refresh token: %w", err)
+				}
+			}
+		}
+		return tokens.TokenSource(ctx, oauthConfig, name), nil
+
+	case "service_account":
+		credentialsFile := cfg.CredentialsFile
+		if credentialsFile == "" {
+			return nil, errors.New("credentials file is required for service account auth")
+		}
+		b, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		jwtConfig, err := google.JWTConfigFromJSON(b, drive.DriveFileScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service account key: %w", err)
+		}
+		jwtConfig.Subject = cfg.ImpersonateUser
+		return jwtConfig.TokenSource(ctx), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported gdrive auth %q", cfg.Auth)
+	}
+}
+
+func newGDriveStorage(service *drive.Service, cfg *config.UploadTarget, dataDir string, logger *logger.Logger) (*GDriveStorage, error) {
+	indexFile := cfg.IndexFile
+	if indexFile == "" {
+		indexFile = filepath.Join(dataDir, fmt.Sprintf("gdrive_%s.json", cfg.FolderID))
+	}
+	index, err := newFileIndex(indexFile)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := defaultGDriveChunkSize
+	if cfg.BlockSizeMB > 0 {
+		chunkSize = cfg.BlockSizeMB << 20
+	}
+
 	return &GDriveStorage{
-		service:  service,
-		folderID: cfg.FolderID,
-		logger:   logger,
+		service:   service,
+		folderID:  cfg.FolderID,
+		driveID:   cfg.DriveID,
+		chunkSize: chunkSize,
+		index:     index,
+		logger:    logger,
 	}, nil
 }
 
-// Upload uploads a file from localPath to Google Drive with the specified remoteName.
+// Upload uploads a file from localPath to Google Drive with the specified
+// remoteName, using a chunked resumable upload.
 func (g *GDriveStorage) Upload(ctx context.Context, localPath, remoteName string) error {
 	if remoteName == "" {
 		return errors.New("remote file name cannot be empty")
@@ -81,13 +208,26 @@ func (g *GDriveStorage) Upload(ctx context.Context, localPath, remoteName string
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
 	fileMetadata := &drive.File{
 		Name:    remoteName,
 		Parents: []string{g.folderID},
 	}
 
-	_, err = g.service.Files.Create(fileMetadata).
-		Media(file).
+	created, err := g.service.Files.Create(fileMetadata).
+		Media(file, googleapi.ChunkSize(g.chunkSize)).
+		ProgressUpdater(func(current, _ int64) {
+			if info.Size() > 0 {
+				g.logger.Infof("Uploading %s to Google Drive: %d%% (%d/%d bytes)",
+					remoteName, current*100/info.Size(), current, info.Size())
+			}
+		}).
+		SupportsAllDrives(true).
+		Fields("id, name, createdTime").
 		Context(ctx).
 		Do()
 	if err != nil {
@@ -95,7 +235,21 @@ func (g *GDriveStorage) Upload(ctx context.Context, localPath, remoteName string
 		return fmt.Errorf("failed to upload to Google Drive: %w", err)
 	}
 
-	g.logger.Infof("Successfully uploaded %s to Google Drive", remoteName)
+	// Append rather than replace, so an earlier file with the same name can
+	// still be deleted by its own ID.
+	entry, _, err := g.index.get(remoteName)
+	if err != nil {
+		return err
+	}
+	entry.Name = remoteName
+	entry.Size = info.Size()
+	entry.CreatedAt = time.Now()
+	entry.Refs = append(entry.Refs, created.Id)
+	if err := g.index.put(entry); err != nil {
+		return fmt.Errorf("failed to record upload in index: %w", err)
+	}
+
+	g.logger.Infof("Successfully uploaded %s to Google Drive (id: %s)", remoteName, created.Id)
 	return nil
 }
 
@@ -104,21 +258,15 @@ func (g *GDriveStorage) List(ctx context.Context) ([]string, error) {
 	query := fmt.Sprintf("'%s' in parents and trashed=false", sanitizeQuery(g.folderID))
 	g.logger.Infof("Listing files in Google Drive folder %s", g.folderID)
 
-	fileList, err := g.service.Files.List().
-		Q(query).
-		Fields("files(id, name, createdTime)").
-		Context(ctx).
-		Do()
-	if err != nil {
-		g.logger.Errorf("Failed to list files in folder %s: %v", g.folderID, err)
-		return nil, fmt.Errorf("failed to list files: %w", err)
-	}
-
 	var files []string
-	for _, file := range fileList.Files {
+	err := g.listFiles(ctx, query, func(file *drive.File) {
 		if file.Name != "" {
 			files = append(files, file.Name)
 		}
+	})
+	if err != nil {
+		g.logger.Errorf("Failed to list files in folder %s: %v", g.folderID, err)
+		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
 
 	g.logger.Infof("Found %d files in folder %s", len(files), g.folderID)
@@ -126,6 +274,8 @@ func (g *GDriveStorage) List(ctx context.Context) ([]string, error) {
 }
 
 // Delete removes a file with the specified remoteName from Google Drive.
+// The oldest file ID recorded for the name at upload time is used; files
+// uploaded before the index existed are looked up by name, oldest first.
 func (g *GDriveStorage) Delete(ctx context.Context, remoteName string) error {
 	if remoteName == "" {
 		return errors.New("remote file name cannot be empty")
@@ -133,61 +283,113 @@ func (g *GDriveStorage) Delete(ctx context.Context, remoteName string) error {
 
 	g.logger.Infof("Deleting file %s from Google Drive folder %s", remoteName, g.folderID)
 
-	query := fmt.Sprintf("'%s' in parents and name='%s' and trashed=false",
-		sanitizeQuery(g.folderID), sanitizeQuery(remoteName))
-
-	fileList, err := g.service.Files.List().
-		Q(query).
-		Fields("files(id)").
-		Context(ctx).
-		Do()
+	entry, ok, err := g.index.get(remoteName)
 	if err != nil {
-		g.logger.Errorf("Failed to find file %s in folder %s: %v", remoteName, g.folderID, err)
-		return fmt.Errorf("failed to find file: %w", err)
+		return err
 	}
 
-	if len(fileList.Files) == 0 {
-		g.logger.Warnf("File %s not found in folder %s", remoteName, g.folderID)
-		return fmt.Errorf("file not found: %s", remoteName)
+	var fileID string
+	if ok && len(entry.Refs) > 0 {
+		fileID = entry.Refs[0]
+	} else {
+		fileID, err = g.findOldestByName(ctx, remoteName)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = g.service.Files.Delete(fileList.Files[0].Id).Context(ctx).Do()
+	err = g.service.Files.Delete(fileID).SupportsAllDrives(true).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		g.logger.Warnf("File %s (id: %s) was already removed from Google Drive", remoteName, fileID)
+		err = nil
+	}
 	if err != nil {
 		g.logger.Errorf("Failed to delete file %s: %v", remoteName, err)
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
+	if ok {
+		entry.Refs = slices.DeleteFunc(entry.Refs, func(ref string) bool { return ref == fileID })
+		if len(entry.Refs) == 0 {
+			err = g.index.remove(remoteName)
+		} else {
+			err = g.index.put(entry)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update index: %w", err)
+		}
+	}
+
 	g.logger.Infof("Successfully deleted file %s", remoteName)
 	return nil
 }
 
-// GetOldFiles retrieves the names of files created before cutoffTime.
+func (g *GDriveStorage) findOldestByName(ctx context.Context, remoteName string) (string, error) {
+	query := fmt.Sprintf("'%s' in parents and name='%s' and trashed=false",
+		sanitizeQuery(g.folderID), sanitizeQuery(remoteName))
+
+	var ids []string
+	err := g.listFiles(ctx, query, func(file *drive.File) {
+		ids = append(ids, file.Id)
+	})
+	if err != nil {
+		g.logger.Errorf("Failed to find file %s in folder %s: %v", remoteName, g.folderID, err)
+		return "", fmt.Errorf("failed to find file: %w", err)
+	}
+
+	if len(ids) == 0 {
+		g.logger.Warnf("File %s not found in folder %s", remoteName, g.folderID)
+		return "", fmt.Errorf("file not found: %s", remoteName)
+	}
+	return ids[0], nil
+}
+
+// GetOldFiles retrieves the names of files created before cutoffTime. A name
+// is returned once per matching file, so duplicates are each deleted.
 func (g *GDriveStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
 	query := fmt.Sprintf("'%s' in parents and trashed=false and createdTime < '%s'",
 		sanitizeQuery(g.folderID), cutoffTime.Format(time.RFC3339))
 	g.logger.Infof("Listing files in folder %s older than %s", g.folderID, cutoffTime.Format(time.RFC3339))
 
-	fileList, err := g.service.Files.List().
-		Q(query).
-		Fields("files(id, name)").
-		Context(ctx).
-		Do()
-	if err != nil {
-		g.logger.Errorf("Failed to list old files in folder %s: %v", g.folderID, err)
-		return nil, fmt.Errorf("failed to list old files: %w", err)
-	}
-
 	var files []string
-	for _, file := range fileList.Files {
+	err := g.listFiles(ctx, query, func(file *drive.File) {
 		if file.Name != "" {
 			files = append(files, file.Name)
 		}
+	})
+	if err != nil {
+		g.logger.Errorf("Failed to list old files in folder %s: %v", g.folderID, err)
+		return nil, fmt.Errorf("failed to list old files: %w", err)
 	}
 
 	g.logger.Infof("Found %d old files in folder %s", len(files), g.folderID)
 	return files, nil
 }
 
+// listFiles calls fn for every file matching query, oldest first, following
+// page tokens. Shared Drive items are included, and a configured drive ID
+// limits the search to that Shared Drive.
+func (g *GDriveStorage) listFiles(ctx context.Context, query string, fn func(*drive.File)) error {
+	call := g.service.Files.List().
+		Q(query).
+		Fields("nextPageToken, files(id, name, createdTime)").
+		OrderBy("createdTime").
+		PageSize(1000).
+		SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true)
+	if g.driveID != "" {
+		call = call.Corpora("drive").DriveId(g.driveID)
+	}
+
+	return call.Pages(ctx, func(page *drive.FileList) error {
+		for _, file := range page.Files {
+			fn(file)
+		}
+		return nil
+	})
+}
+
 // sanitizeQuery escapes single quotes in query strings to prevent injection.
 func sanitizeQuery(input string) string {
 	return strings.ReplaceAll(input, "'", "\\'")
@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+type fakeDriveFile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	CreatedTime string `json:"createdTime"`
+}
+
+// fakeDrive implements the parts of the Drive v3 API used by GDriveStorage:
+// resumable uploads, paginated listings filtered by name and createdTime,
+// and deletes by ID. Listings return at most two files per page.
+type fakeDrive struct {
+	mu      sync.Mutex
+	files   []fakeDriveFile
+	uploads map[string][]byte
+	names   map[string]string
+	chunks  int
+	nextID  int
+	lists   []map[string]string
+}
+
+var (
+	driveNameFilter = regexp.MustCompile(`name='([^']*)'`)
+	driveTimeFilter = regexp.MustCompile(`createdTime < '([^']*)'`)
+)
+
+func (f *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Get("uploadType") == "resumable":
+		var meta drive.File
+		json.NewDecoder(r.Body).Decode(&meta)
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.names[id] = meta.Name
+		w.Header().Set("Location", "http://"+r.Host+"/resumable?id="+id)
+
+	case r.URL.Path == "/resumable":
+		id := q.Get("id")
+		body, _ := io.ReadAll(r.Body)
+		f.uploads[id] = append(f.uploads[id], body...)
+		f.chunks++
+
+		if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(f.uploads[id])-1))
+			w.Header().Set("X-Http-Status-Code-Override", "308")
+			return
+		}
+
+		file := fakeDriveFile{ID: id, Name: f.names[id], CreatedTime: time.Now().UTC().Format(time.RFC3339Nano)}
+		f.files = append(f.files, file)
+		json.NewEncoder(w).Encode(file)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/files":
+		params := map[string]string{}
+		for k := range q {
+			params[k] = q.Get(k)
+		}
+		f.lists = append(f.lists, params)
+
+		var matches []fakeDriveFile
+		for _, file := range f.files {
+			if m := driveNameFilter.FindStringSubmatch(q.Get("q")); m != nil && file.Name != m[1] {
+				continue
+			}
+			if m := driveTimeFilter.FindStringSubmatch(q.Get("q")); m != nil && file.CreatedTime >= m[1] {
+				continue
+			}
+			matches = append(matches, file)
+		}
+		sort.SliceStable(matches, func(a, b int) bool { return matches[a].CreatedTime < matches[b].CreatedTime })
+
+		start := 0
+		fmt.Sscan(q.Get("pageToken"), &start)
+		end := min(start+2, len(matches))
+		resp := map[string]any{"files": matches[start:end]}
+		if end < len(matches) {
+			resp["nextPageToken"] = fmt.Sprint(end)
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/files/"):
+		id := strings.TrimPrefix(r.URL.Path, "/files/")
+		for i, file := range f.files {
+			if file.ID == id {
+				f.files = append(f.files[:i], f.files[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"code":404,"message":"File not found"}}`)
+
+	default:
+		http.Error(w, r.Method+" "+r.URL.String(), http.StatusBadRequest)
+	}
+}
+
+func (f *fakeDrive) ids() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []string
+	for _, file := range f.files {
+		ids = append(ids, file.ID)
+	}
+	return ids
+}
+
+func TestGDriveStorage(t *testing.T) {
+	Convey("Given a GDriveStorage against a fake Drive API", t, func() {
+		fake := &fakeDrive{
+			uploads: map[string][]byte{},
+			names:   map[string]string{},
+			files: []fakeDriveFile{
+				{ID: "legacy-1", Name: "legacy.sql.gz", CreatedTime: "2020-01-01T00:00:00Z"},
+				{ID: "legacy-2", Name: "legacy.sql.gz", CreatedTime: "2020-01-02T00:00:00Z"},
+				{ID: "other-1", Name: "other.sql.gz", CreatedTime: "2020-01-03T00:00:00Z"},
+			},
+		}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		ctx := context.Background()
+		service, err := drive.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+		So(err, ShouldBeNil)
+
+		tempDir, err := os.MkdirTemp("", "gdrive_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		log, _ := logger.New("fatal", "")
+		storage, err := newGDriveStorage(service, &config.UploadTarget{
+			Type:        "gdrive",
+			FolderID:    "folder",
+			DriveID:     "shared-drive",
+			BlockSizeMB: 1,
+		}, tempDir, log)
+		So(err, ShouldBeNil)
+
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, make([]byte, 2<<20+100), 0644), ShouldBeNil)
+
+		Convey("Upload should send resumable chunks and record the file ID", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+			So(fake.chunks, ShouldEqual, 3)
+
+			entry, ok, err := storage.index.get("backup.sql.gz")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(entry.Refs, ShouldResemble, []string{"upload-1"})
+		})
+
+		Convey("List should follow page tokens across Shared Drives", func() {
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"legacy.sql.gz", "legacy.sql.gz", "other.sql.gz"})
+
+			So(fake.lists, ShouldHaveLength, 2)
+			So(fake.lists[1]["pageToken"], ShouldEqual, "2")
+			So(fake.lists[0]["supportsAllDrives"], ShouldEqual, "true")
+			So(fake.lists[0]["includeItemsFromAllDrives"], ShouldEqual, "true")
+			So(fake.lists[0]["corpora"], ShouldEqual, "drive")
+			So(fake.lists[0]["driveId"], ShouldEqual, "shared-drive")
+		})
+
+		Convey("GetOldFiles should return every old file, including duplicates", func() {
+			oldFiles, err := storage.GetOldFiles(ctx, time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"legacy.sql.gz", "legacy.sql.gz"})
+		})
+
+		Convey("Delete of an unindexed name should remove the oldest match", func() {
+			So(storage.Delete(ctx, "legacy.sql.gz"), ShouldBeNil)
+			So(fake.ids(), ShouldResemble, []string{"legacy-2", "other-1"})
+		})
+
+		Convey("Delete should use the recorded IDs for duplicate uploads", func() {
+			So(storage.Upload(ctx, sourceFile, "dup.sql.gz"), ShouldBeNil)
+			So(storage.Upload(ctx, sourceFile, "dup.sql.gz"), ShouldBeNil)
+
+			So(storage.Delete(ctx, "dup.sql.gz"), ShouldBeNil)
+			So(fake.ids(), ShouldNotContain, "upload-1")
+			So(fake.ids(), ShouldContain, "upload-2")
+
+			entry, _, _ := storage.index.get("dup.sql.gz")
+			So(entry.Refs, ShouldResemble, []string{"upload-2"})
+
+			So(storage.Delete(ctx, "dup.sql.gz"), ShouldBeNil)
+			_, ok, _ := storage.index.get("dup.sql.gz")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Delete of a file already removed in Drive should clean the index", func() {
+			So(storage.Upload(ctx, sourceFile, "gone.sql.gz"), ShouldBeNil)
+			fake.files = nil
+
+			So(storage.Delete(ctx, "gone.sql.gz"), ShouldBeNil)
+			_, ok, _ := storage.index.get("gone.sql.gz")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+// fakeTokenServer answers OAuth token requests and records the claims of
+// JWT bearer assertions.
+func fakeTokenServer(claims map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		claims["grant_type"] = r.Form.Get("grant_type")
+		claims["refresh_token"] = r.Form.Get("refresh_token")
+		if assertion := r.Form.Get("assertion"); assertion != "" {
+			parts := strings.Split(assertion, ".")
+			payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			json.Unmarshal(payload, &claims)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+	}))
+}
+
+func TestGDriveTokenSource(t *testing.T) {
+	Convey("Given gdrive credentials files", t, func() {
+		tempDir, err := os.MkdirTemp("", "gdrive_auth_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		claims := map[string]any{}
+		server := fakeTokenServer(claims)
+		defer server.Close()
+		ctx := context.Background()
+
+		clientSecret := filepath.Join(tempDir, "client_secret.json")
+		So(os.WriteFile(clientSecret, []byte(`{"installed":{"client_id":"id","client_secret":"secret",
+			"auth_uri":"`+server.URL+`/auth","token_uri":"`+server.URL+`/token","redirect_uris":["http://localhost"]}}`), 0600), ShouldBeNil)
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		So(err, ShouldBeNil)
+		serviceAccount, _ := json.Marshal(map[string]string{
+			"type":           "service_account",
+			"client_email":   "phylax@project.iam.gserviceaccount.com",
+			"private_key_id": "key-1",
+			"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			"token_uri":      server.URL + "/token",
+		})
+		serviceAccountKey := filepath.Join(tempDir, "service_account.json")
+		So(os.WriteFile(serviceAccountKey, serviceAccount, 0600), ShouldBeNil)
+
+		Convey("OAuth should refresh with the configured token", func() {
+			ts, err := gdriveTokenSource(ctx, &config.UploadTarget{
+				CredentialsFile: clientSecret,
+				RefreshToken:    "refresh",
+			}, nil)
+			So(err, ShouldBeNil)
+
+			_, err = ts.Token()
+			So(err, ShouldBeNil)
+			So(claims["grant_type"], ShouldEqual, "refresh_token")
+			So(claims["refresh_token"], ShouldEqual, "refresh")
+		})
+
+		Convey("OAuth with a token store should import the configured token and persist refreshes", func() {
+			tokens, err := tokenstore.New(filepath.Join(tempDir, "tokens.enc"), []byte("key"))
+			So(err, ShouldBeNil)
+			target := &config.UploadTarget{CredentialsFile: clientSecret, FolderID: "folder", RefreshToken: "refresh"}
+
+			ts, err := gdriveTokenSource(ctx, target, tokens)
+			So(err, ShouldBeNil)
+			_, err = ts.Token()
+			So(err, ShouldBeNil)
+			So(claims["refresh_token"], ShouldEqual, "refresh")
+
+			stored, ok, err := tokens.Get(GDriveTokenName(target))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(stored.AccessToken, ShouldEqual, "access")
+			So(stored.RefreshToken, ShouldEqual, "refresh")
+		})
+
+		Convey("OAuth without a refresh token should return error", func() {
+			_, err := gdriveTokenSource(ctx, &config.UploadTarget{CredentialsFile: clientSecret}, nil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "refresh token is required")
+		})
+
+		Convey("A service account should impersonate the configured user", func() {
+			ts, err := gdriveTokenSource(ctx, &config.UploadTarget{
+				Auth:            "service_account",
+				CredentialsFile: serviceAccountKey,
+				ImpersonateUser: "backups@example.com",
+			}, nil)
+			So(err, ShouldBeNil)
+
+			_, err = ts.Token()
+			So(err, ShouldBeNil)
+			So(claims["grant_type"], ShouldEqual, "urn:ietf:params:oauth:grant-type:jwt-bearer")
+			So(claims["iss"], ShouldEqual, "phylax@project.iam.gserviceaccount.com")
+			So(claims["sub"], ShouldEqual, "backups@example.com")
+			So(claims["scope"], ShouldEqual, drive.DriveFileScope)
+		})
+
+		Convey("A client secret used as a service account key should return error", func() {
+			_, err := gdriveTokenSource(ctx, &config.UploadTarget{Auth: "service_account", CredentialsFile: clientSecret}, nil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "failed to parse service account key")
+		})
+
+		Convey("An unknown auth mode should return error", func() {
+			_, err := gdriveTokenSource(ctx, &config.UploadTarget{Auth: "apikey", CredentialsFile: clientSecret}, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// indexEntry describes one uploaded backup and the remote objects it is made
+// of (message IDs, file IDs, ...).
+type indexEntry struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Refs      []string  `json:"refs"`
+}
+
+// fileIndex is a small JSON file recording what was uploaded to targets that
+// cannot enumerate or address their contents by name.
+type fileIndex struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newFileIndex(path string) (*fileIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	return &fileIndex{path: path}, nil
+}
+
+// entries returns every indexed backup ordered by creation time.
+func (i *fileIndex) entries() ([]indexEntry, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries, err := i.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]indexEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return list, nil
+}
+
+func (i *fileIndex) get(name string) (indexEntry, bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries, err := i.load()
+	if err != nil {
+		return indexEntry{}, false, err
+	}
+	e, ok := entries[name]
+	return e, ok, nil
+}
+
+// put adds or replaces the entry for e.Name.
+func (i *fileIndex) put(e indexEntry) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries, err := i.load()
+	if err != nil {
+		return err
+	}
+	entries[e.Name] = e
+	return i.save(entries)
+}
+
+func (i *fileIndex) remove(name string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries, err := i.load()
+	if err != nil {
+		return err
+	}
+	delete(entries, name)
+	return i.save(entries)
+}
+
+func (i *fileIndex) load() (map[string]indexEntry, error) {
+	entries := make(map[string]indexEntry)
+
+	data, err := os.ReadFile(i.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", i.path, err)
+	}
+	return entries, nil
+}
+
+// save writes the index atomically so a crash never leaves it truncated.
+func (i *fileIndex) save(entries map[string]indexEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	tmp := i.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp, i.path); err != nil {
+		return fmt.Errorf("failed to replace index: %w", err)
+	}
+	return nil
+}
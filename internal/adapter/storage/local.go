@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// tempSuffix marks in-progress uploads, which List and GetOldFiles skip.
+const tempSuffix = ".tmp"
+
 type LocalStorage struct {
 	basePath string
 }
@@ -22,6 +26,9 @@ func NewLocal(basePath string) (*LocalStorage, error) {
 	return &LocalStorage{basePath: basePath}, nil
 }
 
+// Upload copies localPath into the backup directory through a temporary
+// file that is renamed into place, so a crash never leaves a truncated file
+// under remoteName.
 func (l *LocalStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
 	destPath := filepath.Join(l.basePath, remoteName)
 
@@ -31,19 +38,53 @@ func (l *LocalStorage) Upload(ctx context.Context, localPath string, remoteName
 	}
 	defer source.Close()
 
-	dest, err := os.Create(destPath)
+	dest, err := os.CreateTemp(l.basePath, "."+remoteName+".*"+tempSuffix)
 	if err != nil {
 		return fmt.Errorf("failed to create dest: %w", err)
 	}
+	defer os.Remove(dest.Name())
 	defer dest.Close()
 
 	if _, err := dest.ReadFrom(source); err != nil {
 		return fmt.Errorf("failed to copy: %w", err)
 	}
+	if err := dest.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dest: %w", err)
+	}
+	if err := dest.Close(); err != nil {
+		return fmt.Errorf("failed to close dest: %w", err)
+	}
+	if err := os.Chmod(dest.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := os.Rename(dest.Name(), destPath); err != nil {
+		return fmt.Errorf("failed to rename dest: %w", err)
+	}
 
 	return nil
 }
 
+// Download copies remoteName from the backup directory to localPath.
+func (l *LocalStorage) Download(ctx context.Context, remoteName string, localPath string) error {
+	source, err := os.Open(filepath.Join(l.basePath, remoteName))
+	if err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer source.Close()
+
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create dest: %w", err)
+	}
+	defer dest.Close()
+
+	if _, err := dest.ReadFrom(source); err != nil {
+		return fmt.Errorf("failed to copy: %w", err)
+	}
+
+	return dest.Close()
+}
+
 func (l *LocalStorage) List(ctx context.Context) ([]string, error) {
 	entries, err := os.ReadDir(l.basePath)
 	if err != nil {
@@ -52,7 +93,7 @@ func (l *LocalStorage) List(ctx context.Context) ([]string, error) {
 
 	var files []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && !isTempUpload(entry.Name()) {
 			files = append(files, entry.Name())
 		}
 	}
@@ -76,7 +117,7 @@ func (l *LocalStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([
 
 	var oldFiles []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && !isTempUpload(entry.Name()) {
 			info, err := entry.Info()
 			if err != nil {
 				return nil, fmt.Errorf("failed to get file info for %s: %w", entry.Name(), err)
@@ -90,6 +131,10 @@ func (l *LocalStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([
 	return oldFiles, nil
 }
 
+func isTempUpload(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
+
 func (l *LocalStorage) GetPath(filename string) string {
 	return filepath.Join(l.basePath, filename)
 }
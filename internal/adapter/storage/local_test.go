@@ -63,6 +63,14 @@ func TestLocalStorage(t *testing.T) {
 					So(err, ShouldBeNil)
 					So(string(content), ShouldEqual, "test content")
 				})
+
+				Convey("It should leave no temporary file behind", func() {
+					entries, err := os.ReadDir(tempDir)
+					So(err, ShouldBeNil)
+					for _, entry := range entries {
+						So(entry.Name(), ShouldNotEndWith, ".tmp")
+					}
+				})
 			})
 
 			Convey("When source file does not exist", func() {
@@ -76,6 +84,36 @@ func TestLocalStorage(t *testing.T) {
 			})
 		})
 
+		Convey("Download method", func() {
+			storage, _ := NewLocal(tempDir)
+
+			Convey("When downloading an existing file", func() {
+				os.WriteFile(filepath.Join(tempDir, "stored.txt"), []byte("test content"), 0644)
+
+				ctx := context.Background()
+				target := filepath.Join(tempDir, "downloaded.txt")
+				err := storage.Download(ctx, "stored.txt", target)
+
+				Convey("It should copy it to the local path", func() {
+					So(err, ShouldBeNil)
+
+					content, err := os.ReadFile(target)
+					So(err, ShouldBeNil)
+					So(string(content), ShouldEqual, "test content")
+				})
+			})
+
+			Convey("When the file does not exist", func() {
+				ctx := context.Background()
+				err := storage.Download(ctx, "missing.txt", filepath.Join(tempDir, "downloaded.txt"))
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "failed to open source")
+				})
+			})
+		})
+
 		Convey("List method", func() {
 			storage, _ := NewLocal(tempDir)
 
@@ -84,11 +122,13 @@ func TestLocalStorage(t *testing.T) {
 				os.WriteFile(filepath.Join(tempDir, "file1.txt"), []byte("test"), 0644)
 				os.WriteFile(filepath.Join(tempDir, "file2.txt"), []byte("test"), 0644)
 				os.Mkdir(filepath.Join(tempDir, "subdir"), 0755)
+				// An upload interrupted by a crash.
+				os.WriteFile(filepath.Join(tempDir, ".file3.txt.123.tmp"), []byte("te"), 0644)
 
 				ctx := context.Background()
 				files, err := storage.List(ctx)
 
-				Convey("It should list only files", func() {
+				Convey("It should list only completed files", func() {
 					So(err, ShouldBeNil)
 					So(len(files), ShouldEqual, 2)
 					So(files, ShouldContain, "file1.txt")
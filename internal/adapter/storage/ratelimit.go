@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// throttledReader limits the average throughput of an upload to a fixed
+// number of bytes per second.
+type throttledReader struct {
+	ctx         context.Context
+	r           io.Reader
+	bytesPerSec int64
+	start       time.Time
+	read        int64
+}
+
+// newThrottledReader wraps r; a non-positive limit disables throttling.
+func newThrottledReader(ctx context.Context, r io.Reader, bytesPerSec int64) io.Reader {
+	if bytesPerSec <= 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, bytesPerSec: bytesPerSec}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	// Never read more than roughly a tenth of a second's worth at once so
+	// the rate stays smooth.
+	if chunk := max(t.bytesPerSec/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.bytesPerSec) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return n, err
+}
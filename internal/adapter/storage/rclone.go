@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+)
+
+// RcloneStorage implements the Storage interface by delegating to the rclone
+// binary, which gives access to every backend rclone supports (Dropbox,
+// OneDrive, Backblaze B2, Swift, ...). Remotes are configured with
+// `rclone config` as usual.
+type RcloneStorage struct {
+	binary string
+	remote string
+	args   []string
+}
+
+// NewRclone creates a new RcloneStorage instance. remote is an rclone path
+// such as "dropbox:backups/phylax".
+func NewRclone(cfg *config.UploadTarget) (*RcloneStorage, error) {
+	if cfg.Remote == "" {
+		return nil, errors.New("rclone remote is required")
+	}
+
+	binary := cfg.RcloneBinary
+	if binary == "" {
+		binary = "rclone"
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("rclone binary not found: %w", err)
+	}
+
+	var args []string
+	if cfg.RcloneConfig != "" {
+		args = append(args, "--config", cfg.RcloneConfig)
+	}
+	if cfg.BandwidthLimitKBps > 0 {
+		args = append(args, "--bwlimit", fmt.Sprintf("%dk", cfg.BandwidthLimitKBps))
+	}
+	args = append(args, cfg.RcloneArgs...)
+
+	return &RcloneStorage{
+		binary: binary,
+		remote: strings.TrimSuffix(cfg.Remote, "/"),
+		args:   args,
+	}, nil
+}
+
+// Upload copies the file to the remote under remoteName
+func (r *RcloneStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
+	if _, err := r.run(ctx, "copyto", localPath, r.path(remoteName)); err != nil {
+		return fmt.Errorf("failed to upload: %w", err)
+	}
+	return nil
+}
+
+func (r *RcloneStorage) List(ctx context.Context) ([]string, error) {
+	entries, err := r.lsjson(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Path)
+	}
+	return files, nil
+}
+
+func (r *RcloneStorage) Delete(ctx context.Context, remoteName string) error {
+	if _, err := r.run(ctx, "deletefile", r.path(remoteName)); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// GetOldFiles returns files whose ModTime, as reported by lsjson, is before
+// cutoffTime.
+func (r *RcloneStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	entries, err := r.lsjson(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldFiles []string
+	for _, entry := range entries {
+		if entry.ModTime.Before(cutoffTime) {
+			oldFiles = append(oldFiles, entry.Path)
+		}
+	}
+	return oldFiles, nil
+}
+
+type rcloneEntry struct {
+	Path    string    `json:"Path"`
+	ModTime time.Time `json:"ModTime"`
+}
+
+// lsjson lists the files directly under the remote.
+func (r *RcloneStorage) lsjson(ctx context.Context) ([]rcloneEntry, error) {
+	output, err := r.run(ctx, "lsjson", "--files-only", "--no-mimetype", r.remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote: %w", err)
+	}
+
+	var entries []rcloneEntry
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse rclone lsjson output: %w", err)
+	}
+	return entries, nil
+}
+
+// run executes an rclone subcommand and returns its stdout. stderr is
+// included in the error on failure.
+func (r *RcloneStorage) run(ctx context.Context, subcommand string, args ...string) ([]byte, error) {
+	fullArgs := append([]string{subcommand}, r.args...)
+	fullArgs = append(fullArgs, args...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.binary, fullArgs...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rclone %s failed: %w, output: %s", subcommand, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// path joins remoteName onto the remote. A bare "name:" remote refers to its
+// root, so no separator is added after the colon.
+func (r *RcloneStorage) path(remoteName string) string {
+	if strings.HasSuffix(r.remote, ":") {
+		return r.remote + remoteName
+	}
+	return r.remote + "/" + remoteName
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeRclone writes a shell script that records its arguments and prints a
+// canned lsjson listing.
+func fakeRclone(dir string) (binary, argsFile string) {
+	binary = filepath.Join(dir, "rclone")
+	argsFile = filepath.Join(dir, "args")
+	script := `#!/bin/sh
+echo "$@" >> "` + argsFile + `"
+case "$1" in
+lsjson) echo '[{"Path":"old.sql.gz","Name":"old.sql.gz","Size":3,"ModTime":"2020-01-01T00:00:00.000000000Z","IsDir":false},{"Path":"new.sql.gz","Name":"new.sql.gz","Size":3,"ModTime":"2099-01-01T00:00:00Z","IsDir":false}]' ;;
+deletefile) echo "permission denied" >&2; exit 1 ;;
+esac
+`
+	os.WriteFile(binary, []byte(script), 0755)
+	return binary, argsFile
+}
+
+func TestRcloneStorage(t *testing.T) {
+	Convey("Given an RcloneStorage with a fake rclone binary", t, func() {
+		tempDir, err := os.MkdirTemp("", "rclone_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		binary, argsFile := fakeRclone(tempDir)
+		storage, err := NewRclone(&config.UploadTarget{
+			Type:               "rclone",
+			Remote:             "dropbox:backups/",
+			RcloneBinary:       binary,
+			RcloneConfig:       "/etc/phylax/rclone.conf",
+			BandwidthLimitKBps: 512,
+			RcloneArgs:         []string{"--retries", "5"},
+		})
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		recorded := func() []string {
+			content, _ := os.ReadFile(argsFile)
+			return strings.Split(strings.TrimSpace(string(content)), "\n")
+		}
+
+		Convey("Upload should run copyto with the global flags", func() {
+			So(storage.Upload(ctx, "/tmp/backup.sql.gz", "backup.sql.gz"), ShouldBeNil)
+			So(recorded(), ShouldResemble, []string{
+				"copyto --config /etc/phylax/rclone.conf --bwlimit 512k --retries 5 /tmp/backup.sql.gz dropbox:backups/backup.sql.gz",
+			})
+		})
+
+		Convey("List and GetOldFiles should parse lsjson output", func() {
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"old.sql.gz", "new.sql.gz"})
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now())
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"old.sql.gz"})
+
+			So(recorded()[0], ShouldEndWith, "lsjson --config /etc/phylax/rclone.conf --bwlimit 512k --retries 5 --files-only --no-mimetype dropbox:backups")
+		})
+
+		Convey("Delete should report rclone's stderr on failure", func() {
+			err := storage.Delete(ctx, "old.sql.gz")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "permission denied")
+		})
+
+		Convey("A bare remote root should not get a separator", func() {
+			storage.remote = "b2:"
+			So(storage.path("backup.sql.gz"), ShouldEqual, "b2:backup.sql.gz")
+		})
+	})
+
+	Convey("Given a missing rclone binary", t, func() {
+		_, err := NewRclone(&config.UploadTarget{Type: "rclone", Remote: "x:", RcloneBinary: "/nonexistent/rclone"})
+
+		Convey("It should return error", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "rclone binary not found")
+		})
+	})
+}
+
+func TestRcloneStorageLocalBackend(t *testing.T) {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone not in PATH")
+	}
+
+	Convey("Given an RcloneStorage on rclone's local backend", t, func() {
+		tempDir, err := os.MkdirTemp("", "rclone_local_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		remoteDir := filepath.Join(tempDir, "remote")
+		storage, err := NewRclone(&config.UploadTarget{
+			Type:   "rclone",
+			Remote: ":local:" + remoteDir,
+		})
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, []byte("backup"), 0644), ShouldBeNil)
+
+		Convey("It should upload, list, age and delete files", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			oldTime := time.Now().Add(-10 * 24 * time.Hour)
+			So(os.Chtimes(filepath.Join(remoteDir, "backup.sql.gz"), oldTime, oldTime), ShouldBeNil)
+
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"backup.sql.gz"})
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(-7*24*time.Hour))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"backup.sql.gz"})
+
+			So(storage.Delete(ctx, "backup.sql.gz"), ShouldBeNil)
+			_, err = os.Stat(filepath.Join(remoteDir, "backup.sql.gz"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
@@ -2,16 +2,28 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	appconfig "github.com/semmidev/phylax/internal/config"
 )
 
@@ -20,31 +32,196 @@ type S3Storage struct {
 	uploader *s3manager.Uploader
 	bucket   string
 	prefix   string
+	upload   uploadOptions
 }
 
-// NewS3 creates a new S3Storage instance using AWS SDK v2
+// uploadOptions are applied to every object written by Upload.
+type uploadOptions struct {
+	sse            types.ServerSideEncryption
+	sseKMSKeyID    string
+	storageClass   types.StorageClass
+	tagging        string
+	objectLockMode types.ObjectLockMode
+	objectLockDays int
+}
+
+// NewS3 creates a new S3Storage instance using AWS SDK v2.
+// A custom endpoint selects an S3-compatible service such as MinIO, Wasabi,
+// Cloudflare R2 or Ceph RGW.
 func NewS3(cfg *appconfig.UploadTarget) (*S3Storage, error) {
-	awsCfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(cfg.Region),
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
-		),
-	)
+	upload, err := newUploadOptions(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, err
 	}
 
-	client := s3.NewFromConfig(awsCfg)
+	awsCfg, err := newAWSConfig(context.TODO(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = cfg.ForcePathStyle
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			// Not every S3-compatible service understands the default
+			// flexible checksums, so only send them when an API requires it.
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	})
 	uploader := s3manager.NewUploader(client)
 
 	return &S3Storage{
 		client:   client,
 		uploader: uploader,
 		bucket:   cfg.Bucket,
-		prefix:   cfg.Prefix,
+		prefix:   strings.Trim(cfg.Prefix, "/"),
+		upload:   upload,
 	}, nil
 }
 
+// newAWSConfig resolves region, credentials and transport for an upload
+// target. Static keys are used when configured; otherwise the default AWS
+// credential chain applies (environment, shared profile, SSO, web identity,
+// instance or container roles). A role ARN is assumed on top of whichever
+// base credentials were resolved.
+func newAWSConfig(ctx context.Context, cfg *appconfig.UploadTarget) (aws.Config, error) {
+	if (cfg.AccessKey == "") != (cfg.SecretKey == "") {
+		return aws.Config{}, errors.New("access_key and secret_key must be set together")
+	}
+
+	region := cfg.Region
+	if region == "" && cfg.Endpoint != "" {
+		// Most S3-compatible services accept any region for signing.
+		region = "us-east-1"
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+	}
+
+	if cfg.AccessKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
+		))
+	}
+	if cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+
+	if cfg.InsecureSkipVerify || cfg.CAFile != "" {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		opts = append(opts, config.WithHTTPClient(
+			awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+				tr.TLSClientConfig = tlsConfig
+			}),
+		))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	if cfg.RoleARN != "" {
+		sessionName := cfg.SessionName
+		if sessionName == "" {
+			sessionName = "phylax"
+		}
+
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), cfg.RoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = sessionName
+				if cfg.ExternalID != "" {
+					o.ExternalID = aws.String(cfg.ExternalID)
+				}
+			},
+		)
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return awsCfg, nil
+}
+
+// newUploadOptions validates the encryption, storage class, tagging and
+// Object Lock settings of an upload target.
+func newUploadOptions(cfg *appconfig.UploadTarget) (uploadOptions, error) {
+	opts := uploadOptions{
+		sse:            types.ServerSideEncryption(cfg.SSE),
+		sseKMSKeyID:    cfg.SSEKMSKeyID,
+		storageClass:   types.StorageClass(strings.ToUpper(cfg.StorageClass)),
+		objectLockMode: types.ObjectLockMode(strings.ToUpper(cfg.ObjectLockMode)),
+		objectLockDays: cfg.ObjectLockDays,
+	}
+
+	switch opts.sse {
+	case "", types.ServerSideEncryptionAes256:
+		if opts.sseKMSKeyID != "" {
+			return opts, errors.New("sse_kms_key_id requires sse: aws:kms")
+		}
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+	default:
+		return opts, fmt.Errorf("unsupported sse: %s", cfg.SSE)
+	}
+
+	if opts.storageClass != "" && !slices.Contains(opts.storageClass.Values(), opts.storageClass) {
+		return opts, fmt.Errorf("unsupported storage_class: %s", cfg.StorageClass)
+	}
+
+	switch opts.objectLockMode {
+	case "":
+		if opts.objectLockDays != 0 {
+			return opts, errors.New("object_lock_days requires object_lock_mode")
+		}
+	case types.ObjectLockModeGovernance, types.ObjectLockModeCompliance:
+		if opts.objectLockDays <= 0 {
+			return opts, errors.New("object_lock_days must be positive when object_lock_mode is set")
+		}
+	default:
+		return opts, fmt.Errorf("unsupported object_lock_mode: %s", cfg.ObjectLockMode)
+	}
+
+	if len(cfg.Tags) > 0 {
+		tags := url.Values{}
+		for k, v := range cfg.Tags {
+			tags.Set(k, v)
+		}
+		opts.tagging = tags.Encode()
+	}
+
+	return opts, nil
+}
+
+// newTLSConfig builds the TLS settings for endpoints with a private CA or,
+// for testing only, without certificate verification.
+func newTLSConfig(cfg *appconfig.UploadTarget) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
 // Upload uploads a local file to S3
 func (s *S3Storage) Upload(ctx context.Context, localPath string, remoteName string) error {
 	file, err := os.Open(localPath)
@@ -53,13 +230,15 @@ func (s *S3Storage) Upload(ctx context.Context, localPath string, remoteName str
 	}
 	defer file.Close()
 
-	key := filepath.Join(s.prefix, remoteName)
-
-	_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
+	key := s.key(remoteName)
+	input := &s3.PutObjectInput{
 		Bucket: &s.bucket,
 		Key:    &key,
 		Body:   file,
-	})
+	}
+	s.applyUploadOptions(input)
+
+	_, err = s.uploader.Upload(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
@@ -67,22 +246,65 @@ func (s *S3Storage) Upload(ctx context.Context, localPath string, remoteName str
 	return nil
 }
 
-// List returns all files in the bucket with the given prefix
-func (s *S3Storage) List(ctx context.Context) ([]string, error) {
-	resp, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+func (s *S3Storage) applyUploadOptions(input *s3.PutObjectInput) {
+	opts := s.upload
+
+	if opts.sse != "" {
+		input.ServerSideEncryption = opts.sse
+	}
+	if opts.sseKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(opts.sseKMSKeyID)
+	}
+	if opts.storageClass != "" {
+		input.StorageClass = opts.storageClass
+	}
+	if opts.tagging != "" {
+		input.Tagging = aws.String(opts.tagging)
+	}
+	if opts.objectLockMode != "" {
+		input.ObjectLockMode = opts.objectLockMode
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().AddDate(0, 0, opts.objectLockDays))
+		// Object Lock writes must carry an integrity checksum.
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32
+	}
+}
+
+// Download writes the object for remoteName to localPath.
+func (s *S3Storage) Download(ctx context.Context, remoteName string, localPath string) error {
+	key := s.key(remoteName)
+
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &s.bucket,
-		Prefix: &s.prefix,
+		Key:    &key,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+		return fmt.Errorf("failed to download from S3: %w", err)
 	}
+	defer output.Body.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, output.Body); err != nil {
+		return fmt.Errorf("failed to download from S3: %w", err)
+	}
+
+	return file.Close()
+}
 
+// List returns all files in the bucket with the given prefix
+func (s *S3Storage) List(ctx context.Context) ([]string, error) {
 	var files []string
-	for _, obj := range resp.Contents {
-		name := strings.TrimPrefix(*obj.Key, s.prefix)
-		if name != "" {
+	err := s.listObjects(ctx, func(obj types.Object) {
+		if name := s.name(*obj.Key); name != "" {
 			files = append(files, name)
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return files, nil
@@ -90,7 +312,7 @@ func (s *S3Storage) List(ctx context.Context) ([]string, error) {
 
 // Delete removes a file from S3
 func (s *S3Storage) Delete(ctx context.Context, remoteName string) error {
-	key := filepath.Join(s.prefix, remoteName)
+	key := s.key(remoteName)
 
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &s.bucket,
@@ -105,23 +327,53 @@ func (s *S3Storage) Delete(ctx context.Context, remoteName string) error {
 
 // GetOldFiles returns files older than a given time
 func (s *S3Storage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
-	resp, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: &s.bucket,
-		Prefix: &s.prefix,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
-	}
-
 	var oldFiles []string
-	for _, obj := range resp.Contents {
+	err := s.listObjects(ctx, func(obj types.Object) {
 		if obj.LastModified.Before(cutoffTime) {
-			name := strings.TrimPrefix(*obj.Key, s.prefix)
-			if name != "" {
+			if name := s.name(*obj.Key); name != "" {
 				oldFiles = append(oldFiles, name)
 			}
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return oldFiles, nil
 }
+
+// listObjects calls fn for every object under the prefix, following
+// continuation tokens past the 1000 keys returned per page.
+func (s *S3Storage) listObjects(ctx context.Context, fn func(types.Object)) error {
+	input := &s3.ListObjectsV2Input{Bucket: &s.bucket}
+	if s.prefix != "" {
+		input.Prefix = aws.String(s.prefix + "/")
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list S3 objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			fn(obj)
+		}
+	}
+
+	return nil
+}
+
+// key maps a remote file name to its object key. Keys always use forward
+// slashes regardless of the host OS.
+func (s *S3Storage) key(remoteName string) string {
+	return path.Join(s.prefix, remoteName)
+}
+
+// name maps an object key back to the remote file name.
+func (s *S3Storage) name(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.prefix+"/")
+}
@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestS3TLSConfig(t *testing.T) {
+	Convey("Given S3 TLS settings", t, func() {
+		tempDir, err := os.MkdirTemp("", "s3_tls_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		Convey("When insecure_skip_verify is set", func() {
+			tlsConfig, err := newTLSConfig(&config.UploadTarget{InsecureSkipVerify: true})
+
+			Convey("It should disable verification", func() {
+				So(err, ShouldBeNil)
+				So(tlsConfig.InsecureSkipVerify, ShouldBeTrue)
+				So(tlsConfig.RootCAs, ShouldBeNil)
+			})
+		})
+
+		Convey("When the CA file does not exist", func() {
+			_, err := newTLSConfig(&config.UploadTarget{CAFile: filepath.Join(tempDir, "missing.pem")})
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "failed to read CA file")
+			})
+		})
+
+		Convey("When the CA file has no certificates", func() {
+			caFile := filepath.Join(tempDir, "ca.pem")
+			os.WriteFile(caFile, []byte("not a certificate"), 0644)
+
+			_, err := newTLSConfig(&config.UploadTarget{CAFile: caFile})
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "no certificates found")
+			})
+		})
+	})
+}
+
+func TestAWSConfigCredentials(t *testing.T) {
+	Convey("Given S3 credential settings", t, func() {
+		tempDir, err := os.MkdirTemp("", "aws_config_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		// Isolate the default chain from the host environment.
+		credentialsFile := filepath.Join(tempDir, "credentials")
+		os.WriteFile(credentialsFile, []byte("[backup]\naws_access_key_id = PROFILEKEY\naws_secret_access_key = profile-secret\n"), 0600)
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+		t.Setenv("AWS_CONFIG_FILE", filepath.Join(tempDir, "config"))
+		t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+		t.Setenv("AWS_ACCESS_KEY_ID", "")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+		t.Setenv("AWS_PROFILE", "")
+
+		ctx := context.Background()
+
+		Convey("When static keys are configured", func() {
+			awsCfg, err := newAWSConfig(ctx, &config.UploadTarget{
+				Region: "us-east-1", AccessKey: "STATICKEY", SecretKey: "static-secret",
+			})
+			So(err, ShouldBeNil)
+
+			creds, err := awsCfg.Credentials.Retrieve(ctx)
+
+			Convey("It should use them", func() {
+				So(err, ShouldBeNil)
+				So(creds.AccessKeyID, ShouldEqual, "STATICKEY")
+			})
+		})
+
+		Convey("When keys are empty", func() {
+			t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+
+			awsCfg, err := newAWSConfig(ctx, &config.UploadTarget{Region: "us-east-1"})
+			So(err, ShouldBeNil)
+
+			creds, err := awsCfg.Credentials.Retrieve(ctx)
+
+			Convey("It should fall back to the default credential chain", func() {
+				So(err, ShouldBeNil)
+				So(creds.AccessKeyID, ShouldEqual, "ENVKEY")
+			})
+		})
+
+		Convey("When a profile is configured", func() {
+			awsCfg, err := newAWSConfig(ctx, &config.UploadTarget{Region: "us-east-1", Profile: "backup"})
+			So(err, ShouldBeNil)
+
+			creds, err := awsCfg.Credentials.Retrieve(ctx)
+
+			Convey("It should load the shared profile", func() {
+				So(err, ShouldBeNil)
+				So(creds.AccessKeyID, ShouldEqual, "PROFILEKEY")
+			})
+		})
+
+		Convey("When only one static key is set", func() {
+			_, err := newAWSConfig(ctx, &config.UploadTarget{AccessKey: "STATICKEY"})
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "must be set together")
+			})
+		})
+
+		Convey("When a role ARN is configured", func() {
+			awsCfg, err := newAWSConfig(ctx, &config.UploadTarget{
+				Region:    "us-east-1",
+				AccessKey: "STATICKEY", SecretKey: "static-secret",
+				RoleARN:    "arn:aws:iam::123456789012:role/dr-backups",
+				ExternalID: "phylax",
+			})
+
+			Convey("It should wrap the base credentials in an assume-role provider", func() {
+				So(err, ShouldBeNil)
+				cache, ok := awsCfg.Credentials.(*aws.CredentialsCache)
+				So(ok, ShouldBeTrue)
+				So(cache.IsCredentialsProvider(&stscreds.AssumeRoleProvider{}), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestS3UploadOptions(t *testing.T) {
+	Convey("Given S3 upload settings", t, func() {
+		Convey("When every option is set", func() {
+			opts, err := newUploadOptions(&config.UploadTarget{
+				SSE:            "aws:kms",
+				SSEKMSKeyID:    "alias/backups",
+				StorageClass:   "standard_ia",
+				Tags:           map[string]string{"team": "dba", "env": "prod"},
+				ObjectLockMode: "compliance",
+				ObjectLockDays: 30,
+			})
+			So(err, ShouldBeNil)
+
+			input := &s3.PutObjectInput{}
+			(&S3Storage{upload: opts}).applyUploadOptions(input)
+
+			Convey("It should apply them to the put request", func() {
+				So(input.ServerSideEncryption, ShouldEqual, types.ServerSideEncryptionAwsKms)
+				So(*input.SSEKMSKeyId, ShouldEqual, "alias/backups")
+				So(input.StorageClass, ShouldEqual, types.StorageClassStandardIa)
+				So(*input.Tagging, ShouldEqual, "env=prod&team=dba")
+				So(input.ObjectLockMode, ShouldEqual, types.ObjectLockModeCompliance)
+				So(input.ObjectLockRetainUntilDate.After(time.Now().AddDate(0, 0, 29)), ShouldBeTrue)
+				So(input.ChecksumAlgorithm, ShouldEqual, types.ChecksumAlgorithmCrc32)
+			})
+		})
+
+		Convey("When sse is unknown", func() {
+			_, err := newUploadOptions(&config.UploadTarget{SSE: "rot13"})
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "unsupported sse")
+			})
+		})
+
+		Convey("When storage class is unknown", func() {
+			_, err := newUploadOptions(&config.UploadTarget{StorageClass: "COLD"})
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "unsupported storage_class")
+			})
+		})
+
+		Convey("When object lock has no retention", func() {
+			_, err := newUploadOptions(&config.UploadTarget{ObjectLockMode: "GOVERNANCE"})
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "object_lock_days must be positive")
+			})
+		})
+	})
+}
+
+func TestS3StoragePagination(t *testing.T) {
+	Convey("Given a bucket with more objects than fit in one listing page", t, func() {
+		var prefixes []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			prefixes = append(prefixes, q.Get("prefix"))
+
+			w.Header().Set("Content-Type", "application/xml")
+			if q.Get("continuation-token") == "" {
+				fmt.Fprint(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>page2</NextContinuationToken>`+
+					`<Contents><Key>db/a.sql.gz</Key><LastModified>2020-01-01T00:00:00Z</LastModified></Contents>`+
+					`<Contents><Key>db/b.sql.gz</Key><LastModified>2020-01-01T00:00:00Z</LastModified></Contents></ListBucketResult>`)
+				return
+			}
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`+
+				`<Contents><Key>db/c.sql.gz</Key><LastModified>2099-01-01T00:00:00Z</LastModified></Contents></ListBucketResult>`)
+		}))
+		defer server.Close()
+
+		storage, err := NewS3(&config.UploadTarget{
+			Type:           "s3",
+			Endpoint:       server.URL,
+			ForcePathStyle: true,
+			Bucket:         "backups",
+			AccessKey:      "key",
+			SecretKey:      "secret",
+			Prefix:         "/db/",
+		})
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("List should follow continuation tokens", func() {
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"a.sql.gz", "b.sql.gz", "c.sql.gz"})
+			So(prefixes, ShouldResemble, []string{"db/", "db/"})
+		})
+
+		Convey("GetOldFiles should check every page", func() {
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now())
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"a.sql.gz", "b.sql.gz"})
+		})
+
+		Convey("Keys should be joined with forward slashes", func() {
+			So(storage.key("a.sql.gz"), ShouldEqual, "db/a.sql.gz")
+		})
+	})
+}
+
+// TestS3StorageIntegration runs against an S3-compatible server such as a
+// local MinIO binary:
+//
+//	minio server /tmp/minio &
+//	PHYLAX_TEST_S3_ENDPOINT=http://127.0.0.1:9000 go test ./internal/adapter/storage -run S3
+func TestS3StorageIntegration(t *testing.T) {
+	endpoint := os.Getenv("PHYLAX_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("PHYLAX_TEST_S3_ENDPOINT not set")
+	}
+
+	Convey("Given an S3Storage against an S3-compatible endpoint", t, func() {
+		cfg := &config.UploadTarget{
+			Type:           "s3",
+			Endpoint:       endpoint,
+			ForcePathStyle: true,
+			Bucket:         envOrDefault("PHYLAX_TEST_S3_BUCKET", "phylax-test"),
+			AccessKey:      envOrDefault("PHYLAX_TEST_S3_ACCESS_KEY", "minioadmin"),
+			SecretKey:      envOrDefault("PHYLAX_TEST_S3_SECRET_KEY", "minioadmin"),
+			Prefix:         "it/",
+		}
+
+		storage, err := NewS3(cfg)
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		storage.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: &cfg.Bucket})
+
+		tempDir, err := os.MkdirTemp("", "s3_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, []byte("backup"), 0644), ShouldBeNil)
+
+		Convey("It should upload, list, age and delete objects", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldContain, "backup.sql.gz")
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldContain, "backup.sql.gz")
+
+			So(storage.Delete(ctx, "backup.sql.gz"), ShouldBeNil)
+
+			files, err = storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldNotContain, "backup.sql.gz")
+		})
+	})
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
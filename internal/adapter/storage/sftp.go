@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/semmidev/phylax/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// tempUploadPrefix marks in-progress uploads, which are renamed into place once
+// complete and ignored by List and GetOldFiles.
+const tempUploadPrefix = ".phylax-upload-"
+
+// SFTPStorage implements the Storage interface for a remote directory reached
+// over SSH. A new connection is opened per operation, so long idle periods
+// between scheduled backups never leave a dead session behind.
+type SFTPStorage struct {
+	addr        string
+	sshConfig   *ssh.ClientConfig
+	basePath    string
+	bytesPerSec int64
+}
+
+// NewSFTP creates a new SFTPStorage instance. Host keys are always verified
+// against known_hosts (~/.ssh/known_hosts unless known_hosts_file is set).
+func NewSFTP(cfg *config.UploadTarget) (*SFTPStorage, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("sftp host is required")
+	}
+	if cfg.Username == "" {
+		return nil, errors.New("sftp username is required")
+	}
+	if cfg.Path == "" {
+		return nil, errors.New("sftp remote path is required")
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKeyFile != "" {
+		signer, err := loadPrivateKey(cfg.PrivateKeyFile, cfg.PrivateKeyPass)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("sftp requires private_key_file or password")
+	}
+
+	knownHostsFile := cfg.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+
+	return &SFTPStorage{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		sshConfig: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         30 * time.Second,
+		},
+		basePath:    cfg.Path,
+		bytesPerSec: int64(cfg.BandwidthLimitKBps) * 1024,
+	}, nil
+}
+
+// Upload writes the file under a temporary name and renames it into place,
+// so readers never see a partially written backup.
+func (s *SFTPStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
+	source, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer source.Close()
+
+	client, closeFn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err := client.MkdirAll(s.basePath); err != nil {
+		return fmt.Errorf("failed to create remote directory: %w", err)
+	}
+
+	destPath := path.Join(s.basePath, remoteName)
+	tempPath := path.Join(s.basePath, tempUploadPrefix+remoteName)
+
+	dest, err := client.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file: %w", err)
+	}
+
+	_, err = io.Copy(dest, newThrottledReader(ctx, source, s.bytesPerSec))
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = client.Remove(tempPath)
+		return fmt.Errorf("failed to upload: %w", err)
+	}
+
+	if err := client.PosixRename(tempPath, destPath); err != nil {
+		// Servers without the posix-rename extension refuse to overwrite.
+		_ = client.Remove(destPath)
+		if err := client.Rename(tempPath, destPath); err != nil {
+			_ = client.Remove(tempPath)
+			return fmt.Errorf("failed to rename uploaded file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Download copies remoteName from the remote directory to localPath.
+func (s *SFTPStorage) Download(ctx context.Context, remoteName string, localPath string) error {
+	client, closeFn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	source, err := client.Open(path.Join(s.basePath, remoteName))
+	if err != nil {
+		return fmt.Errorf("failed to open remote file: %w", err)
+	}
+	defer source.Close()
+
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer dest.Close()
+
+	if _, err := io.Copy(dest, newThrottledReader(ctx, source, s.bytesPerSec)); err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+
+	return dest.Close()
+}
+
+func (s *SFTPStorage) List(ctx context.Context) ([]string, error) {
+	entries, err := s.readDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	return files, nil
+}
+
+func (s *SFTPStorage) Delete(ctx context.Context, remoteName string) error {
+	client, closeFn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err := client.Remove(path.Join(s.basePath, remoteName)); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// GetOldFiles returns files whose remote modification time is before
+// cutoffTime.
+func (s *SFTPStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	entries, err := s.readDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldFiles []string
+	for _, entry := range entries {
+		if entry.ModTime().Before(cutoffTime) {
+			oldFiles = append(oldFiles, entry.Name())
+		}
+	}
+	return oldFiles, nil
+}
+
+// readDir lists regular files in the remote directory, skipping in-progress
+// uploads.
+func (s *SFTPStorage) readDir(ctx context.Context) ([]os.FileInfo, error) {
+	client, closeFn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	entries, err := client.ReadDir(s.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote directory: %w", err)
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), tempUploadPrefix) {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+func (s *SFTPStorage) connect(ctx context.Context) (*sftp.Client, func(), error) {
+	dialer := net.Dialer{Timeout: s.sshConfig.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", s.addr, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, s.addr, s.sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("ssh handshake with %s failed: %w", s.addr, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, nil, fmt.Errorf("failed to start sftp session: %w", err)
+	}
+
+	// Abort blocking calls when the context is cancelled.
+	stop := context.AfterFunc(ctx, func() { sshClient.Close() })
+
+	return client, func() {
+		stop()
+		client.Close()
+		sshClient.Close()
+	}, nil
+}
+
+func loadPrivateKey(keyFile, passphrase string) (ssh.Signer, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSFTPServer runs an in-process SSH server with the sftp subsystem that
+// accepts the given password and serves the local filesystem.
+func startSFTPServer(t *testing.T, password string) (net.Listener, ssh.PublicKey) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, errors.New("access denied")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTPConn(conn, serverConfig)
+		}
+	}()
+
+	return listener, hostSigner.PublicKey()
+}
+
+func serveSFTPConn(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel)
+					if err == nil {
+						server.Serve()
+					}
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func TestSFTPStorage(t *testing.T) {
+	Convey("Given an SFTPStorage against an in-process SFTP server", t, func() {
+		tempDir, err := os.MkdirTemp("", "sftp_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		listener, hostKey := startSFTPServer(t, "s3cret")
+		defer listener.Close()
+		addr := listener.Addr().(*net.TCPAddr)
+
+		knownHostsFile := filepath.Join(tempDir, "known_hosts")
+		line := knownhosts.Line([]string{knownhosts.Normalize(addr.String())}, hostKey)
+		So(os.WriteFile(knownHostsFile, []byte(line+"\n"), 0600), ShouldBeNil)
+
+		remoteDir := filepath.Join(tempDir, "remote", "backups")
+		cfg := &config.UploadTarget{
+			Type:           "sftp",
+			Host:           "127.0.0.1",
+			Port:           addr.Port,
+			Username:       "backup",
+			Password:       "s3cret",
+			KnownHostsFile: knownHostsFile,
+			Path:           remoteDir,
+		}
+
+		sourceFile := filepath.Join(tempDir, "source.sql.gz")
+		So(os.WriteFile(sourceFile, []byte("backup content"), 0644), ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("NewSFTP", func() {
+			Convey("When no auth method is configured", func() {
+				cfg.Password = ""
+				_, err := NewSFTP(cfg)
+
+				Convey("It should return error", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "requires private_key_file or password")
+				})
+			})
+		})
+
+		Convey("Upload method", func() {
+			storage, err := NewSFTP(cfg)
+			So(err, ShouldBeNil)
+
+			err = storage.Upload(ctx, sourceFile, "backup.sql.gz")
+
+			Convey("It should create the remote directory and rename into place", func() {
+				So(err, ShouldBeNil)
+
+				content, err := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "backup content")
+
+				_, err = os.Stat(filepath.Join(remoteDir, tempUploadPrefix+"backup.sql.gz"))
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+
+			Convey("It should overwrite an existing file", func() {
+				os.WriteFile(sourceFile, []byte("newer content"), 0644)
+				So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+				content, _ := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+				So(string(content), ShouldEqual, "newer content")
+			})
+		})
+
+		Convey("List, GetOldFiles and Delete methods", func() {
+			storage, err := NewSFTP(cfg)
+			So(err, ShouldBeNil)
+
+			So(os.MkdirAll(filepath.Join(remoteDir, "subdir"), 0755), ShouldBeNil)
+			os.WriteFile(filepath.Join(remoteDir, "old.sql.gz"), []byte("old"), 0644)
+			os.WriteFile(filepath.Join(remoteDir, "new.sql.gz"), []byte("new"), 0644)
+			os.WriteFile(filepath.Join(remoteDir, tempUploadPrefix+"partial.sql.gz"), []byte("x"), 0644)
+			oldTime := time.Now().Add(-10 * 24 * time.Hour)
+			os.Chtimes(filepath.Join(remoteDir, "old.sql.gz"), oldTime, oldTime)
+
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"new.sql.gz", "old.sql.gz"})
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(-7*24*time.Hour))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"old.sql.gz"})
+
+			So(storage.Delete(ctx, "old.sql.gz"), ShouldBeNil)
+			_, err = os.Stat(filepath.Join(remoteDir, "old.sql.gz"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("When the host key is not in known_hosts", func() {
+			os.WriteFile(knownHostsFile, nil, 0600)
+			storage, err := NewSFTP(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = storage.List(ctx)
+
+			Convey("It should refuse to connect", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "ssh handshake")
+			})
+		})
+
+		Convey("When the password is wrong", func() {
+			cfg.Password = "wrong"
+			storage, err := NewSFTP(cfg)
+			So(err, ShouldBeNil)
+
+			_, err = storage.List(ctx)
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestThrottledReader(t *testing.T) {
+	Convey("Given a reader limited to 10 KB/s", t, func() {
+		data := make([]byte, 5*1024)
+		src := newThrottledReader(context.Background(), &sliceReader{data: data}, 10*1024)
+
+		Convey("It should take about half a second to read 5 KB", func() {
+			start := time.Now()
+			buf := make([]byte, 32*1024)
+			total := 0
+			for {
+				n, err := src.Read(buf)
+				total += n
+				if err != nil {
+					break
+				}
+			}
+			So(total, ShouldEqual, len(data))
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 400*time.Millisecond)
+		})
+	})
+}
+
+type sliceReader struct {
+	data []byte
+}
+
+func (s *sliceReader) Read(p []byte) (int, error) {
+	if len(s.data) == 0 {
+		return 0, errors.New("EOF")
+	}
+	n := copy(p, s.data)
+	s.data = s.data[n:]
+	return n, nil
+}
@@ -2,23 +2,46 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
 )
 
+// defaultPartSizeMB keeps every part safely under the 50 MB upload limit of
+// the public Bot API.
+const defaultPartSizeMB = 45
+
+// errMessageCannotBeDeleted is the Bot API error for messages a bot may not
+// delete: those older than 48 hours, unless it is an admin with delete rights.
+const errMessageCannotBeDeleted = "message can't be deleted"
+
 type TelegramStorage struct {
 	bot        *tgbotapi.BotAPI
 	chatID     int64
 	sendFile   bool
 	notifyOnly bool
+	partSize   int64
+	index      *fileIndex
+	logger     *logger.Logger
 }
 
-func NewTelegram(cfg *config.UploadTarget) (*TelegramStorage, error) {
-	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
+func NewTelegram(cfg *config.UploadTarget, dataDir string, logger *logger.Logger) (*TelegramStorage, error) {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.BotToken, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create telegram bot: %w", err)
 	}
@@ -26,11 +49,30 @@ func NewTelegram(cfg *config.UploadTarget) (*TelegramStorage, error) {
 	var chatID int64
 	fmt.Sscanf(cfg.ChatID, "%d", &chatID)
 
+	partSizeMB := cfg.PartSizeMB
+	if partSizeMB <= 0 {
+		partSizeMB = defaultPartSizeMB
+	}
+
+	// The bot API cannot enumerate a chat, so uploaded message IDs are
+	// recorded locally for List, Delete and GetOldFiles.
+	indexFile := cfg.IndexFile
+	if indexFile == "" {
+		indexFile = filepath.Join(dataDir, fmt.Sprintf("telegram_%d.json", chatID))
+	}
+	index, err := newFileIndex(indexFile)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TelegramStorage{
 		bot:        bot,
 		chatID:     chatID,
 		sendFile:   cfg.SendFile,
 		notifyOnly: cfg.NotifyOnly,
+		partSize:   int64(partSizeMB) * 1024 * 1024,
+		index:      index,
+		logger:     logger,
 	}, nil
 }
 
@@ -42,7 +84,7 @@ func (t *TelegramStorage) Upload(ctx context.Context, localPath string, remoteNa
 
 	fileSizeMB := float64(fileInfo.Size()) / (1024 * 1024)
 
-	if t.notifyOnly || !t.sendFile || fileSizeMB > 50 {
+	if t.notifyOnly || !t.sendFile {
 		// Send notification only
 		message := fmt.Sprintf(
 			"✅ Backup Created\n\n"+
@@ -59,37 +101,149 @@ func (t *TelegramStorage) Upload(ctx context.Context, localPath string, remoteNa
 		if err != nil {
 			return fmt.Errorf("failed to send telegram notification: %w", err)
 		}
-	} else {
-		// Send file (for files < 50MB)
-		file := tgbotapi.NewDocument(t.chatID, tgbotapi.FilePath(localPath))
-		file.Caption = fmt.Sprintf("📦 Backup: %s (%.2f MB)", remoteName, fileSizeMB)
+		return nil
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	size := fileInfo.Size()
+	parts := int((size + t.partSize - 1) / t.partSize)
+	if parts == 0 {
+		parts = 1
+	}
+
+	var messageIDs []string
+	for i := 0; i < parts; i++ {
+		if err := ctx.Err(); err != nil {
+			t.deleteMessages(messageIDs)
+			return err
+		}
+
+		offset := int64(i) * t.partSize
+		length := min(t.partSize, size-offset)
+
+		name, caption := remoteName, fmt.Sprintf("📦 Backup: %s (%.2f MB)\n🔐 SHA256: %s", remoteName, fileSizeMB, checksum)
+		if parts > 1 {
+			name = fmt.Sprintf("%s.%03d", remoteName, i+1)
+			caption = fmt.Sprintf(
+				"📦 Backup: %s\n"+
+					"🧩 Part %d/%d (%.2f MB)\n"+
+					"📊 Total: %.2f MB\n"+
+					"🔐 SHA256: %s",
+				remoteName, i+1, parts, float64(length)/(1024*1024), fileSizeMB, checksum,
+			)
+		}
+
+		doc := tgbotapi.NewDocument(t.chatID, tgbotapi.FileReader{
+			Name:   name,
+			Reader: io.NewSectionReader(file, offset, length),
+		})
+		doc.Caption = caption
 
-		_, err = t.bot.Send(file)
+		msg, err := t.bot.Send(doc)
 		if err != nil {
-			return fmt.Errorf("failed to send telegram file: %w", err)
+			t.deleteMessages(messageIDs)
+			return fmt.Errorf("failed to send telegram file part %d/%d: %w", i+1, parts, err)
 		}
+		messageIDs = append(messageIDs, strconv.Itoa(msg.MessageID))
+	}
+
+	if err := t.index.put(indexEntry{
+		Name:      remoteName,
+		Size:      size,
+		SHA256:    checksum,
+		CreatedAt: time.Now(),
+		Refs:      messageIDs,
+	}); err != nil {
+		return fmt.Errorf("failed to record upload in index: %w", err)
 	}
 
 	return nil
 }
 
 func (t *TelegramStorage) List(ctx context.Context) ([]string, error) {
-	// Telegram doesn't support listing files
-	return []string{}, nil
+	entries, err := t.index.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, e.Name)
+	}
+	return files, nil
 }
 
 func (t *TelegramStorage) Delete(ctx context.Context, remoteName string) error {
-	// Telegram doesn't support deleting files
-	return nil
+	entry, ok, err := t.index.get(remoteName)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("file not found in telegram index: %s", remoteName)
+	}
+
+	remaining, undeletable := t.deleteMessages(entry.Refs)
+	if len(undeletable) > 0 {
+		// Retrying would fail the same way on every cleanup, so forget the
+		// messages and leave them in the chat.
+		t.logger.Warnf("Telegram refused to delete %d message(s) of %s; the bot must be a chat admin with delete rights to remove messages older than 48 hours. Dropping them from the index.",
+			len(undeletable), remoteName)
+	}
+	if len(remaining) > 0 {
+		entry.Refs = remaining
+		if err := t.index.put(entry); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to delete %d telegram message(s) for %s", len(remaining), remoteName)
+	}
+
+	return t.index.remove(remoteName)
 }
 
 func (t *TelegramStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
-	// Telegram doesn't support getting old files
-	return []string{}, nil
+	entries, err := t.index.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var oldFiles []string
+	for _, e := range entries {
+		if e.CreatedAt.Before(cutoffTime) {
+			oldFiles = append(oldFiles, e.Name)
+		}
+	}
+	return oldFiles, nil
 }
 
-func (t *TelegramStorage) SendNotification(message string) error {
-	msg := tgbotapi.NewMessage(t.chatID, message)
-	_, err := t.bot.Send(msg)
-	return err
+// deleteMessages removes the given messages from the chat. It returns the IDs
+// that failed to delete and may succeed on a retry, and the IDs the Bot API
+// will never let this bot delete. Messages that are already gone count as
+// deleted.
+func (t *TelegramStorage) deleteMessages(messageIDs []string) (remaining, undeletable []string) {
+	for _, ref := range messageIDs {
+		id, err := strconv.Atoi(ref)
+		if err != nil {
+			continue
+		}
+		_, err = t.bot.Request(tgbotapi.NewDeleteMessage(t.chatID, id))
+		switch {
+		case err == nil, strings.Contains(err.Error(), "message to delete not found"):
+		case strings.Contains(err.Error(), errMessageCannotBeDeleted):
+			undeletable = append(undeletable, ref)
+		default:
+			remaining = append(remaining, ref)
+		}
+	}
+	return remaining, undeletable
 }
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeBotAPI answers the Bot API methods used by TelegramStorage.
+type fakeBotAPI struct {
+	mu        sync.Mutex
+	nextID    int
+	documents []fakeDocument
+	deleted   []string
+	// undeletable messages are refused as if older than 48 hours.
+	undeletable map[string]bool
+}
+
+type fakeDocument struct {
+	name    string
+	caption string
+	content []byte
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getMe"):
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"phylax"}}`)
+
+	case strings.HasSuffix(r.URL.Path, "/sendDocument"):
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			fmt.Fprintf(w, `{"ok":false,"description":%q}`, err.Error())
+			return
+		}
+		file, header, _ := r.FormFile("document")
+		content, _ := io.ReadAll(file)
+		f.documents = append(f.documents, fakeDocument{
+			name:    header.Filename,
+			caption: r.FormValue("caption"),
+			content: content,
+		})
+		f.nextID++
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":42}}}`, f.nextID)
+
+	case strings.HasSuffix(r.URL.Path, "/deleteMessage"):
+		r.ParseForm()
+		id := r.Form.Get("message_id")
+		if f.undeletable[id] {
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: message can't be deleted"}`)
+			return
+		}
+		f.deleted = append(f.deleted, id)
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+
+	default:
+		fmt.Fprint(w, `{"ok":false,"error_code":404,"description":"Not Found"}`)
+	}
+}
+
+func TestTelegramStorage(t *testing.T) {
+	Convey("Given a TelegramStorage sending files", t, func() {
+		tempDir, err := os.MkdirTemp("", "telegram_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		log, err := logger.New("fatal", "")
+		So(err, ShouldBeNil)
+
+		api := &fakeBotAPI{}
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		storage, err := NewTelegram(&config.UploadTarget{
+			Type:        "telegram",
+			BotToken:    "123:abc",
+			ChatID:      "42",
+			SendFile:    true,
+			APIEndpoint: server.URL + "/bot%s/%s",
+		}, tempDir, log)
+		So(err, ShouldBeNil)
+		So(storage.index.path, ShouldEqual, filepath.Join(tempDir, "telegram_42.json"))
+
+		content := []byte(strings.Repeat("0123456789", 25))
+		sourceFile := filepath.Join(tempDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, content, 0644), ShouldBeNil)
+
+		sum := sha256.Sum256(content)
+		checksum := hex.EncodeToString(sum[:])
+		ctx := context.Background()
+
+		Convey("When the file fits in one message", func() {
+			err := storage.Upload(ctx, sourceFile, "backup.sql.gz")
+
+			Convey("It should send a single document and index it", func() {
+				So(err, ShouldBeNil)
+				So(len(api.documents), ShouldEqual, 1)
+				So(api.documents[0].name, ShouldEqual, "backup.sql.gz")
+				So(api.documents[0].caption, ShouldContainSubstring, checksum)
+
+				files, err := storage.List(ctx)
+				So(err, ShouldBeNil)
+				So(files, ShouldResemble, []string{"backup.sql.gz"})
+			})
+		})
+
+		Convey("When the file exceeds the part size", func() {
+			storage.partSize = 100
+			err := storage.Upload(ctx, sourceFile, "backup.sql.gz")
+
+			Convey("It should send numbered parts with a manifest", func() {
+				So(err, ShouldBeNil)
+				So(len(api.documents), ShouldEqual, 3)
+
+				var joined []byte
+				for i, doc := range api.documents {
+					So(doc.name, ShouldEqual, fmt.Sprintf("backup.sql.gz.%03d", i+1))
+					So(doc.caption, ShouldContainSubstring, fmt.Sprintf("Part %d/3", i+1))
+					So(doc.caption, ShouldContainSubstring, checksum)
+					joined = append(joined, doc.content...)
+				}
+				So(joined, ShouldResemble, content)
+
+				entry, ok, err := storage.index.get("backup.sql.gz")
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(entry.Refs, ShouldResemble, []string{"1", "2", "3"})
+				So(entry.SHA256, ShouldEqual, checksum)
+			})
+
+			Convey("Delete should remove every part", func() {
+				So(storage.Delete(ctx, "backup.sql.gz"), ShouldBeNil)
+				So(api.deleted, ShouldResemble, []string{"1", "2", "3"})
+
+				files, err := storage.List(ctx)
+				So(err, ShouldBeNil)
+				So(len(files), ShouldEqual, 0)
+			})
+		})
+
+		Convey("GetOldFiles method", func() {
+			So(storage.index.put(indexEntry{Name: "old.sql.gz", CreatedAt: time.Now().Add(-10 * 24 * time.Hour)}), ShouldBeNil)
+			So(storage.index.put(indexEntry{Name: "new.sql.gz", CreatedAt: time.Now()}), ShouldBeNil)
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(-7*24*time.Hour))
+
+			Convey("It should return only indexed files older than the cutoff", func() {
+				So(err, ShouldBeNil)
+				So(oldFiles, ShouldResemble, []string{"old.sql.gz"})
+			})
+		})
+
+		Convey("When the Bot API refuses to delete old messages", func() {
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+			api.undeletable = map[string]bool{"1": true}
+
+			err := storage.Delete(ctx, "backup.sql.gz")
+
+			Convey("It should drop the file from the index", func() {
+				So(err, ShouldBeNil)
+				files, err := storage.List(ctx)
+				So(err, ShouldBeNil)
+				So(files, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When deleting a file that is not indexed", func() {
+			err := storage.Delete(ctx, "missing.sql.gz")
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "not found in telegram index")
+			})
+		})
+	})
+}
@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+)
+
+// defaultWebDAVChunkSize is the size of each Nextcloud upload chunk. Nextcloud
+// requires chunks of at least 5 MiB, except for the last one.
+const defaultWebDAVChunkSize = 10 << 20
+
+// WebDAVStorage implements the Storage interface for a WebDAV folder, such as
+// a Nextcloud or ownCloud share.
+type WebDAVStorage struct {
+	client     *http.Client
+	baseURL    *url.URL
+	basePath   string
+	username   string
+	password   string
+	uploadsURL *url.URL
+	chunkSize  int64
+}
+
+// NewWebDAV creates a new WebDAVStorage instance. endpoint is the WebDAV root,
+// e.g. https://cloud.example.com/remote.php/dav/files/alice/, and path the
+// folder below it. For Nextcloud, use an app password.
+func NewWebDAV(cfg *config.UploadTarget) (*WebDAVStorage, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("webdav endpoint is required")
+	}
+
+	baseURL, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webdav endpoint: %w", err)
+	}
+
+	s := &WebDAVStorage{
+		client:    &http.Client{},
+		baseURL:   baseURL,
+		basePath:  strings.Trim(cfg.Path, "/"),
+		username:  cfg.Username,
+		password:  cfg.Password,
+		chunkSize: defaultWebDAVChunkSize,
+	}
+	if cfg.BlockSizeMB > 0 {
+		s.chunkSize = int64(cfg.BlockSizeMB) << 20
+	}
+
+	if cfg.ChunkedUpload {
+		s.uploadsURL, err = nextcloudUploadsURL(baseURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// nextcloudUploadsURL derives the chunked upload root
+// (/remote.php/dav/uploads/<user>) from a files endpoint
+// (/remote.php/dav/files/<user>).
+func nextcloudUploadsURL(baseURL *url.URL) (*url.URL, error) {
+	before, after, found := strings.Cut(baseURL.Path, "/remote.php/dav/files/")
+	user, _, _ := strings.Cut(after, "/")
+	if !found || user == "" {
+		return nil, errors.New("chunked_upload requires a Nextcloud endpoint ending in /remote.php/dav/files/<user>/")
+	}
+
+	uploadsURL := *baseURL
+	uploadsURL.Path = before + "/remote.php/dav/uploads/" + user
+	uploadsURL.RawPath = ""
+	return &uploadsURL, nil
+}
+
+// Upload creates the target folder if needed and PUTs the file. With
+// chunked_upload, files larger than one chunk go through Nextcloud's chunked
+// upload API instead, so a single request never has to carry the whole file.
+func (s *WebDAVStorage) Upload(ctx context.Context, localPath string, remoteName string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if err := s.mkdirAll(ctx); err != nil {
+		return err
+	}
+
+	dest := s.url(s.basePath, remoteName)
+	if s.uploadsURL != nil && info.Size() > s.chunkSize {
+		return s.uploadChunked(ctx, file, info.Size(), dest)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, dest, file, func(req *http.Request) {
+		req.ContentLength = info.Size()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to WebDAV: %w", err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// uploadChunked implements Nextcloud chunked upload v2: the chunks are
+// written to a temporary upload folder and assembled with a final MOVE.
+func (s *WebDAVStorage) uploadChunked(ctx context.Context, file *os.File, size int64, dest string) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate upload id: %w", err)
+	}
+	uploadURL := s.uploadsURL.JoinPath("phylax-" + hex.EncodeToString(id)).String()
+
+	withDest := func(req *http.Request) {
+		req.Header.Set("Destination", dest)
+		req.Header.Set("OC-Total-Length", strconv.FormatInt(size, 10))
+	}
+
+	resp, err := s.do(ctx, "MKCOL", uploadURL, nil, withDest)
+	if err != nil {
+		return fmt.Errorf("failed to start chunked upload: %w", err)
+	}
+	resp.Body.Close()
+
+	err = func() error {
+		for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+s.chunkSize {
+			length := min(s.chunkSize, size-offset)
+			resp, err := s.do(ctx, http.MethodPut, fmt.Sprintf("%s/%05d", uploadURL, n),
+				io.NewSectionReader(file, offset, length), func(req *http.Request) {
+					withDest(req)
+					req.ContentLength = length
+				})
+			if err != nil {
+				return fmt.Errorf("failed to upload chunk %d: %w", n, err)
+			}
+			resp.Body.Close()
+		}
+
+		resp, err := s.do(ctx, "MOVE", uploadURL+"/.file", nil, func(req *http.Request) {
+			withDest(req)
+			req.Header.Set("Overwrite", "T")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to assemble chunks: %w", err)
+		}
+		resp.Body.Close()
+		return nil
+	}()
+	if err != nil {
+		// Best effort; Nextcloud also expires abandoned uploads on its own.
+		if resp, delErr := s.do(context.WithoutCancel(ctx), http.MethodDelete, uploadURL, nil, nil); delErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	return nil
+}
+
+// List returns all files in the folder
+func (s *WebDAVStorage) List(ctx context.Context) ([]string, error) {
+	entries, err := s.propfind(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.name)
+	}
+	return files, nil
+}
+
+// Delete removes a file
+func (s *WebDAVStorage) Delete(ctx context.Context, remoteName string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.url(s.basePath, remoteName), nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete from WebDAV: %w", err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// GetOldFiles returns files whose getlastmodified is before cutoffTime
+func (s *WebDAVStorage) GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error) {
+	entries, err := s.propfind(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldFiles []string
+	for _, entry := range entries {
+		if entry.modified.Before(cutoffTime) {
+			oldFiles = append(oldFiles, entry.name)
+		}
+	}
+	return oldFiles, nil
+}
+
+type webdavEntry struct {
+	name     string
+	modified time.Time
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				LastModified string    `xml:"getlastmodified"`
+				Collection   *struct{} `xml:"resourcetype>collection"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfind lists the files directly inside the folder, skipping the folder
+// itself and any subfolders.
+func (s *WebDAVStorage) propfind(ctx context.Context) ([]webdavEntry, error) {
+	resp, err := s.do(ctx, "PROPFIND", strings.TrimSuffix(s.url(s.basePath), "/")+"/", strings.NewReader(propfindBody), func(req *http.Request) {
+		req.Header.Set("Depth", "1")
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WebDAV folder: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to parse PROPFIND response: %w", err)
+	}
+
+	var entries []webdavEntry
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") || ps.Prop.Collection != nil {
+				continue
+			}
+
+			href, err := url.PathUnescape(r.Href)
+			if err != nil {
+				href = r.Href
+			}
+			modified, err := http.ParseTime(ps.Prop.LastModified)
+			if err != nil {
+				return nil, fmt.Errorf("invalid getlastmodified for %s: %w", href, err)
+			}
+
+			entries = append(entries, webdavEntry{name: path.Base(href), modified: modified})
+		}
+	}
+
+	return entries, nil
+}
+
+// mkdirAll creates each folder along the base path with MKCOL. An existing
+// folder answers 405 Method Not Allowed.
+func (s *WebDAVStorage) mkdirAll(ctx context.Context) error {
+	if s.basePath == "" {
+		return nil
+	}
+
+	var dir string
+	for _, segment := range strings.Split(s.basePath, "/") {
+		dir = path.Join(dir, segment)
+
+		resp, err := s.send(ctx, "MKCOL", s.url(dir), nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create folder %s: %w", dir, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create folder %s: %s", dir, resp.Status)
+		}
+	}
+
+	return nil
+}
+
+// do sends an authenticated request and turns non-2xx responses into errors.
+func (s *WebDAVStorage) do(ctx context.Context, method, target string, body io.Reader, prepare func(*http.Request)) (*http.Response, error) {
+	resp, err := s.send(ctx, method, target, body, prepare)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s %s", method, target, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+// send sends an authenticated request without checking the response status.
+func (s *WebDAVStorage) send(ctx context.Context, method, target string, body io.Reader, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+
+	return s.client.Do(req)
+}
+
+func (s *WebDAVStorage) url(elems ...string) string {
+	return s.baseURL.JoinPath(elems...).String()
+}
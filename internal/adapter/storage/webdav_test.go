@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/config"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer serves root over WebDAV under /remote.php/dav with basic
+// auth, and emulates Nextcloud's chunked upload MOVE of "<upload>/.file".
+func newWebDAVServer(root string, chunkPuts *int) *httptest.Server {
+	const prefix = "/remote.php/dav"
+	handler := &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: webdav.Dir(root),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.Contains(r.URL.Path, "/uploads/") && r.Method == http.MethodPut {
+			if r.Header.Get("Destination") == "" {
+				http.Error(w, "missing Destination", http.StatusBadRequest)
+				return
+			}
+			*chunkPuts++
+		}
+
+		if r.Method == "MOVE" && strings.HasSuffix(r.URL.Path, "/.file") {
+			uploadDir := filepath.Join(root, strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/.file"), prefix))
+			dest, _ := url.Parse(r.Header.Get("Destination"))
+
+			chunks, _ := os.ReadDir(uploadDir)
+			var data bytes.Buffer
+			for _, chunk := range chunks {
+				content, _ := os.ReadFile(filepath.Join(uploadDir, chunk.Name()))
+				data.Write(content)
+			}
+			os.WriteFile(filepath.Join(root, strings.TrimPrefix(dest.Path, prefix)), data.Bytes(), 0644)
+			os.RemoveAll(uploadDir)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func TestWebDAVStorage(t *testing.T) {
+	Convey("Given a WebDAVStorage against an in-process WebDAV server", t, func() {
+		root, err := os.MkdirTemp("", "webdav_storage_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+
+		So(os.MkdirAll(filepath.Join(root, "files", "alice"), 0755), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join(root, "uploads", "alice"), 0755), ShouldBeNil)
+
+		var chunkPuts int
+		server := newWebDAVServer(root, &chunkPuts)
+		defer server.Close()
+
+		cfg := &config.UploadTarget{
+			Type:     "webdav",
+			Endpoint: server.URL + "/remote.php/dav/files/alice/",
+			Path:     "Backups/phylax db",
+			Username: "alice",
+			Password: "app-password",
+		}
+		remoteDir := filepath.Join(root, "files", "alice", "Backups", "phylax db")
+
+		sourceDir, err := os.MkdirTemp("", "webdav_source")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(sourceDir)
+
+		sourceFile := filepath.Join(sourceDir, "backup.sql.gz")
+		So(os.WriteFile(sourceFile, []byte("backup content"), 0644), ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("Upload should create missing folders and PUT the file", func() {
+			storage, err := NewWebDAV(cfg)
+			So(err, ShouldBeNil)
+
+			So(storage.Upload(ctx, sourceFile, "backup.sql.gz"), ShouldBeNil)
+
+			content, err := os.ReadFile(filepath.Join(remoteDir, "backup.sql.gz"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "backup content")
+			So(chunkPuts, ShouldEqual, 0)
+
+			Convey("A second upload should reuse the existing folders", func() {
+				So(storage.Upload(ctx, sourceFile, "second.sql.gz"), ShouldBeNil)
+			})
+		})
+
+		Convey("Upload with chunked_upload should assemble Nextcloud chunks", func() {
+			cfg.ChunkedUpload = true
+			cfg.BlockSizeMB = 1
+			storage, err := NewWebDAV(cfg)
+			So(err, ShouldBeNil)
+
+			large := bytes.Repeat([]byte("0123456789abcdef"), 160*1024)
+			So(os.WriteFile(sourceFile, large, 0644), ShouldBeNil)
+
+			So(storage.Upload(ctx, sourceFile, "large.sql.gz"), ShouldBeNil)
+
+			content, err := os.ReadFile(filepath.Join(remoteDir, "large.sql.gz"))
+			So(err, ShouldBeNil)
+			So(bytes.Equal(content, large), ShouldBeTrue)
+			So(chunkPuts, ShouldEqual, 3)
+
+			leftovers, _ := os.ReadDir(filepath.Join(root, "uploads", "alice"))
+			So(leftovers, ShouldBeEmpty)
+		})
+
+		Convey("List, GetOldFiles and Delete should use PROPFIND and DELETE", func() {
+			storage, err := NewWebDAV(cfg)
+			So(err, ShouldBeNil)
+
+			So(os.MkdirAll(filepath.Join(remoteDir, "subfolder"), 0755), ShouldBeNil)
+			os.WriteFile(filepath.Join(remoteDir, "old.sql.gz"), []byte("old"), 0644)
+			os.WriteFile(filepath.Join(remoteDir, "new.sql.gz"), []byte("new"), 0644)
+			oldTime := time.Now().Add(-10 * 24 * time.Hour)
+			os.Chtimes(filepath.Join(remoteDir, "old.sql.gz"), oldTime, oldTime)
+
+			files, err := storage.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+			So(files, ShouldContain, "old.sql.gz")
+			So(files, ShouldContain, "new.sql.gz")
+
+			oldFiles, err := storage.GetOldFiles(ctx, time.Now().Add(-7*24*time.Hour))
+			So(err, ShouldBeNil)
+			So(oldFiles, ShouldResemble, []string{"old.sql.gz"})
+
+			So(storage.Delete(ctx, "old.sql.gz"), ShouldBeNil)
+			_, err = os.Stat(filepath.Join(remoteDir, "old.sql.gz"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("When the password is wrong", func() {
+			cfg.Password = "wrong"
+			storage, err := NewWebDAV(cfg)
+			So(err, ShouldBeNil)
+
+			err = storage.Upload(ctx, sourceFile, "backup.sql.gz")
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "401")
+			})
+		})
+
+		Convey("When chunked_upload is set on a non-Nextcloud endpoint", func() {
+			cfg.Endpoint = server.URL + "/dav/"
+			cfg.ChunkedUpload = true
+			_, err := NewWebDAV(cfg)
+
+			Convey("It should return error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "chunked_upload requires a Nextcloud endpoint")
+			})
+		})
+	})
+}
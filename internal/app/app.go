@@ -4,14 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
 
 	"github.com/semmidev/phylax/internal/adapter/compressor"
 	"github.com/semmidev/phylax/internal/adapter/database"
+	"github.com/semmidev/phylax/internal/adapter/notifier"
+	"github.com/semmidev/phylax/internal/adapter/repository"
 	"github.com/semmidev/phylax/internal/adapter/storage"
 	"github.com/semmidev/phylax/internal/config"
 	"github.com/semmidev/phylax/internal/domain"
 	"github.com/semmidev/phylax/internal/infrastructure/logger"
 	"github.com/semmidev/phylax/internal/infrastructure/scheduler"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
 	"github.com/semmidev/phylax/internal/usecase"
 )
 
@@ -21,9 +27,20 @@ type App struct {
 	logger        *logger.Logger
 	scheduler     *scheduler.Scheduler
 	uploadTargets []usecase.UploadTarget
+	notifyTargets []usecase.NotifyTarget
 	backupJobs    []domain.BackupJob
 	cleanupUC     *usecase.Cleanup
 	oauthService  OAuthService
+
+	binlogArchivers []*usecase.BinlogArchiver
+	background      sync.WaitGroup
+}
+
+// summaryNotifier is implemented by notifiers that batch events into a
+// periodic digest.
+type summaryNotifier interface {
+	SummarySchedule() string
+	SendSummary(ctx context.Context) error
 }
 
 // New creates a new App instance.
@@ -41,30 +58,42 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 	log.Infof("Starting %s", cfg.App.Name)
 	log.Infof("Found %d database(s) configured", len(cfg.EnabledDatabases()))
 
-	// Initialize OAuth service if Google Drive is enabled
+	// Initialize OAuth service if a Google Drive target authorizes through
+	// the browser; service accounts need no callback server.
 	var oauthService OAuthService
-	if cfg.HasUploadTarget("gdrive") {
-		oauthService, err = NewGoogleOAuthService(log, "client_secret.json")
+	var tokens *tokenstore.Store
+	oauthTargets := gdriveOAuthTargets(cfg)
+	if len(oauthTargets) > 0 {
+		tokens, err = openTokenStore(cfg)
+		if err != nil {
+			log.Errorf("Failed to open OAuth token store: %v", err)
+		}
+	}
+	if tokens != nil {
+		oauthService, err = NewGoogleOAuthService(log, tokens, oauthTargets)
 		if err != nil {
 			log.Errorf("Failed to initialize Google Drive OAuth service: %v", err)
+			oauthService = nil
 		} else {
 			log.Infof("Google Drive OAuth service initialized")
-			addr := fmt.Sprintf(":%d", cfg.App.Port)
+			addr := fmt.Sprintf("%s:%d", cfg.App.BindAddress, cfg.App.Port)
 			if err := oauthService.StartAuthServer(ctx, addr); err != nil {
 				log.Errorf("Failed to start OAuth server: %v", err)
 			}
 		}
 	}
 
-	comp := compressor.NewGzip()
-	uploadTargets := initializeUploadTargets(cfg, log, oauthService)
-	backupJobs := initializeBackupJobs(cfg, uploadTargets, comp, log)
+	uploadTargets := initializeUploadTargets(cfg, log, tokens)
+	notifyTargets := initializeNotifiers(cfg, log)
+	backupJobs := initializeBackupJobs(cfg, uploadTargets, notifyTargets, log)
 
 	if len(backupJobs) == 0 {
 		return nil, fmt.Errorf("no enabled databases found")
 	}
 
-	cleanupUC := usecase.NewCleanup(uploadTargets, log, cfg.Backup.RetentionDays)
+	binlogArchivers := initializeBinlogArchivers(cfg, uploadTargets, log)
+
+	cleanupUC := usecase.NewCleanup(uploadTargets, notifyTargets, log, cfg.Backup.RetentionDays)
 	sched := scheduler.New()
 
 	return &App{
@@ -72,9 +101,12 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 		logger:        log,
 		scheduler:     sched,
 		uploadTargets: uploadTargets,
+		notifyTargets: notifyTargets,
 		backupJobs:    backupJobs,
 		cleanupUC:     cleanupUC,
 		oauthService:  oauthService,
+
+		binlogArchivers: binlogArchivers,
 	}, nil
 }
 
@@ -101,9 +133,39 @@ func (a *App) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to schedule cleanup: %w", err)
 	}
 
+	for _, target := range a.notifyTargets {
+		summary, ok := target.Notifier.(summaryNotifier)
+		if !ok || summary.SummarySchedule() == "" {
+			continue
+		}
+
+		name := target.Name
+		a.logger.Infof("Scheduling %s summary: %s", name, summary.SummarySchedule())
+		if err := a.scheduler.AddJob(summary.SummarySchedule(), func(ctx context.Context) error {
+			if err := summary.SendSummary(ctx); err != nil {
+				a.logger.Errorf("Failed to send %s summary: %v", name, err)
+				return err
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to schedule %s summary: %w", target.Name, err)
+		}
+	}
+
+	for _, archiver := range a.binlogArchivers {
+		a.background.Add(1)
+		go func() {
+			defer a.background.Done()
+			if err := archiver.Run(ctx); err != nil {
+				a.logger.Errorf("Binlog archiver stopped: %v", err)
+			}
+		}()
+	}
+
 	a.scheduler.Start()
 	a.logger.Infof("Scheduler started successfully")
 	a.logger.Infof("Backup destinations: %d remote target(s)", len(a.uploadTargets))
+	a.logger.Infof("Notification channels: %d", len(a.notifyTargets))
 
 	<-ctx.Done()
 	return nil
@@ -113,6 +175,7 @@ func (a *App) Run(ctx context.Context) error {
 func (a *App) Shutdown(ctx context.Context) {
 	a.logger.Infof("Shutting down application...")
 	a.scheduler.Stop()
+	a.background.Wait()
 
 	if a.oauthService != nil {
 		if err := a.oauthService.Shutdown(ctx); err != nil {
@@ -124,7 +187,7 @@ func (a *App) Shutdown(ctx context.Context) {
 }
 
 // initializeUploadTargets creates upload targets based on configuration.
-func initializeUploadTargets(cfg *config.Config, log *logger.Logger, oauthService OAuthService) []usecase.UploadTarget {
+func initializeUploadTargets(cfg *config.Config, log *logger.Logger, tokens *tokenstore.Store) []usecase.UploadTarget {
 	var targets []usecase.UploadTarget
 
 	for _, targetCfg := range cfg.EnabledUploadTargets() {
@@ -133,16 +196,16 @@ func initializeUploadTargets(cfg *config.Config, log *logger.Logger, oauthServic
 
 		switch targetCfg.Type {
 		case "gdrive":
-			if oauthService == nil {
-				log.Errorf("Google Drive OAuth service not initialized for target: %s", targetCfg.Type)
-				continue
-			}
-			stor, err = storage.NewGDrive(context.Background(), &targetCfg, oauthService.GetConfig(), log)
+			stor, err = storage.NewGDrive(context.Background(), &targetCfg, cfg.App.DataDir, tokens, log)
 			if err != nil {
 				log.Errorf("Failed to initialize Google Drive: %v", err)
 				continue
 			}
-			log.Infof("✓ Google Drive upload enabled")
+			if targetCfg.Auth == "service_account" {
+				log.Infof("✓ Google Drive upload enabled (service account)")
+			} else {
+				log.Infof("✓ Google Drive upload enabled")
+			}
 
 		case "s3":
 			stor, err = storage.NewS3(&targetCfg)
@@ -152,14 +215,62 @@ func initializeUploadTargets(cfg *config.Config, log *logger.Logger, oauthServic
 			}
 			log.Infof("✓ AWS S3 upload enabled (bucket: %s)", targetCfg.Bucket)
 
+		case "gcs":
+			stor, err = storage.NewGCS(context.Background(), &targetCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize GCS: %v", err)
+				continue
+			}
+			log.Infof("✓ Google Cloud Storage upload enabled (bucket: %s)", targetCfg.Bucket)
+
+		case "azure":
+			stor, err = storage.NewAzure(&targetCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize Azure: %v", err)
+				continue
+			}
+			log.Infof("✓ Azure Blob upload enabled (container: %s)", targetCfg.Container)
+
+		case "webdav":
+			stor, err = storage.NewWebDAV(&targetCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize WebDAV: %v", err)
+				continue
+			}
+			log.Infof("✓ WebDAV upload enabled (%s)", targetCfg.Endpoint)
+
 		case "telegram":
-			stor, err = storage.NewTelegram(&targetCfg)
+			stor, err = storage.NewTelegram(&targetCfg, cfg.App.DataDir, log)
 			if err != nil {
 				log.Errorf("Failed to initialize Telegram: %v", err)
 				continue
 			}
 			log.Infof("✓ Telegram upload enabled")
 
+		case "sftp":
+			stor, err = storage.NewSFTP(&targetCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize SFTP: %v", err)
+				continue
+			}
+			log.Infof("✓ SFTP upload enabled (%s@%s:%s)", targetCfg.Username, targetCfg.Host, targetCfg.Path)
+
+		case "ftp":
+			stor, err = storage.NewFTP(&targetCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize FTP: %v", err)
+				continue
+			}
+			log.Infof("✓ FTP upload enabled (%s:%s)", targetCfg.Host, targetCfg.Path)
+
+		case "rclone":
+			stor, err = storage.NewRclone(&targetCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize rclone: %v", err)
+				continue
+			}
+			log.Infof("✓ rclone upload enabled (%s)", targetCfg.Remote)
+
 		case "local":
 			stor, err = storage.NewLocal(targetCfg.Path)
 			if err != nil {
@@ -173,9 +284,66 @@ func initializeUploadTargets(cfg *config.Config, log *logger.Logger, oauthServic
 			continue
 		}
 
+		if targetCfg.Repository {
+			stor, err = repository.New(stor, log)
+			if err != nil {
+				log.Errorf("Failed to initialize repository on %s: %v", targetCfg.Type, err)
+				continue
+			}
+			log.Infof("✓ Deduplicating repository enabled on %s", targetCfg.Type)
+		}
+
 		targets = append(targets, usecase.UploadTarget{
 			Name:    targetCfg.Type,
 			Storage: stor,
+			Raw:     targetCfg.Repository,
+		})
+	}
+
+	return targets
+}
+
+// initializeNotifiers creates notifiers based on configuration.
+func initializeNotifiers(cfg *config.Config, log *logger.Logger) []usecase.NotifyTarget {
+	var targets []usecase.NotifyTarget
+
+	for _, notifyCfg := range cfg.EnabledNotifications() {
+		var n domain.Notifier
+		var err error
+
+		switch notifyCfg.Type {
+		case "webhook":
+			n, err = notifier.NewWebhook(&notifyCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize webhook notifier: %v", err)
+				continue
+			}
+			log.Infof("✓ Webhook notifications enabled")
+
+		case "smtp":
+			n, err = notifier.NewSMTP(&notifyCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize SMTP notifier: %v", err)
+				continue
+			}
+			log.Infof("✓ Email notifications enabled (%d recipient(s))", len(notifyCfg.To))
+
+		case "telegram":
+			n, err = notifier.NewTelegram(&notifyCfg)
+			if err != nil {
+				log.Errorf("Failed to initialize Telegram notifier: %v", err)
+				continue
+			}
+			log.Infof("✓ Telegram notifications enabled")
+
+		default:
+			log.Warnf("Unknown notification type: %s", notifyCfg.Type)
+			continue
+		}
+
+		targets = append(targets, usecase.NotifyTarget{
+			Name:     notifyCfg.Type,
+			Notifier: n,
 		})
 	}
 
@@ -186,7 +354,7 @@ func initializeUploadTargets(cfg *config.Config, log *logger.Logger, oauthServic
 func initializeBackupJobs(
 	cfg *config.Config,
 	uploadTargets []usecase.UploadTarget,
-	comp domain.Compressor,
+	notifyTargets []usecase.NotifyTarget,
 	log *logger.Logger,
 ) []domain.BackupJob {
 	var jobs []domain.BackupJob
@@ -196,7 +364,25 @@ func initializeBackupJobs(
 
 		switch dbCfg.Type {
 		case "mysql":
-			db = database.NewMySQL(&dbCfg)
+			if dbCfg.BackupMethod == "mydumper" {
+				db = database.NewMySQLParallel(&dbCfg)
+			} else {
+				db = database.NewMySQL(&dbCfg)
+			}
+		case "mysql-physical":
+			stateDir := filepath.Join(cfg.App.DataDir, "xtrabackup", dbCfg.Name)
+			physical, err := database.NewMySQLPhysical(&dbCfg, stateDir)
+			if err != nil {
+				log.Errorf("Failed to initialize %s: %v", dbCfg.Name, err)
+				continue
+			}
+			db = physical
+		case "postgresql":
+			if dbCfg.BackupMethod != "basebackup" {
+				log.Warnf("Logical dumps are not supported for postgresql yet; set backup_method: basebackup for %s", dbCfg.Name)
+				continue
+			}
+			db = database.NewPostgresBaseBackup(&dbCfg)
 		default:
 			log.Warnf("Unsupported database type: %s for %s", dbCfg.Type, dbCfg.Name)
 			continue
@@ -209,12 +395,39 @@ func initializeBackupJobs(
 		}
 		log.Infof("✓ Connected to %s (%s)", dbCfg.Name, dbCfg.Type)
 
+		jobNotifyTargets := notifyTargets
+		if dbCfg.Heartbeat.URL != "" {
+			heartbeat, err := notifier.NewHeartbeat(&dbCfg.Heartbeat)
+			if err != nil {
+				log.Errorf("Failed to initialize heartbeat for %s: %v", dbCfg.Name, err)
+			} else {
+				jobNotifyTargets = append(slices.Clone(notifyTargets), usecase.NotifyTarget{
+					Name:     "heartbeat",
+					Notifier: heartbeat,
+				})
+				log.Infof("✓ Heartbeat pings enabled for %s", dbCfg.Name)
+			}
+		}
+
+		compression := cfg.CompressionFor(dbCfg)
+		comp, err := compressor.New(compression)
+		if err != nil {
+			log.Errorf("Failed to initialize compressor for %s: %v", dbCfg.Name, err)
+			continue
+		}
+		// "none" skips the compression step instead of copying the dump.
+		compress := cfg.Backup.Compress && comp.Name() != "none"
+		if compress {
+			log.Infof("✓ Compression for %s: %s", dbCfg.Name, comp.Name())
+		}
+
 		backupUC := usecase.NewBackup(
 			db,
 			uploadTargets,
+			jobNotifyTargets,
 			comp,
 			log,
-			cfg.Backup.Compress,
+			compress,
 		)
 
 		jobs = append(jobs, domain.BackupJob{
@@ -229,3 +442,43 @@ func initializeBackupJobs(
 
 	return jobs
 }
+
+// initializeBinlogArchivers creates binlog archivers for MySQL databases with
+// binlog archiving enabled.
+func initializeBinlogArchivers(
+	cfg *config.Config,
+	uploadTargets []usecase.UploadTarget,
+	log *logger.Logger,
+) []*usecase.BinlogArchiver {
+	var archivers []*usecase.BinlogArchiver
+
+	for _, dbCfg := range cfg.EnabledDatabases() {
+		if !dbCfg.Binlog.Enabled {
+			continue
+		}
+
+		comp, err := compressor.New(cfg.CompressionFor(dbCfg))
+		if err != nil {
+			log.Errorf("Failed to initialize compressor for %s binlogs: %v", dbCfg.Name, err)
+			continue
+		}
+
+		dir := dbCfg.Binlog.Dir
+		if dir == "" {
+			dir = filepath.Join(cfg.App.DataDir, "binlog", dbCfg.Name)
+		}
+
+		archivers = append(archivers, usecase.NewBinlogArchiver(
+			database.NewMySQL(&dbCfg),
+			uploadTargets,
+			comp,
+			log,
+			cfg.Backup.Compress && comp.Name() != "none",
+			dir,
+			dbCfg.Binlog.ArchiveInterval,
+		))
+		log.Infof("✓ Binlog archiving enabled for %s (%s)", dbCfg.Name, dir)
+	}
+
+	return archivers
+}
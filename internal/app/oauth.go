@@ -2,113 +2,205 @@ package app
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/semmidev/phylax/internal/adapter/storage"
+	"github.com/semmidev/phylax/internal/config"
 	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/drive/v3"
 )
 
+// pendingAuthTTL is how long an authorization started at /auth/google/drive
+// may take before its state is rejected.
+const pendingAuthTTL = 10 * time.Minute
+
 // OAuthService defines the interface for OAuth-related operations.
 type OAuthService interface {
-	GetConfig() *oauth2.Config
 	StartAuthServer(ctx context.Context, addr string) error
 	Shutdown(ctx context.Context) error
 }
 
+// gdriveOAuthTarget is a Google Drive target that authorizes through OAuth.
+type gdriveOAuthTarget struct {
+	folderID  string
+	tokenName string
+	config    *oauth2.Config
+}
+
+// pendingAuth is an authorization waiting for its callback.
+type pendingAuth struct {
+	target   *gdriveOAuthTarget
+	verifier string
+	expires  time.Time
+}
+
 // GoogleOAuthService handles Google OAuth configuration and server.
 type GoogleOAuthService struct {
-	config     *oauth2.Config
+	targets    []*gdriveOAuthTarget
+	tokens     *tokenstore.Store
 	logger     *logger.Logger
 	authServer *http.Server
+
+	mu      sync.Mutex
+	pending map[string]pendingAuth
 }
 
-// NewGoogleOAuthService creates a new GoogleOAuthService.
-func NewGoogleOAuthService(logger *logger.Logger, clientSecretPath string) (*GoogleOAuthService, error) {
+// NewGoogleOAuthService creates a new GoogleOAuthService for the given
+// Google Drive targets. Obtained tokens are saved in tokens.
+func NewGoogleOAuthService(logger *logger.Logger, tokens *tokenstore.Store, targets []config.UploadTarget) (*GoogleOAuthService, error) {
 	if logger == nil {
 		return nil, errors.New("logger cannot be nil")
 	}
-	if clientSecretPath == "" {
-		return nil, errors.New("client secret path cannot be empty")
+	if tokens == nil {
+		return nil, errors.New("token store cannot be nil")
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("at least one Google Drive target is required")
 	}
 
-	b, err := os.ReadFile(clientSecretPath)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read client_secret.json: %w", err)
+	s := &GoogleOAuthService{
+		tokens:  tokens,
+		logger:  logger,
+		pending: make(map[string]pendingAuth),
+	}
+	for i := range targets {
+		cfg, err := storage.GDriveOAuthConfig(&targets[i])
+		if err != nil {
+			return nil, fmt.Errorf("gdrive folder %s: %w", targets[i].FolderID, err)
+		}
+		s.targets = append(s.targets, &gdriveOAuthTarget{
+			folderID:  targets[i].FolderID,
+			tokenName: storage.GDriveTokenName(&targets[i]),
+			config:    cfg,
+		})
 	}
+	return s, nil
+}
 
-	cfg, err := google.ConfigFromJSON(b, drive.DriveFileScope)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse client secret: %w", err)
+// StartAuthServer starts the OAuth HTTP server in a goroutine.
+func (s *GoogleOAuthService) StartAuthServer(ctx context.Context, addr string) error {
+	s.authServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	return &GoogleOAuthService{
-		config: cfg,
-		logger: logger,
-	}, nil
-}
+	go func() {
+		s.logger.Infof("Google Drive OAuth server listening on %s", s.authServer.Addr)
+		if err := s.authServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Errorf("OAuth server error: %v", err)
+		}
+	}()
 
-// GetConfig returns the OAuth2 configuration.
-func (s *GoogleOAuthService) GetConfig() *oauth2.Config {
-	return s.config
+	return nil
 }
 
-// StartAuthServer starts the OAuth HTTP server in a goroutine.
-func (s *GoogleOAuthService) StartAuthServer(ctx context.Context, addr string) error {
+// handler serves the authorization code flow. Each authorization gets a
+// random single-use state and a PKCE verifier; the resulting token is stored
+// instead of being shown.
+func (s *GoogleOAuthService) handler() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /auth/google/drive", func(w http.ResponseWriter, r *http.Request) {
-		authURL := s.config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+		target := s.target(r.URL.Query().Get("folder_id"))
+		if target == nil {
+			http.Error(w, "unknown or ambiguous folder_id", http.StatusBadRequest)
+			return
+		}
+
+		state, err := randomState()
+		if err != nil {
+			http.Error(w, "failed to generate state", http.StatusInternalServerError)
+			return
+		}
+		verifier := oauth2.GenerateVerifier()
+
+		s.mu.Lock()
+		now := time.Now()
+		for k, p := range s.pending {
+			if now.After(p.expires) {
+				delete(s.pending, k)
+			}
+		}
+		s.pending[state] = pendingAuth{target: target, verifier: verifier, expires: now.Add(pendingAuthTTL)}
+		s.mu.Unlock()
+
+		authURL := target.config.AuthCodeURL(state,
+			oauth2.AccessTypeOffline,
+			oauth2.ApprovalForce,
+			oauth2.S256ChallengeOption(verifier))
 		http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 	})
 
 	mux.HandleFunc("GET /auth/google/callback", func(w http.ResponseWriter, r *http.Request) {
+		state := r.URL.Query().Get("state")
+
+		s.mu.Lock()
+		pending, ok := s.pending[state]
+		delete(s.pending, state)
+		s.mu.Unlock()
+
+		if !ok || time.Now().After(pending.expires) {
+			http.Error(w, "invalid or expired state; start again at /auth/google/drive", http.StatusBadRequest)
+			return
+		}
+
 		code := r.URL.Query().Get("code")
 		if code == "" {
 			http.Error(w, "missing code parameter", http.StatusBadRequest)
 			return
 		}
 
-		token, err := s.config.Exchange(r.Context(), code)
+		token, err := pending.target.config.Exchange(r.Context(), code, oauth2.VerifierOption(pending.verifier))
 		if err != nil {
-			http.Error(w, fmt.Sprintf("token exchange failed: %v", err), http.StatusInternalServerError)
+			s.logger.Errorf("Google Drive token exchange failed: %v", err)
+			http.Error(w, "token exchange failed", http.StatusInternalServerError)
 			return
 		}
-
-		tokenJSON, err := json.MarshalIndent(token, "", "  ")
-		if err != nil {
-			http.Error(w, "failed to marshal token", http.StatusInternalServerError)
+		if token.RefreshToken == "" {
+			fmt.Fprintln(w, "⚠️ No refresh token returned. Revoke app access & re-authorize.")
 			return
 		}
 
-		refresh := token.RefreshToken
-		if refresh == "" {
-			fmt.Fprintln(w, "⚠️ No refresh token returned. Revoke app access & re-authorize.")
+		if err := s.tokens.Put(pending.target.tokenName, token); err != nil {
+			s.logger.Errorf("Failed to store Google Drive token: %v", err)
+			http.Error(w, "failed to store token", http.StatusInternalServerError)
 			return
 		}
 
-		fmt.Fprintf(w, "✅ Refresh Token:\n%s\n\nFull Token JSON:\n%s", refresh, tokenJSON)
+		s.logger.Infof("Google Drive folder %s authorized", pending.target.folderID)
+		fmt.Fprintf(w, "✅ Google Drive folder %s authorized. The token has been stored; you can close this page.",
+			html.EscapeString(pending.target.folderID))
 	})
 
-	s.authServer = &http.Server{
-		Addr:              addr,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
-	}
+	return mux
+}
 
-	go func() {
-		s.logger.Infof("Google Drive OAuth server listening on %s", s.authServer.Addr)
-		if err := s.authServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.Errorf("OAuth server error: %v", err)
+// target returns the target for folderID, or the only target when folderID
+// is empty.
+func (s *GoogleOAuthService) target(folderID string) *gdriveOAuthTarget {
+	if folderID == "" {
+		if len(s.targets) == 1 {
+			return s.targets[0]
 		}
-	}()
-
+		return nil
+	}
+	for _, t := range s.targets {
+		if t.folderID == folderID {
+			return t
+		}
+	}
 	return nil
 }
 
@@ -124,3 +216,92 @@ func (s *GoogleOAuthService) Shutdown(ctx context.Context) error {
 	s.logger.Infof("OAuth server stopped successfully")
 	return nil
 }
+
+// AuthorizeGDrive runs the OAuth device flow for the Google Drive targets
+// that use OAuth, or only the one with folderID if set, and stores the
+// tokens. It suits headless machines: the user opens the printed URL on any
+// device and enters the code. The client secret must belong to a "TVs and
+// Limited Input devices" OAuth client.
+func AuthorizeGDrive(ctx context.Context, cfg *config.Config, folderID string, out io.Writer) error {
+	targets := gdriveOAuthTargets(cfg)
+	if folderID != "" {
+		targets = nil
+		for _, t := range gdriveOAuthTargets(cfg) {
+			if t.FolderID == folderID {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return errors.New("no enabled gdrive target uses OAuth")
+	}
+
+	tokens, err := openTokenStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	for i := range targets {
+		target := &targets[i]
+		oauthConfig, err := storage.GDriveOAuthConfig(target)
+		if err != nil {
+			return fmt.Errorf("gdrive folder %s: %w", target.FolderID, err)
+		}
+
+		auth, err := oauthConfig.DeviceAuth(ctx, oauth2.AccessTypeOffline)
+		if err != nil {
+			return fmt.Errorf("failed to start device authorization: %w", err)
+		}
+
+		fmt.Fprintf(out, "Google Drive folder %s:\n", target.FolderID)
+		fmt.Fprintf(out, "  Visit %s and enter the code %s\n", auth.VerificationURI, auth.UserCode)
+
+		token, err := oauthConfig.DeviceAccessToken(ctx, auth)
+		if err != nil {
+			return fmt.Errorf("device authorization failed: %w", err)
+		}
+		if err := tokens.Put(storage.GDriveTokenName(target), token); err != nil {
+			return fmt.Errorf("failed to store token: %w", err)
+		}
+		fmt.Fprintf(out, "  ✅ Authorized\n")
+	}
+	return nil
+}
+
+// gdriveOAuthTargets returns the enabled Google Drive targets that use the
+// OAuth flow rather than a service account.
+func gdriveOAuthTargets(cfg *config.Config) []config.UploadTarget {
+	var targets []config.UploadTarget
+	for _, target := range cfg.EnabledUploadTargets() {
+		if target.Type == "gdrive" && (target.Auth == "" || target.Auth == "oauth") {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// openTokenStore opens the encrypted OAuth token store under data_dir. The
+// key is app.token_key or PHYLAX_TOKEN_KEY, falling back to a random key
+// generated next to the store.
+func openTokenStore(cfg *config.Config) (*tokenstore.Store, error) {
+	secret := []byte(cfg.App.TokenKey)
+	if env := os.Getenv("PHYLAX_TOKEN_KEY"); env != "" {
+		secret = []byte(env)
+	}
+	if len(secret) == 0 {
+		key, err := tokenstore.LoadOrCreateKey(filepath.Join(cfg.App.DataDir, "token.key"))
+		if err != nil {
+			return nil, err
+		}
+		secret = key
+	}
+	return tokenstore.New(filepath.Join(cfg.App.DataDir, "tokens.enc"), secret)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
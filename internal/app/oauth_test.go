@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
+)
+
+func TestGoogleOAuthService(t *testing.T) {
+	Convey("Given a GoogleOAuthService with a fake token endpoint", t, func() {
+		tempDir, err := os.MkdirTemp("", "oauth_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		var exchanged url.Values
+		provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			exchanged = r.Form
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`)
+		}))
+		defer provider.Close()
+
+		clientSecret := filepath.Join(tempDir, "client_secret.json")
+		So(os.WriteFile(clientSecret, []byte(`{"installed":{"client_id":"id","client_secret":"secret",
+			"auth_uri":"https://accounts.example.com/auth","token_uri":"`+provider.URL+`",
+			"redirect_uris":["http://localhost:8089/auth/google/callback"]}}`), 0600), ShouldBeNil)
+
+		tokens, err := tokenstore.New(filepath.Join(tempDir, "tokens.enc"), []byte("key"))
+		So(err, ShouldBeNil)
+		log, _ := logger.New("fatal", "")
+
+		service, err := NewGoogleOAuthService(log, tokens, []config.UploadTarget{
+			{Type: "gdrive", CredentialsFile: clientSecret, FolderID: "folder-a"},
+		})
+		So(err, ShouldBeNil)
+		server := httptest.NewServer(service.handler())
+		defer server.Close()
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+		start := func() url.Values {
+			resp, err := client.Get(server.URL + "/auth/google/drive")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusTemporaryRedirect)
+
+			location, err := url.Parse(resp.Header.Get("Location"))
+			So(err, ShouldBeNil)
+			return location.Query()
+		}
+
+		Convey("The authorization redirect should carry a random state and a PKCE challenge", func() {
+			first, second := start(), start()
+			So(first.Get("state"), ShouldNotEqual, "state-token")
+			So(first.Get("state"), ShouldNotEqual, second.Get("state"))
+			So(first.Get("code_challenge_method"), ShouldEqual, "S256")
+			So(first.Get("code_challenge"), ShouldNotBeEmpty)
+			So(first.Get("access_type"), ShouldEqual, "offline")
+		})
+
+		Convey("A valid callback should exchange with the verifier and store the token", func() {
+			auth := start()
+
+			resp, err := client.Get(server.URL + "/auth/google/callback?code=abc&state=" + url.QueryEscape(auth.Get("state")))
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			verifier := exchanged.Get("code_verifier")
+			So(verifier, ShouldNotBeEmpty)
+			So(oauth2.S256ChallengeFromVerifier(verifier), ShouldEqual, auth.Get("code_challenge"))
+
+			token, ok, err := tokens.Get("gdrive:folder-a")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(token.RefreshToken, ShouldEqual, "refresh")
+
+			Convey("And the state should not be accepted twice", func() {
+				resp, err := client.Get(server.URL + "/auth/google/callback?code=abc&state=" + url.QueryEscape(auth.Get("state")))
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("A callback with an unknown state should be rejected", func() {
+			resp, err := client.Get(server.URL + "/auth/google/callback?code=abc&state=state-token")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(exchanged, ShouldBeNil)
+		})
+
+		Convey("An unknown folder_id should be rejected", func() {
+			resp, err := client.Get(server.URL + "/auth/google/drive?folder_id=other")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
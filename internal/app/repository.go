@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/semmidev/phylax/internal/adapter/repository"
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
+)
+
+// CheckRepositories verifies the deduplicating repositories for
+// `phylax repository check`: every chunk a snapshot references must exist,
+// and with readData must also match its hash. from limits the check to the
+// target of that type.
+func CheckRepositories(ctx context.Context, cfg *config.Config, from string, readData bool) error {
+	log, err := logger.New(cfg.App.LogLevel, cfg.App.LogFile)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer log.Close()
+
+	var tokens *tokenstore.Store
+	if len(gdriveOAuthTargets(cfg)) > 0 {
+		if tokens, err = openTokenStore(cfg); err != nil {
+			log.Errorf("Failed to open OAuth token store: %v", err)
+		}
+	}
+
+	checked := 0
+	var errs []error
+	for _, target := range initializeUploadTargets(cfg, log, tokens) {
+		repo, ok := target.Storage.(*repository.Repository)
+		if !ok || (from != "" && target.Name != from) {
+			continue
+		}
+
+		checked++
+		log.Infof("Checking repository on %s...", target.Name)
+		if err := repo.Check(ctx, readData); err != nil {
+			log.Errorf("Repository on %s is damaged: %v", target.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+			continue
+		}
+		log.Infof("✓ Repository on %s is intact", target.Name)
+	}
+
+	if checked == 0 {
+		return errors.New("no enabled upload target has repository: true; use -from to pick one")
+	}
+	return errors.Join(errs...)
+}
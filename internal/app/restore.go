@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/semmidev/phylax/internal/adapter/compressor"
+	"github.com/semmidev/phylax/internal/adapter/database"
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/domain"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
+	"github.com/semmidev/phylax/internal/usecase"
+)
+
+// RestoreOptions selects what `phylax restore` recovers.
+type RestoreOptions struct {
+	Database string
+	ToTime   time.Time
+	// From is the upload target type to restore from; empty uses the
+	// first target that supports downloads.
+	From   string
+	DryRun bool
+}
+
+// RestoreToTime restores a MySQL database to opts.ToTime from the nearest
+// full dump and the archived binlogs after it.
+func RestoreToTime(ctx context.Context, cfg *config.Config, opts RestoreOptions) error {
+	log, err := logger.New(cfg.App.LogLevel, cfg.App.LogFile)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer log.Close()
+
+	var dbCfg *config.DatabaseConfig
+	for i := range cfg.Databases {
+		if cfg.Databases[i].Name == opts.Database {
+			dbCfg = &cfg.Databases[i]
+		}
+	}
+	if dbCfg == nil {
+		return fmt.Errorf("database %q is not configured", opts.Database)
+	}
+	if dbCfg.Type != "mysql" {
+		return fmt.Errorf("point-in-time restore is only supported for mysql, not %s", dbCfg.Type)
+	}
+
+	var tokens *tokenstore.Store
+	if len(gdriveOAuthTargets(cfg)) > 0 {
+		if tokens, err = openTokenStore(cfg); err != nil {
+			log.Errorf("Failed to open OAuth token store: %v", err)
+		}
+	}
+
+	var source domain.Storage
+	for _, target := range initializeUploadTargets(cfg, log, tokens) {
+		if _, ok := target.Storage.(domain.Downloader); !ok {
+			continue
+		}
+		if opts.From == "" || target.Name == opts.From {
+			source = target.Storage
+			break
+		}
+	}
+	if source == nil {
+		return errors.New("no enabled upload target supports downloads; use -from to pick one")
+	}
+
+	comp, err := compressor.New(cfg.CompressionFor(*dbCfg))
+	if err != nil {
+		return fmt.Errorf("failed to initialize compressor: %w", err)
+	}
+
+	db := database.NewMySQL(dbCfg)
+	return usecase.NewRestore(db, db, source, comp, log).Execute(ctx, opts.ToTime, opts.DryRun)
+}
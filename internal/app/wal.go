@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/semmidev/phylax/internal/adapter/compressor"
+	"github.com/semmidev/phylax/internal/config"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	"github.com/semmidev/phylax/internal/infrastructure/tokenstore"
+	"github.com/semmidev/phylax/internal/usecase"
+)
+
+// WALPush archives the WAL file at path for `phylax wal-push`, which
+// PostgreSQL runs as its archive_command.
+func WALPush(ctx context.Context, cfg *config.Config, dbName string, path string) error {
+	archive, log, err := newWALArchive(cfg, dbName)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	return archive.Push(ctx, path)
+}
+
+// WALFetch restores the archived WAL file walName to dest for
+// `phylax wal-fetch`, which PostgreSQL runs as its restore_command.
+func WALFetch(ctx context.Context, cfg *config.Config, dbName string, walName string, dest string) error {
+	archive, log, err := newWALArchive(cfg, dbName)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	return archive.Fetch(ctx, walName, dest)
+}
+
+func newWALArchive(cfg *config.Config, dbName string) (*usecase.WALArchive, *logger.Logger, error) {
+	dbCfg, err := walDatabase(cfg, dbName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log, err := logger.New(cfg.App.LogLevel, cfg.App.LogFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	comp, err := compressor.New(cfg.CompressionFor(*dbCfg))
+	if err != nil {
+		log.Close()
+		return nil, nil, fmt.Errorf("failed to initialize compressor: %w", err)
+	}
+
+	var tokens *tokenstore.Store
+	if len(gdriveOAuthTargets(cfg)) > 0 {
+		if tokens, err = openTokenStore(cfg); err != nil {
+			log.Errorf("Failed to open OAuth token store: %v", err)
+		}
+	}
+
+	archive := usecase.NewWALArchive(
+		dbCfg.Name,
+		initializeUploadTargets(cfg, log, tokens),
+		comp,
+		log,
+		cfg.Backup.Compress && comp.Name() != "none",
+	)
+	return archive, log, nil
+}
+
+// walDatabase returns the base backup database named dbName, or the only
+// one configured when dbName is empty.
+func walDatabase(cfg *config.Config, dbName string) (*config.DatabaseConfig, error) {
+	var candidates []*config.DatabaseConfig
+	for i := range cfg.Databases {
+		db := &cfg.Databases[i]
+		if db.Type == "postgresql" && db.BackupMethod == "basebackup" {
+			candidates = append(candidates, db)
+		}
+	}
+
+	if dbName == "" {
+		switch len(candidates) {
+		case 1:
+			return candidates[0], nil
+		case 0:
+			return nil, fmt.Errorf("no postgresql database uses backup_method: basebackup")
+		default:
+			names := make([]string, len(candidates))
+			for i, db := range candidates {
+				names[i] = db.Name
+			}
+			return nil, fmt.Errorf("several postgresql databases are configured (%s); use -db", strings.Join(names, ", "))
+		}
+	}
+
+	for _, db := range candidates {
+		if db.Name == dbName {
+			return db, nil
+		}
+	}
+	return nil, fmt.Errorf("database %q is not a postgresql database with backup_method: basebackup", dbName)
+}
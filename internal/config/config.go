@@ -2,59 +2,195 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	App       AppConfig        `mapstructure:"app"`
-	Databases []DatabaseConfig `mapstructure:"databases"`
-	Backup    BackupConfig     `mapstructure:"backup"`
+	App           AppConfig            `mapstructure:"app"`
+	Databases     []DatabaseConfig     `mapstructure:"databases"`
+	Backup        BackupConfig         `mapstructure:"backup"`
+	Notifications []NotificationConfig `mapstructure:"notifications"`
 }
 
 type AppConfig struct {
-	Name     string `mapstructure:"name"`
-	Port     int    `mapstructure:"port"`
-	LogLevel string `mapstructure:"log_level"`
-	LogFile  string `mapstructure:"log_file"`
+	Name        string `mapstructure:"name"`
+	Port        int    `mapstructure:"port"`
+	BindAddress string `mapstructure:"bind_address"`
+	LogLevel    string `mapstructure:"log_level"`
+	LogFile     string `mapstructure:"log_file"`
+	DataDir     string `mapstructure:"data_dir"`
+	TokenKey    string `mapstructure:"token_key"`
 }
 
 type DatabaseConfig struct {
-	Name         string `mapstructure:"name"`
-	Type         string `mapstructure:"type"`
-	Host         string `mapstructure:"host"`
-	Port         int    `mapstructure:"port"`
-	Username     string `mapstructure:"username"`
-	Password     string `mapstructure:"password"`
-	Database     string `mapstructure:"database"`
-	Enabled      bool   `mapstructure:"enabled"`
-	Schedule     string `mapstructure:"schedule"`
-	SSLMode      string `mapstructure:"ssl_mode"`
-	AuthDatabase string `mapstructure:"auth_database"`
+	Name         string            `mapstructure:"name"`
+	Type         string            `mapstructure:"type"`
+	Host         string            `mapstructure:"host"`
+	Port         int               `mapstructure:"port"`
+	Username     string            `mapstructure:"username"`
+	Password     string            `mapstructure:"password"`
+	Database     string            `mapstructure:"database"`
+	Enabled      bool              `mapstructure:"enabled"`
+	Schedule     string            `mapstructure:"schedule"`
+	SSLMode      string            `mapstructure:"ssl_mode"`
+	AuthDatabase string            `mapstructure:"auth_database"`
+	Heartbeat    HeartbeatConfig   `mapstructure:"heartbeat"`
+	Compression  CompressionConfig `mapstructure:"compression"`
+	Binlog       BinlogConfig      `mapstructure:"binlog"`
+	BackupMethod string            `mapstructure:"backup_method"`
+	Physical     PhysicalConfig    `mapstructure:"physical"`
+	Dump         DumpConfig        `mapstructure:"dump"`
+}
+
+// DumpConfig selects what a logical MySQL dump contains. Table patterns are
+// globs matched against "table" and "schema.table".
+type DumpConfig struct {
+	Tables          []string `mapstructure:"tables"`
+	ExcludeTables   []string `mapstructure:"exclude_tables"`
+	IgnoreDataFor   []string `mapstructure:"ignore_data_for"`
+	SchemaOnly      bool     `mapstructure:"schema_only"`
+	DataOnly        bool     `mapstructure:"data_only"`
+	ExtraArgs       []string `mapstructure:"extra_args"`
+	AllDatabases    bool     `mapstructure:"all_databases"`
+	Databases       []string `mapstructure:"databases"`
+	FilePerDatabase bool     `mapstructure:"file_per_database"`
+	Threads         int      `mapstructure:"threads"`
+}
+
+// PhysicalConfig configures mysql-physical backups taken with xtrabackup or
+// mariabackup. FullEvery is the number of incremental backups taken after
+// each full backup; zero takes only full backups.
+type PhysicalConfig struct {
+	Binary    string   `mapstructure:"binary"`
+	FullEvery int      `mapstructure:"full_every"`
+	ExtraArgs []string `mapstructure:"extra_args"`
+}
+
+// BinlogConfig enables continuous MySQL binlog archiving for point-in-time
+// recovery. Binlogs are streamed into Dir and uploaded once complete.
+type BinlogConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Binary           string        `mapstructure:"binary"`
+	ServerID         int           `mapstructure:"server_id"`
+	Dir              string        `mapstructure:"dir"`
+	ArchiveInterval  time.Duration `mapstructure:"archive_interval"`
+	LegacyMasterData bool          `mapstructure:"legacy_master_data"`
+}
+
+type HeartbeatConfig struct {
+	URL     string        `mapstructure:"url"`
+	Format  string        `mapstructure:"format"`
+	Timeout time.Duration `mapstructure:"timeout"`
 }
 
 type BackupConfig struct {
-	RetentionDays int            `mapstructure:"retention_days"`
-	Compress      bool           `mapstructure:"compress"`
-	UploadTargets []UploadTarget `mapstructure:"upload_targets"`
+	RetentionDays int               `mapstructure:"retention_days"`
+	Compress      bool              `mapstructure:"compress"`
+	Compression   CompressionConfig `mapstructure:"compression"`
+	UploadTargets []UploadTarget    `mapstructure:"upload_targets"`
+}
+
+// CompressionConfig selects the compression algorithm. A level or worker
+// count of zero uses the algorithm's default.
+type CompressionConfig struct {
+	Algorithm string `mapstructure:"algorithm"`
+	Level     int    `mapstructure:"level"`
+	Workers   int    `mapstructure:"workers"`
 }
 
 type UploadTarget struct {
-	Type            string `mapstructure:"type"`
-	Path            string `mapstructure:"path"`
-	RefreshToken    string `mapstructure:"refresh_token"`
-	Enabled         bool   `mapstructure:"enabled"`
-	CredentialsFile string `mapstructure:"credentials_file"`
-	FolderID        string `mapstructure:"folder_id"`
-	Region          string `mapstructure:"region"`
-	Bucket          string `mapstructure:"bucket"`
-	AccessKey       string `mapstructure:"access_key"`
-	SecretKey       string `mapstructure:"secret_key"`
-	Prefix          string `mapstructure:"prefix"`
-	BotToken        string `mapstructure:"bot_token"`
-	ChatID          string `mapstructure:"chat_id"`
-	SendFile        bool   `mapstructure:"send_file"`
-	NotifyOnly      bool   `mapstructure:"notify_only"`
+	Type               string            `mapstructure:"type"`
+	Path               string            `mapstructure:"path"`
+	RefreshToken       string            `mapstructure:"refresh_token"`
+	Enabled            bool              `mapstructure:"enabled"`
+	CredentialsFile    string            `mapstructure:"credentials_file"`
+	Auth               string            `mapstructure:"auth"`
+	ImpersonateUser    string            `mapstructure:"impersonate_user"`
+	FolderID           string            `mapstructure:"folder_id"`
+	DriveID            string            `mapstructure:"drive_id"`
+	Region             string            `mapstructure:"region"`
+	Bucket             string            `mapstructure:"bucket"`
+	AccessKey          string            `mapstructure:"access_key"`
+	SecretKey          string            `mapstructure:"secret_key"`
+	Profile            string            `mapstructure:"profile"`
+	RoleARN            string            `mapstructure:"role_arn"`
+	ExternalID         string            `mapstructure:"external_id"`
+	SessionName        string            `mapstructure:"session_name"`
+	Prefix             string            `mapstructure:"prefix"`
+	Endpoint           string            `mapstructure:"endpoint"`
+	ForcePathStyle     bool              `mapstructure:"force_path_style"`
+	InsecureSkipVerify bool              `mapstructure:"insecure_skip_verify"`
+	CAFile             string            `mapstructure:"ca_file"`
+	SSE                string            `mapstructure:"sse"`
+	SSEKMSKeyID        string            `mapstructure:"sse_kms_key_id"`
+	StorageClass       string            `mapstructure:"storage_class"`
+	Tags               map[string]string `mapstructure:"tags"`
+	ObjectLockMode     string            `mapstructure:"object_lock_mode"`
+	ObjectLockDays     int               `mapstructure:"object_lock_days"`
+	Container          string            `mapstructure:"container"`
+	ConnectionString   string            `mapstructure:"connection_string"`
+	AccountName        string            `mapstructure:"account_name"`
+	AccountKey         string            `mapstructure:"account_key"`
+	SASToken           string            `mapstructure:"sas_token"`
+	AccessTier         string            `mapstructure:"access_tier"`
+	ImmutabilityMode   string            `mapstructure:"immutability_mode"`
+	ImmutabilityDays   int               `mapstructure:"immutability_days"`
+	BlockSizeMB        int               `mapstructure:"block_size_mb"`
+	ChunkedUpload      bool              `mapstructure:"chunked_upload"`
+	Host               string            `mapstructure:"host"`
+	Port               int               `mapstructure:"port"`
+	Username           string            `mapstructure:"username"`
+	Password           string            `mapstructure:"password"`
+	PrivateKeyFile     string            `mapstructure:"private_key_file"`
+	PrivateKeyPass     string            `mapstructure:"private_key_passphrase"`
+	KnownHostsFile     string            `mapstructure:"known_hosts_file"`
+	TLS                string            `mapstructure:"tls"`
+	BandwidthLimitKBps int               `mapstructure:"bandwidth_limit_kbps"`
+	Remote             string            `mapstructure:"remote"`
+	RcloneConfig       string            `mapstructure:"rclone_config"`
+	RcloneBinary       string            `mapstructure:"rclone_binary"`
+	RcloneArgs         []string          `mapstructure:"rclone_args"`
+	BotToken           string            `mapstructure:"bot_token"`
+	ChatID             string            `mapstructure:"chat_id"`
+	SendFile           bool              `mapstructure:"send_file"`
+	NotifyOnly         bool              `mapstructure:"notify_only"`
+	PartSizeMB         int               `mapstructure:"part_size_mb"`
+	IndexFile          string            `mapstructure:"index_file"`
+	APIEndpoint        string            `mapstructure:"api_endpoint"`
+	Repository         bool              `mapstructure:"repository"`
+}
+
+type NotificationConfig struct {
+	Type            string            `mapstructure:"type"`
+	Enabled         bool              `mapstructure:"enabled"`
+	Events          []string          `mapstructure:"events"`
+	URL             string            `mapstructure:"url"`
+	Method          string            `mapstructure:"method"`
+	Headers         map[string]string `mapstructure:"headers"`
+	Template        string            `mapstructure:"template"`
+	Secret          string            `mapstructure:"secret"`
+	SignatureHeader string            `mapstructure:"signature_header"`
+	MaxRetries      int               `mapstructure:"max_retries"`
+	RetryDelay      time.Duration     `mapstructure:"retry_delay"`
+	Timeout         time.Duration     `mapstructure:"timeout"`
+	Host            string            `mapstructure:"host"`
+	Port            int               `mapstructure:"port"`
+	Username        string            `mapstructure:"username"`
+	Password        string            `mapstructure:"password"`
+	From            string            `mapstructure:"from"`
+	To              []string          `mapstructure:"to"`
+	TLS             string            `mapstructure:"tls"`
+	Subject         string            `mapstructure:"subject"`
+	HTMLTemplate    string            `mapstructure:"html_template"`
+	SummarySchedule string            `mapstructure:"summary_schedule"`
+	BotToken        string            `mapstructure:"bot_token"`
+	ChatID          string            `mapstructure:"chat_id"`
+	SuccessChatID   string            `mapstructure:"success_chat_id"`
+	FailureChatID   string            `mapstructure:"failure_chat_id"`
+	APIEndpoint     string            `mapstructure:"api_endpoint"`
 }
 
 func Load(path string) (*Config, error) {
@@ -63,9 +199,12 @@ func Load(path string) (*Config, error) {
 	v.SetConfigType("yaml")
 
 	v.SetDefault("app.name", "phylax")
+	v.SetDefault("app.bind_address", "127.0.0.1")
 	v.SetDefault("app.log_level", "info")
+	v.SetDefault("app.data_dir", "data")
 	v.SetDefault("backup.retention_days", 14)
 	v.SetDefault("backup.compress", true)
+	v.SetDefault("backup.compression.algorithm", "gzip")
 
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
@@ -88,6 +227,10 @@ func (c *Config) validate() error {
 		return fmt.Errorf("at least one database required")
 	}
 
+	if err := validateCompression(c.Backup.Compression); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
 	for i, db := range c.Databases {
 		if db.Name == "" {
 			return fmt.Errorf("database[%d]: name required", i)
@@ -101,11 +244,174 @@ func (c *Config) validate() error {
 		if db.Enabled && db.Schedule == "" {
 			return fmt.Errorf("database[%d]: schedule required when enabled", i)
 		}
+		// Validate what the backup will use: a database that sets only a
+		// level keeps the backup section's algorithm.
+		if err := validateCompression(c.CompressionFor(db)); err != nil {
+			return fmt.Errorf("database[%d]: %w", i, err)
+		}
+		if db.Binlog.Enabled && db.Type != "mysql" {
+			return fmt.Errorf("database[%d]: binlog archiving requires a mysql database", i)
+		}
+		if err := validateDump(db); err != nil {
+			return fmt.Errorf("database[%d]: %w", i, err)
+		}
+		if db.Physical.FullEvery < 0 {
+			return fmt.Errorf("database[%d]: physical.full_every cannot be negative", i)
+		}
+		switch db.BackupMethod {
+		case "", "dump":
+		case "basebackup":
+			if db.Type != "postgresql" {
+				return fmt.Errorf("database[%d]: basebackup requires a postgresql database", i)
+			}
+		case "mydumper":
+			if err := validateMyDumper(db); err != nil {
+				return fmt.Errorf("database[%d]: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("database[%d]: unsupported backup_method %q", i, db.BackupMethod)
+		}
+		switch db.Heartbeat.Format {
+		case "", "healthchecks", "uptime-kuma":
+		default:
+			return fmt.Errorf("database[%d]: unsupported heartbeat format %q", i, db.Heartbeat.Format)
+		}
+	}
+
+	for i, target := range c.Backup.UploadTargets {
+		if target.Type == "telegram" {
+			if err := validateTelegramPartSize(target); err != nil {
+				return fmt.Errorf("upload_targets[%d]: %w", i, err)
+			}
+		}
+	}
+
+	for i, n := range c.Notifications {
+		if n.Type == "" {
+			return fmt.Errorf("notifications[%d]: type required", i)
+		}
+		if n.Enabled && n.Type == "webhook" && n.URL == "" {
+			return fmt.Errorf("notifications[%d]: url required for webhook", i)
+		}
+		if n.Enabled && n.Type == "smtp" && (n.Host == "" || n.From == "" || len(n.To) == 0) {
+			return fmt.Errorf("notifications[%d]: host, from and to required for smtp", i)
+		}
+		if n.Enabled && n.Type == "telegram" && n.BotToken == "" {
+			return fmt.Errorf("notifications[%d]: bot_token required for telegram", i)
+		}
 	}
 
 	return nil
 }
 
+func validateDump(db DatabaseConfig) error {
+	d := db.Dump
+	if d.SchemaOnly && d.DataOnly {
+		return fmt.Errorf("dump.schema_only and dump.data_only are mutually exclusive")
+	}
+	if d.AllDatabases && len(d.Databases) > 0 {
+		return fmt.Errorf("dump.all_databases and dump.databases are mutually exclusive")
+	}
+	for _, patterns := range [][]string{d.Tables, d.ExcludeTables, d.IgnoreDataFor} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	if db.Binlog.Enabled && (d.AllDatabases || len(d.Databases) > 0) {
+		return fmt.Errorf("binlog archiving restores a single database; dump.all_databases and dump.databases are not supported with it")
+	}
+	if d.Threads < 0 {
+		return fmt.Errorf("dump.threads cannot be negative")
+	}
+	if db.Binlog.Enabled && d.FilePerDatabase {
+		return fmt.Errorf("binlog archiving needs a plain SQL dump; disable dump.file_per_database")
+	}
+	return nil
+}
+
+func validateMyDumper(db DatabaseConfig) error {
+	switch {
+	case db.Type != "mysql":
+		return fmt.Errorf("mydumper requires a mysql database")
+	case db.Binlog.Enabled:
+		return fmt.Errorf("binlog archiving needs a mysqldump backup, not mydumper")
+	case db.Dump.FilePerDatabase:
+		return fmt.Errorf("dump.file_per_database does not apply to mydumper, which writes a file per table")
+	case len(db.Dump.IgnoreDataFor) > 0:
+		return fmt.Errorf("dump.ignore_data_for is not supported with mydumper")
+	}
+	return nil
+}
+
+// Bot API upload limits: 50 MB on the public server, 2000 MB on a local
+// Bot API server.
+const (
+	telegramMaxPartSizeMB      = 50
+	telegramLocalMaxPartSizeMB = 2000
+)
+
+func validateTelegramPartSize(target UploadTarget) error {
+	limit := telegramMaxPartSizeMB
+	if target.APIEndpoint != "" {
+		limit = telegramLocalMaxPartSizeMB
+	}
+	if target.PartSizeMB < 0 || target.PartSizeMB > limit {
+		return fmt.Errorf("part_size_mb must be between 0 and %d for telegram", limit)
+	}
+	return nil
+}
+
+func validateCompression(c CompressionConfig) error {
+	switch c.Algorithm {
+	case "", "gzip", "pgzip", "zstd", "xz", "lz4", "none":
+	default:
+		return fmt.Errorf("unsupported compression algorithm %q", c.Algorithm)
+	}
+	if c.Level < 0 || c.Workers < 0 {
+		return fmt.Errorf("compression level and workers cannot be negative")
+	}
+	// Level 0 selects the algorithm's default.
+	if limits, ok := compressionLevels[c.Algorithm]; ok && c.Level != 0 &&
+		(c.Level < limits[0] || c.Level > limits[1]) {
+		algorithm := c.Algorithm
+		if algorithm == "" {
+			algorithm = "gzip"
+		}
+		return fmt.Errorf("compression level %d is out of range for %s (%d-%d)", c.Level, algorithm, limits[0], limits[1])
+	}
+	return nil
+}
+
+// compressionLevels are the valid levels of each algorithm.
+var compressionLevels = map[string][2]int{
+	"":      {1, 9},
+	"gzip":  {1, 9},
+	"pgzip": {1, 9},
+	"zstd":  {1, 22},
+	"xz":    {0, 9},
+	"lz4":   {0, 9},
+}
+
+// CompressionFor returns the compression settings for db: its own settings
+// where set, otherwise those of the backup section. Choosing a different
+// algorithm for db does not inherit the backup section's level and workers.
+func (c *Config) CompressionFor(db DatabaseConfig) CompressionConfig {
+	result := c.Backup.Compression
+	override := db.Compression
+	if override.Algorithm != "" && override.Algorithm != result.Algorithm {
+		result = CompressionConfig{Algorithm: override.Algorithm}
+	}
+	if override.Level != 0 {
+		result.Level = override.Level
+	}
+	if override.Workers != 0 {
+		result.Workers = override.Workers
+	}
+	return result
+}
+
 func (c *Config) HasUploadTarget(targetType string) bool {
 	for _, target := range c.EnabledUploadTargets() {
 		if target.Type == targetType {
@@ -134,3 +440,13 @@ func (c *Config) EnabledUploadTargets() []UploadTarget {
 	}
 	return enabled
 }
+
+func (c *Config) EnabledNotifications() []NotificationConfig {
+	var enabled []NotificationConfig
+	for _, n := range c.Notifications {
+		if n.Enabled {
+			enabled = append(enabled, n)
+		}
+	}
+	return enabled
+}
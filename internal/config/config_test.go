@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompressionValidation(t *testing.T) {
+	Convey("Given a config with per-database compression", t, func() {
+		tempDir, err := os.MkdirTemp("", "config_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		load := func(backup, database string) (*Config, error) {
+			path := filepath.Join(tempDir, "config.yaml")
+			data := "backup:\n  compression:\n" + backup +
+				"databases:\n  - name: shop\n    type: mysql\n    host: db\n    compression:\n" + database
+			So(os.WriteFile(path, []byte(data), 0600), ShouldBeNil)
+			return Load(path)
+		}
+
+		Convey("A level-only override should be checked against the backup algorithm", func() {
+			cfg, err := load("    algorithm: zstd\n", "      level: 19\n")
+			So(err, ShouldBeNil)
+			So(cfg.CompressionFor(cfg.Databases[0]), ShouldResemble, CompressionConfig{Algorithm: "zstd", Level: 19})
+
+			_, err = load("    algorithm: gzip\n", "      level: 19\n")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "database[0]: compression level 19 is out of range for gzip (1-9)")
+		})
+
+		Convey("An algorithm override should be checked against its own range", func() {
+			_, err := load("    algorithm: gzip\n", "      algorithm: zstd\n      level: 19\n")
+			So(err, ShouldBeNil)
+
+			_, err = load("    algorithm: zstd\n", "      algorithm: xz\n      level: 19\n")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "out of range for xz (0-9)")
+		})
+
+		Convey("An invalid backup algorithm should be reported for the backup section", func() {
+			_, err := load("    algorithm: brotli\n", "      level: 5\n")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, `backup: unsupported compression algorithm "brotli"`)
+		})
+	})
+}
@@ -3,4 +3,8 @@ package domain
 type Compressor interface {
 	Compress(sourcePath, destPath string) error
 	Decompress(sourcePath, destPath string) error
+	// Extension is appended to the names of compressed files, e.g. ".gz".
+	Extension() string
+	// Name identifies the algorithm in logs and notifications.
+	Name() string
 }
@@ -1,6 +1,9 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Database interface {
 	Backup(ctx context.Context, outputPath string) error
@@ -8,3 +11,56 @@ type Database interface {
 	Type() string
 	Ping(ctx context.Context) error
 }
+
+// ExtensionProvider is implemented by databases whose backup file extension
+// depends on their configuration.
+type ExtensionProvider interface {
+	Extension() string
+}
+
+// Backup kinds of a ChainedDatabase.
+const (
+	BackupKindFull        = "full"
+	BackupKindIncremental = "incremental"
+)
+
+// ChainedDatabase is implemented by databases whose backups form chains: a
+// full backup followed by incrementals that each depend on the one before.
+type ChainedDatabase interface {
+	// NextBackupKind reports whether the next Backup is full or incremental.
+	NextBackupKind() string
+	// CommitBackup records the last Backup in the chain once it is stored,
+	// so later incrementals are based on it.
+	CommitBackup() error
+	// AbandonBackup drops the last Backup when only some targets stored it,
+	// so the next backup starts a new chain with a full backup.
+	AbandonBackup() error
+}
+
+// BinlogPosition is a coordinate in a MySQL server's binary log.
+type BinlogPosition struct {
+	File     string `json:"file"`
+	Position int64  `json:"position"`
+}
+
+// BinlogPositionReader is implemented by databases whose dumps record the
+// binary log position they were taken at.
+type BinlogPositionReader interface {
+	BinlogPosition(dumpPath string) (BinlogPosition, error)
+}
+
+// BinlogSource streams a MySQL server's binary logs into a local directory,
+// one file per binlog, named as on the server.
+type BinlogSource interface {
+	Name() string
+	CurrentBinlog(ctx context.Context) (string, error)
+	StreamBinlogs(ctx context.Context, dir string, startFile string) error
+}
+
+// PointInTimeRestorer restores a full dump and replays archived binlogs on
+// top of it.
+type PointInTimeRestorer interface {
+	BinlogPositionReader
+	Restore(ctx context.Context, dumpPath string) error
+	ReplayBinlogs(ctx context.Context, files []string, start BinlogPosition, stopTime time.Time) error
+}
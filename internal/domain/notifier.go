@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventBackupStarted    EventType = "backup_started"
+	EventBackupSucceeded  EventType = "backup_succeeded"
+	EventBackupFailed     EventType = "backup_failed"
+	EventBackupPartial    EventType = "backup_partial"
+	EventCleanupCompleted EventType = "cleanup_completed"
+)
+
+type UploadResult struct {
+	Target string `json:"target"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BackupResult struct {
+	Database    string             `json:"database"`
+	Type        string             `json:"type"`
+	Filename    string             `json:"filename,omitempty"`
+	Size        int64              `json:"size"`
+	StartedAt   time.Time          `json:"started_at"`
+	Duration    time.Duration      `json:"duration"`
+	Compression *CompressionResult `json:"compression,omitempty"`
+	Binlog      *BinlogPosition    `json:"binlog,omitempty"`
+	Uploads     []UploadResult     `json:"uploads,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// CompressionResult describes the compression step of a backup. Ratio is
+// original size divided by compressed size; throughput is measured on the
+// original size.
+type CompressionResult struct {
+	Algorithm      string        `json:"algorithm"`
+	OriginalSize   int64         `json:"original_size"`
+	CompressedSize int64         `json:"compressed_size"`
+	Ratio          float64       `json:"ratio"`
+	Duration       time.Duration `json:"duration"`
+	ThroughputMBps float64       `json:"throughput_mbps"`
+}
+
+type CleanupResult struct {
+	Target  string `json:"target"`
+	Deleted int    `json:"deleted"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Event struct {
+	Type    EventType       `json:"type"`
+	Time    time.Time       `json:"time"`
+	Backup  *BackupResult   `json:"backup,omitempty"`
+	Cleanup []CleanupResult `json:"cleanup,omitempty"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
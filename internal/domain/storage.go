@@ -11,3 +11,14 @@ type Storage interface {
 	Delete(ctx context.Context, remoteName string) error
 	GetOldFiles(ctx context.Context, cutoffTime time.Time) ([]string, error)
 }
+
+// Downloader is implemented by storages that can fetch a stored file back.
+type Downloader interface {
+	Download(ctx context.Context, remoteName string, localPath string) error
+}
+
+// Pruner is implemented by storages that reclaim space in a separate step
+// after old backups have been deleted.
+type Pruner interface {
+	Prune(ctx context.Context) error
+}
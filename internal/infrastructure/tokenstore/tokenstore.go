@@ -0,0 +1,229 @@
+package tokenstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+// ErrNoToken is returned by a store token source when nothing has been
+// stored under its name yet.
+var ErrNoToken = errors.New("no token stored")
+
+// envelope is the on-disk format: the JSON token map sealed with AES-256-GCM
+// under a key derived from the store secret and salt.
+type envelope struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// Store is an encrypted file of OAuth tokens keyed by name.
+type Store struct {
+	path   string
+	secret []byte
+	mu     sync.Mutex
+}
+
+// New returns a Store kept at path and encrypted with secret. The file is
+// created on the first Put.
+func New(path string, secret []byte) (*Store, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("token store secret cannot be empty")
+	}
+	return &Store{path: path, secret: secret}, nil
+}
+
+// LoadOrCreateKey returns the key kept in keyFile, generating a random one
+// readable only by the owner if the file does not exist.
+func LoadOrCreateKey(keyFile string) ([]byte, error) {
+	key, err := os.ReadFile(keyFile)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read token key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate token key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create token key directory: %w", err)
+	}
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write token key: %w", err)
+	}
+	return key, nil
+}
+
+// Get returns the token stored under name.
+func (s *Store) Get(name string) (*oauth2.Token, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return nil, false, err
+	}
+	token, ok := tokens[name]
+	return token, ok, nil
+}
+
+// Put stores token under name, replacing any previous token.
+func (s *Store) Put(name string, token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[name] = token
+	return s.save(tokens)
+}
+
+// TokenSource returns a token source for the token stored under name. The
+// token is read from the store on first use, refreshed through cfg when it
+// expires, and written back whenever the provider issues a new one.
+func (s *Store) TokenSource(ctx context.Context, cfg *oauth2.Config, name string) oauth2.TokenSource {
+	return &storeTokenSource{ctx: ctx, cfg: cfg, store: s, name: name}
+}
+
+type storeTokenSource struct {
+	ctx   context.Context
+	cfg   *oauth2.Config
+	store *Store
+	name  string
+
+	mu      sync.Mutex
+	current *oauth2.Token
+}
+
+func (ts *storeTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.current.Valid() {
+		return ts.current, nil
+	}
+
+	token := ts.current
+	if token == nil {
+		stored, ok, err := ts.store.Get(ts.name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w for %s", ErrNoToken, ts.name)
+		}
+		token = stored
+	}
+
+	refreshed, err := ts.cfg.TokenSource(ts.ctx, token).Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+
+	if refreshed.AccessToken != token.AccessToken || refreshed.RefreshToken != token.RefreshToken {
+		if err := ts.store.Put(ts.name, refreshed); err != nil {
+			return nil, fmt.Errorf("failed to persist refreshed token: %w", err)
+		}
+	}
+
+	ts.current = refreshed
+	return refreshed, nil
+}
+
+func (s *Store) load() (map[string]*oauth2.Token, error) {
+	tokens := make(map[string]*oauth2.Token)
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token store: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse token store: %w", err)
+	}
+
+	gcm, err := s.cipher(env.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt token store: wrong key or corrupted file")
+	}
+
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token store: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *Store) save(tokens map[string]*oauth2.Token) error {
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	env := envelope{Salt: make([]byte, 16)}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	gcm, err := s.cipher(env.Salt)
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	env.Data = gcm.Seal(nil, env.Nonce, plain, nil)
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode token store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create token store directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace token store: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(s.secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token store key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,145 @@
+package tokenstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
+)
+
+func TestStore(t *testing.T) {
+	Convey("Given a token store", t, func() {
+		tempDir, err := os.MkdirTemp("", "tokenstore_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		path := filepath.Join(tempDir, "tokens.enc")
+		store, err := New(path, []byte("passphrase"))
+		So(err, ShouldBeNil)
+
+		Convey("Put and Get should round-trip through an encrypted file", func() {
+			So(store.Put("gdrive:folder", &oauth2.Token{AccessToken: "access-secret", RefreshToken: "refresh-secret"}), ShouldBeNil)
+
+			token, ok, err := store.Get("gdrive:folder")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(token.RefreshToken, ShouldEqual, "refresh-secret")
+
+			content, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldNotContainSubstring, "refresh-secret")
+
+			info, err := os.Stat(path)
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+
+			Convey("And a different secret should not decrypt it", func() {
+				other, _ := New(path, []byte("wrong"))
+				_, _, err := other.Get("gdrive:folder")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "failed to decrypt token store")
+			})
+		})
+
+		Convey("Get of an unknown name should report it missing", func() {
+			_, ok, err := store.Get("gdrive:missing")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("An empty secret should return error", func() {
+			_, err := New(path, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	Convey("Given a missing key file", t, func() {
+		tempDir, err := os.MkdirTemp("", "tokenstore_key_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		keyFile := filepath.Join(tempDir, "keys", "token.key")
+
+		Convey("It should generate a private key once and reuse it", func() {
+			key, err := LoadOrCreateKey(keyFile)
+			So(err, ShouldBeNil)
+			So(key, ShouldHaveLength, 32)
+
+			info, err := os.Stat(keyFile)
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+
+			again, err := LoadOrCreateKey(keyFile)
+			So(err, ShouldBeNil)
+			So(again, ShouldResemble, key)
+		})
+	})
+}
+
+func TestStoreTokenSource(t *testing.T) {
+	Convey("Given a store token source", t, func() {
+		tempDir, err := os.MkdirTemp("", "tokenstore_source_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		refreshes := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			refreshes++
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"rotated","token_type":"Bearer","expires_in":3600}`, refreshes)
+		}))
+		defer server.Close()
+
+		cfg := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{TokenURL: server.URL}}
+		store, _ := New(filepath.Join(tempDir, "tokens.enc"), []byte("passphrase"))
+		ts := store.TokenSource(context.Background(), cfg, "gdrive:folder")
+
+		Convey("Without a stored token it should return ErrNoToken", func() {
+			_, err := ts.Token()
+			So(errors.Is(err, ErrNoToken), ShouldBeTrue)
+		})
+
+		Convey("An expired token should be refreshed and persisted", func() {
+			So(store.Put("gdrive:folder", &oauth2.Token{
+				AccessToken:  "stale",
+				RefreshToken: "original",
+				Expiry:       time.Now().Add(-time.Hour),
+			}), ShouldBeNil)
+
+			token, err := ts.Token()
+			So(err, ShouldBeNil)
+			So(token.AccessToken, ShouldEqual, "access-1")
+
+			stored, _, err := store.Get("gdrive:folder")
+			So(err, ShouldBeNil)
+			So(stored.AccessToken, ShouldEqual, "access-1")
+			So(stored.RefreshToken, ShouldEqual, "rotated")
+
+			Convey("And a valid token should be reused without refreshing", func() {
+				_, err := ts.Token()
+				So(err, ShouldBeNil)
+				So(refreshes, ShouldEqual, 1)
+			})
+		})
+
+		Convey("A token stored later should be picked up without a restart", func() {
+			_, err := ts.Token()
+			So(err, ShouldNotBeNil)
+
+			So(store.Put("gdrive:folder", &oauth2.Token{RefreshToken: "late"}), ShouldBeNil)
+			token, err := ts.Token()
+			So(err, ShouldBeNil)
+			So(token.AccessToken, ShouldEqual, "access-1")
+		})
+	})
+}
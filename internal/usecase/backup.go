@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,7 @@ import (
 type Backup struct {
 	db            domain.Database
 	uploadTargets []UploadTarget
+	notifyTargets []NotifyTarget
 	compressor    domain.Compressor
 	logger        Logger
 	compress      bool
@@ -22,6 +24,9 @@ type Backup struct {
 type UploadTarget struct {
 	Name    string
 	Storage domain.Storage
+	// Raw targets receive the uncompressed dump, e.g. deduplicating
+	// repositories that compress chunks themselves.
+	Raw bool
 }
 
 type Logger interface {
@@ -33,6 +38,7 @@ type Logger interface {
 func NewBackup(
 	db domain.Database,
 	uploadTargets []UploadTarget,
+	notifyTargets []NotifyTarget,
 	compressor domain.Compressor,
 	logger Logger,
 	compress bool,
@@ -40,6 +46,7 @@ func NewBackup(
 	return &Backup{
 		db:            db,
 		uploadTargets: uploadTargets,
+		notifyTargets: notifyTargets,
 		compressor:    compressor,
 		logger:        logger,
 		compress:      compress,
@@ -47,7 +54,32 @@ func NewBackup(
 }
 
 func (uc *Backup) Execute(ctx context.Context) error {
-	start := time.Now()
+	result := &domain.BackupResult{
+		Database:  uc.db.Name(),
+		Type:      uc.db.Type(),
+		StartedAt: time.Now(),
+	}
+	uc.notify(ctx, domain.EventBackupStarted, result)
+
+	err := uc.run(ctx, result)
+	result.Duration = time.Since(result.StartedAt)
+
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+		uc.logger.Errorf("[%s] Backup failed after %s: %v",
+			result.Database, result.Duration.Round(time.Second), err)
+		uc.notify(ctx, domain.EventBackupFailed, result)
+	case failedUploads(result.Uploads) > 0:
+		uc.notify(ctx, domain.EventBackupPartial, result)
+	default:
+		uc.notify(ctx, domain.EventBackupSucceeded, result)
+	}
+
+	return err
+}
+
+func (uc *Backup) run(ctx context.Context, result *domain.BackupResult) error {
 	dbName := uc.db.Name()
 	uc.logger.Infof("[%s] Starting backup...", dbName)
 
@@ -72,36 +104,100 @@ func (uc *Backup) Execute(ctx context.Context) error {
 	uc.logger.Infof("[%s] Backup created, size: %.2f MB",
 		dbName, float64(fileInfo.Size())/(1024*1024))
 
+	if reader, ok := uc.db.(domain.BinlogPositionReader); ok {
+		if position, err := reader.BinlogPosition(tempPath); err == nil {
+			result.Binlog = &position
+			uc.logger.Infof("[%s] Binlog coordinates: %s:%d", dbName, position.File, position.Position)
+		}
+	}
+
 	finalPath, finalFilename := tempPath, filename
+	result.Filename, result.Size = filename, fileInfo.Size()
 
-	if uc.compress {
-		finalPath, finalFilename, err = uc.compressBackup(tempPath, filename, fileInfo.Size())
+	if uc.compress && uc.hasCompressedTargets() {
+		finalPath, finalFilename, result.Compression, err = uc.compressBackup(tempPath, filename, fileInfo.Size())
 		if err != nil {
 			return err
 		}
 		defer os.Remove(finalPath)
+
+		result.Filename = finalFilename
+		if info, err := os.Stat(finalPath); err == nil {
+			result.Size = info.Size()
+		}
 	}
 
-	if err := uc.uploadBackup(ctx, finalPath, finalFilename); err != nil {
+	files := uploadFiles{
+		path: finalPath, filename: finalFilename,
+		rawPath: tempPath, rawFilename: filename,
+	}
+	if err := uc.uploadBackup(ctx, files, result); err != nil {
 		return err
 	}
 
+	if chained, ok := uc.db.(domain.ChainedDatabase); ok {
+		if err := uc.recordChain(chained, result.Uploads); err != nil {
+			return err
+		}
+	}
+
 	uc.logger.Infof("[%s] Backup completed in %s: %s",
-		dbName, time.Since(start).Round(time.Second), finalFilename)
+		dbName, time.Since(result.StartedAt).Round(time.Second), finalFilename)
 
 	return nil
 }
 
+// recordChain commits the backup to its chain only when every target stored
+// it. A target that missed it could not restore later incrementals based on
+// it, so after a partial upload the next backup starts a new chain instead.
+func (uc *Backup) recordChain(chained domain.ChainedDatabase, uploads []domain.UploadResult) error {
+	if failedUploads(uploads) > 0 {
+		uc.logger.Warnf("[%s] Backup did not reach every target; the next backup will be a full backup", uc.db.Name())
+		if err := chained.AbandonBackup(); err != nil {
+			return fmt.Errorf("reset backup chain: %w", err)
+		}
+		return nil
+	}
+
+	if err := chained.CommitBackup(); err != nil {
+		return fmt.Errorf("record backup chain: %w", err)
+	}
+	return nil
+}
+
+func (uc *Backup) notify(ctx context.Context, eventType domain.EventType, result *domain.BackupResult) {
+	if len(uc.notifyTargets) == 0 {
+		return
+	}
+
+	snapshot := *result
+	notify(ctx, uc.logger, uc.notifyTargets, domain.Event{
+		Type:   eventType,
+		Time:   time.Now(),
+		Backup: &snapshot,
+	})
+}
+
 func (uc *Backup) generateFilename() string {
 	timestamp := time.Now().Format("20060102_150405")
 	baseFilename := fmt.Sprintf("%s_%s_%s", uc.db.Name(), uc.db.Type(), timestamp)
+	if chained, ok := uc.db.(domain.ChainedDatabase); ok {
+		// Retention reads the kind back to keep chains whole.
+		baseFilename += "_" + chained.NextBackupKind()
+	}
 
 	ext := map[string]string{
-		"mysql":      ".sql",
-		"postgresql": ".dump",
-		"mongodb":    ".archive",
+		"mysql":          ".sql",
+		"postgresql":     ".dump",
+		"mongodb":        ".archive",
+		"pgbasebackup":   ".tar",
+		"mysql-physical": ".xbstream",
+		"mydumper":       ".tar",
 	}[uc.db.Type()]
 
+	if provider, ok := uc.db.(domain.ExtensionProvider); ok {
+		ext = provider.Extension()
+	}
 	if ext == "" {
 		ext = ".backup"
 	}
@@ -109,49 +205,128 @@ func (uc *Backup) generateFilename() string {
 	return baseFilename + ext
 }
 
-func (uc *Backup) compressBackup(tempPath, filename string, originalSize int64) (string, string, error) {
+func (uc *Backup) compressBackup(tempPath, filename string, originalSize int64) (string, string, *domain.CompressionResult, error) {
 	dbName := uc.db.Name()
-	compressedFilename := filename + ".gz"
+	compressedFilename := filename + uc.compressor.Extension()
 	compressedPath := filepath.Join(os.TempDir(), compressedFilename)
 
-	uc.logger.Infof("[%s] Compressing backup...", dbName)
+	uc.logger.Infof("[%s] Compressing backup with %s...", dbName, uc.compressor.Name())
+	start := time.Now()
 	if err := uc.compressor.Compress(tempPath, compressedPath); err != nil {
-		return "", "", fmt.Errorf("compression: %w", err)
+		return "", "", nil, fmt.Errorf("compression: %w", err)
+	}
+
+	compressedInfo, err := os.Stat(compressedPath)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("stat compressed file: %w", err)
 	}
 
-	compressedInfo, _ := os.Stat(compressedPath)
-	uc.logger.Infof("[%s] Compression complete, size: %.2f MB (%.1f%% of original)",
+	stats := &domain.CompressionResult{
+		Algorithm:      uc.compressor.Name(),
+		OriginalSize:   originalSize,
+		CompressedSize: compressedInfo.Size(),
+		Duration:       time.Since(start),
+	}
+	if stats.CompressedSize > 0 {
+		stats.Ratio = float64(originalSize) / float64(stats.CompressedSize)
+	}
+	if seconds := stats.Duration.Seconds(); seconds > 0 {
+		stats.ThroughputMBps = float64(originalSize) / (1024 * 1024) / seconds
+	}
+
+	uc.logger.Infof("[%s] Compression complete, size: %.2f MB (%.1f%% of original, ratio %.2fx, %.1f MB/s)",
 		dbName,
-		float64(compressedInfo.Size())/(1024*1024),
-		float64(compressedInfo.Size())/float64(originalSize)*100)
+		float64(stats.CompressedSize)/(1024*1024),
+		float64(stats.CompressedSize)/float64(max(originalSize, 1))*100,
+		stats.Ratio,
+		stats.ThroughputMBps)
+
+	return compressedPath, compressedFilename, stats, nil
+}
 
-	return compressedPath, compressedFilename, nil
+// uploadFiles holds the finished backup and the dump it was made from.
+type uploadFiles struct {
+	path, filename       string
+	rawPath, rawFilename string
 }
 
-func (uc *Backup) uploadBackup(ctx context.Context, filePath, filename string) error {
-	if len(uc.uploadTargets) > 0 {
-		uc.uploadToTargets(ctx, filePath, filename)
+// hasCompressedTargets reports whether any target takes the compressed backup.
+func (uc *Backup) hasCompressedTargets() bool {
+	if len(uc.uploadTargets) == 0 {
+		return true
+	}
+	for _, t := range uc.uploadTargets {
+		if !t.Raw {
+			return true
+		}
+	}
+	return false
+}
+
+func (uc *Backup) uploadBackup(ctx context.Context, files uploadFiles, result *domain.BackupResult) error {
+	if len(uc.uploadTargets) == 0 {
+		return nil
+	}
+
+	result.Uploads = uc.uploadToTargets(ctx, files)
+	if failedUploads(result.Uploads) == len(result.Uploads) {
+		return errors.New("upload failed for all targets")
 	}
 	return nil
 }
 
-func (uc *Backup) uploadToTargets(ctx context.Context, filePath, filename string) {
+func (uc *Backup) uploadToTargets(ctx context.Context, files uploadFiles) []domain.UploadResult {
 	var wg sync.WaitGroup
 	dbName := uc.db.Name()
+	results := make([]domain.UploadResult, len(uc.uploadTargets))
 
-	for _, target := range uc.uploadTargets {
+	for i, target := range uc.uploadTargets {
 		wg.Add(1)
-		go func(t UploadTarget) {
+		go func(i int, t UploadTarget) {
 			defer wg.Done()
 
+			results[i].Target = t.Name
+			filePath, filename := files.path, files.filename
+			if t.Raw {
+				filePath, filename = files.rawPath, files.rawFilename
+			}
+
 			uc.logger.Infof("[%s] Uploading to %s...", dbName, t.Name)
 			if err := t.Storage.Upload(ctx, filePath, filename); err != nil {
 				uc.logger.Errorf("[%s] Failed to upload to %s: %v", dbName, t.Name, err)
+				results[i].Error = err.Error()
 			} else {
 				uc.logger.Infof("[%s] Successfully uploaded to %s", dbName, t.Name)
 			}
-		}(target)
+		}(i, target)
 	}
 
 	wg.Wait()
+	return results
+}
+
+// uploadToAll uploads files to every target, sequentially, and returns the
+// errors of all targets that failed.
+func uploadToAll(ctx context.Context, targets []UploadTarget, files uploadFiles) error {
+	var errs []error
+	for _, target := range targets {
+		localPath, remoteName := files.path, files.filename
+		if target.Raw {
+			localPath, remoteName = files.rawPath, files.rawFilename
+		}
+		if err := target.Storage.Upload(ctx, localPath, remoteName); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func failedUploads(uploads []domain.UploadResult) int {
+	failed := 0
+	for _, u := range uploads {
+		if u.Error != "" {
+			failed++
+		}
+	}
+	return failed
 }
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/adapter/storage"
+	"github.com/semmidev/phylax/internal/domain"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeChainedDatabase writes a small backup and records how each backup
+// was settled.
+type fakeChainedDatabase struct {
+	committed, abandoned int
+}
+
+func (f *fakeChainedDatabase) Backup(ctx context.Context, outputPath string) error {
+	return os.WriteFile(outputPath, []byte("backup"), 0600)
+}
+func (f *fakeChainedDatabase) Name() string                   { return "orders" }
+func (f *fakeChainedDatabase) Type() string                   { return "mysql-physical" }
+func (f *fakeChainedDatabase) Ping(ctx context.Context) error { return nil }
+func (f *fakeChainedDatabase) NextBackupKind() string         { return domain.BackupKindIncremental }
+func (f *fakeChainedDatabase) CommitBackup() error            { f.committed++; return nil }
+func (f *fakeChainedDatabase) AbandonBackup() error           { f.abandoned++; return nil }
+
+// failingStorage rejects every upload.
+type failingStorage struct{}
+
+func (failingStorage) Upload(ctx context.Context, localPath, remoteName string) error {
+	return errors.New("connection refused")
+}
+func (failingStorage) List(ctx context.Context) ([]string, error)          { return nil, nil }
+func (failingStorage) Delete(ctx context.Context, remoteName string) error { return nil }
+func (failingStorage) GetOldFiles(ctx context.Context, cutoff time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func TestBackupChain(t *testing.T) {
+	Convey("Given a chained database and a local target", t, func() {
+		tempDir, err := os.MkdirTemp("", "backup_chain_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		local, err := storage.NewLocal(filepath.Join(tempDir, "local"))
+		So(err, ShouldBeNil)
+		log, err := logger.New("fatal", "")
+		So(err, ShouldBeNil)
+
+		db := &fakeChainedDatabase{}
+		targets := []UploadTarget{{Name: "local", Storage: local}}
+
+		Convey("A backup stored by every target should join the chain", func() {
+			So(NewBackup(db, targets, nil, nil, log, false).Execute(context.Background()), ShouldBeNil)
+			So(db.committed, ShouldEqual, 1)
+			So(db.abandoned, ShouldEqual, 0)
+
+			files, err := local.List(context.Background())
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+			So(files[0], ShouldEndWith, "_incremental.xbstream")
+		})
+
+		Convey("A backup one target missed should be abandoned", func() {
+			targets = append(targets, UploadTarget{Name: "offsite", Storage: failingStorage{}})
+
+			So(NewBackup(db, targets, nil, nil, log, false).Execute(context.Background()), ShouldBeNil)
+			So(db.committed, ShouldEqual, 0)
+			So(db.abandoned, ShouldEqual, 1)
+		})
+	})
+}
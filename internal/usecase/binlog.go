@@ -0,0 +1,247 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+const (
+	defaultBinlogArchiveInterval = 30 * time.Second
+	binlogRestartDelay           = 10 * time.Second
+
+	// binlogStateFile records the last binlog uploaded, so a restart with
+	// an empty directory resumes from the next one.
+	binlogStateFile = "last-archived"
+)
+
+// archivedBinlogPattern matches uploaded binlogs:
+// <db>_binlog_<YYYYMMDD_HHMMSS>_<binlog file>[<compression extension>].
+var archivedBinlogPattern = regexp.MustCompile(`_binlog_(\d{8}_\d{6})_(.+\.\d+)(\.[a-z0-9]+)?$`)
+
+// BinlogArchiver streams a MySQL server's binlogs to a local directory and
+// uploads each binlog once the server has moved on to the next one.
+type BinlogArchiver struct {
+	source        domain.BinlogSource
+	uploadTargets []UploadTarget
+	compressor    domain.Compressor
+	logger        Logger
+	compress      bool
+	dir           string
+	interval      time.Duration
+}
+
+func NewBinlogArchiver(
+	source domain.BinlogSource,
+	uploadTargets []UploadTarget,
+	compressor domain.Compressor,
+	logger Logger,
+	compress bool,
+	dir string,
+	interval time.Duration,
+) *BinlogArchiver {
+	if interval <= 0 {
+		interval = defaultBinlogArchiveInterval
+	}
+	return &BinlogArchiver{
+		source:        source,
+		uploadTargets: uploadTargets,
+		compressor:    compressor,
+		logger:        logger,
+		compress:      compress,
+		dir:           dir,
+		interval:      interval,
+	}
+}
+
+// Run streams and archives binlogs until ctx is cancelled. A dropped stream
+// is restarted from the binlog it was writing.
+func (a *BinlogArchiver) Run(ctx context.Context) error {
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create binlog directory: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.stream(ctx)
+	}()
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+			if err := a.Archive(ctx); err != nil {
+				a.logger.Errorf("[%s] Binlog archiving failed: %v", a.source.Name(), err)
+			}
+		}
+	}
+}
+
+func (a *BinlogArchiver) stream(ctx context.Context) {
+	dbName := a.source.Name()
+
+	for {
+		start, err := a.startFile(ctx)
+		if err == nil {
+			a.logger.Infof("[%s] Streaming binlogs from %s", dbName, start)
+			err = a.source.StreamBinlogs(ctx, a.dir, start)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		a.logger.Errorf("[%s] Binlog stream stopped, restarting in %s: %v", dbName, binlogRestartDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(binlogRestartDelay):
+		}
+	}
+}
+
+// startFile picks where streaming resumes: the binlog still being written
+// locally, the one after the last archived binlog, or the server's current
+// binlog on first start.
+func (a *BinlogArchiver) startFile(ctx context.Context) (string, error) {
+	files, err := a.localBinlogs()
+	if err != nil {
+		return "", err
+	}
+	if len(files) > 0 {
+		return files[len(files)-1], nil
+	}
+
+	if last, err := os.ReadFile(filepath.Join(a.dir, binlogStateFile)); err == nil {
+		if next, ok := nextBinlog(strings.TrimSpace(string(last))); ok {
+			return next, nil
+		}
+	}
+
+	return a.source.CurrentBinlog(ctx)
+}
+
+// Archive uploads every completed binlog in the directory and removes it
+// locally once all targets have it. The newest binlog is still being
+// written and is left alone.
+func (a *BinlogArchiver) Archive(ctx context.Context) error {
+	files, err := a.localBinlogs()
+	if err != nil {
+		return err
+	}
+	if len(files) < 2 {
+		return nil
+	}
+
+	for _, file := range files[:len(files)-1] {
+		if err := a.archiveFile(ctx, file); err != nil {
+			return fmt.Errorf("archive %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func (a *BinlogArchiver) archiveFile(ctx context.Context, file string) error {
+	dbName := a.source.Name()
+	path := filepath.Join(a.dir, file)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat binlog: %w", err)
+	}
+
+	// Name by modification time rather than now, so a retried upload
+	// overwrites the earlier attempt instead of duplicating it.
+	rawName := fmt.Sprintf("%s_binlog_%s_%s", dbName, info.ModTime().Format("20060102_150405"), file)
+	files := uploadFiles{path: path, filename: rawName, rawPath: path, rawFilename: rawName}
+
+	if a.compress {
+		files.filename = rawName + a.compressor.Extension()
+		files.path = filepath.Join(os.TempDir(), files.filename)
+		if err := a.compressor.Compress(path, files.path); err != nil {
+			return fmt.Errorf("compression: %w", err)
+		}
+		defer os.Remove(files.path)
+	}
+
+	if err := uploadToAll(ctx, a.uploadTargets, files); err != nil {
+		// Keep the binlog so the next pass retries; a gap in any target
+		// would break point-in-time restores from it.
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(a.dir, binlogStateFile), []byte(file+"\n"), 0600); err != nil {
+		return fmt.Errorf("record archived binlog: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove archived binlog: %w", err)
+	}
+
+	a.logger.Infof("[%s] Archived binlog %s (%.2f MB)", dbName, file, float64(info.Size())/(1024*1024))
+	return nil
+}
+
+// localBinlogs returns the binlog files in the directory, oldest first.
+func (a *BinlogArchiver) localBinlogs() ([]string, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binlog directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if _, ok := binlogSequence(entry.Name()); ok && !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	sortBinlogs(files)
+	return files, nil
+}
+
+// binlogSequence returns the numeric suffix of a binlog file name such as
+// "binlog.000042".
+func binlogSequence(name string) (int, bool) {
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 || dot == len(name)-1 {
+		return 0, false
+	}
+	seq, err := strconv.Atoi(name[dot+1:])
+	if err != nil || seq < 0 {
+		return 0, false
+	}
+	return seq, true
+}
+
+// nextBinlog returns the name the server gives the binlog after name.
+func nextBinlog(name string) (string, bool) {
+	seq, ok := binlogSequence(name)
+	if !ok {
+		return "", false
+	}
+	dot := strings.LastIndexByte(name, '.')
+	width := len(name) - dot - 1
+	return fmt.Sprintf("%s.%0*d", name[:dot], width, seq+1), true
+}
+
+func sortBinlogs(files []string) {
+	sort.Slice(files, func(i, j int) bool {
+		a, _ := binlogSequence(files[i])
+		b, _ := binlogSequence(files[j])
+		return a < b
+	})
+}
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semmidev/phylax/internal/adapter/storage"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeBinlogSource reports a fixed current binlog and never streams.
+type fakeBinlogSource struct {
+	current string
+}
+
+func (f *fakeBinlogSource) Name() string { return "orders" }
+func (f *fakeBinlogSource) CurrentBinlog(ctx context.Context) (string, error) {
+	return f.current, nil
+}
+func (f *fakeBinlogSource) StreamBinlogs(ctx context.Context, dir string, startFile string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBinlogNames(t *testing.T) {
+	Convey("Given binlog file names", t, func() {
+		Convey("nextBinlog should keep the zero padding", func() {
+			next, ok := nextBinlog("binlog.000042")
+			So(ok, ShouldBeTrue)
+			So(next, ShouldEqual, "binlog.000043")
+
+			next, ok = nextBinlog("mysql-bin.999999")
+			So(ok, ShouldBeTrue)
+			So(next, ShouldEqual, "mysql-bin.1000000")
+		})
+
+		Convey("nextBinlog should reject names without a sequence number", func() {
+			for _, name := range []string{"binlog", "binlog.", "binlog.index", ".000001"} {
+				_, ok := nextBinlog(name)
+				So(ok, ShouldBeFalse)
+			}
+		})
+
+		Convey("sortBinlogs should order by sequence number, not by name", func() {
+			files := []string{"mysql-bin.1000000", "mysql-bin.999999", "mysql-bin.000002"}
+			sortBinlogs(files)
+			So(files, ShouldResemble, []string{"mysql-bin.000002", "mysql-bin.999999", "mysql-bin.1000000"})
+		})
+	})
+}
+
+func TestBinlogArchiver(t *testing.T) {
+	Convey("Given a binlog archiver with a local target", t, func() {
+		tempDir, err := os.MkdirTemp("", "binlog_archiver_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		local, err := storage.NewLocal(filepath.Join(tempDir, "target"))
+		So(err, ShouldBeNil)
+		log, err := logger.New("fatal", "")
+		So(err, ShouldBeNil)
+
+		dir := filepath.Join(tempDir, "binlog")
+		So(os.MkdirAll(dir, 0700), ShouldBeNil)
+		writeBinlog := func(name string) {
+			So(os.WriteFile(filepath.Join(dir, name), []byte("events of "+name), 0600), ShouldBeNil)
+		}
+
+		source := &fakeBinlogSource{current: "binlog.000100"}
+		targets := []UploadTarget{{Name: "local", Storage: local}}
+		archiver := NewBinlogArchiver(source, targets, nil, log, false, dir, 0)
+		ctx := context.Background()
+
+		Convey("startFile should resume from the binlog still on disk", func() {
+			writeBinlog("binlog.000007")
+			writeBinlog("binlog.000008")
+			So(os.WriteFile(filepath.Join(dir, binlogStateFile), []byte("binlog.000006\n"), 0600), ShouldBeNil)
+
+			start, err := archiver.startFile(ctx)
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, "binlog.000008")
+		})
+
+		Convey("startFile should continue after the last archived binlog", func() {
+			So(os.WriteFile(filepath.Join(dir, binlogStateFile), []byte("binlog.000041\n"), 0600), ShouldBeNil)
+
+			start, err := archiver.startFile(ctx)
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, "binlog.000042")
+		})
+
+		Convey("startFile should start from the server's current binlog on first run", func() {
+			start, err := archiver.startFile(ctx)
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, "binlog.000100")
+		})
+
+		Convey("Archive should upload completed binlogs and keep the one being written", func() {
+			writeBinlog("binlog.000001")
+			writeBinlog("binlog.000002")
+			writeBinlog("binlog.000003")
+
+			So(archiver.Archive(ctx), ShouldBeNil)
+
+			files, err := local.List(ctx)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+			for i, file := range files {
+				So(file, ShouldStartWith, "orders_binlog_")
+				So(file, ShouldEndWith, "_binlog.00000"+string(rune('1'+i)))
+				So(archivedBinlogPattern.MatchString(file), ShouldBeTrue)
+			}
+
+			remaining, err := archiver.localBinlogs()
+			So(err, ShouldBeNil)
+			So(remaining, ShouldResemble, []string{"binlog.000003"})
+
+			state, err := os.ReadFile(filepath.Join(dir, binlogStateFile))
+			So(err, ShouldBeNil)
+			So(strings.TrimSpace(string(state)), ShouldEqual, "binlog.000002")
+		})
+
+		Convey("Archive should keep binlogs a target failed to store", func() {
+			writeBinlog("binlog.000001")
+			writeBinlog("binlog.000002")
+			targets = append(targets, UploadTarget{Name: "offsite", Storage: failingStorage{}})
+			archiver := NewBinlogArchiver(source, targets, nil, log, false, dir, 0)
+
+			err := archiver.Archive(ctx)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "offsite")
+
+			remaining, err := archiver.localBinlogs()
+			So(err, ShouldBeNil)
+			So(remaining, ShouldResemble, []string{"binlog.000001", "binlog.000002"})
+
+			_, err = os.Stat(filepath.Join(dir, binlogStateFile))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
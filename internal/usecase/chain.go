@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// chainBackupPattern matches backups of a domain.ChainedDatabase:
+// <db>_<type>_<YYYYMMDD_HHMMSS>_<kind>.
+var chainBackupPattern = regexp.MustCompile(`^(.+_.+)_(\d{8}_\d{6})_(` + domain.BackupKindFull + `|` + domain.BackupKindIncremental + `)\.`)
+
+// retainChains removes from old every backup a kept backup depends on.
+// Each incremental depends on all earlier backups of its chain, back to the
+// full backup that started it, so a chain is deleted whole or not at all.
+func retainChains(all, old []string) []string {
+	deleting := make(map[string]bool, len(old))
+	for _, file := range old {
+		deleting[file] = true
+	}
+
+	type chainBackup struct {
+		file      string
+		timestamp string
+		kind      string
+	}
+	byDatabase := make(map[string][]chainBackup)
+	for _, file := range all {
+		if m := chainBackupPattern.FindStringSubmatch(file); m != nil {
+			byDatabase[m[1]] = append(byDatabase[m[1]], chainBackup{file: file, timestamp: m[2], kind: m[3]})
+		}
+	}
+
+	for _, backups := range byDatabase {
+		sort.Slice(backups, func(i, j int) bool { return backups[i].timestamp < backups[j].timestamp })
+
+		start := 0
+		for i := range backups {
+			if i+1 < len(backups) && backups[i+1].kind != domain.BackupKindFull {
+				continue
+			}
+			// backups[start:i+1] is one chain.
+			chain := backups[start : i+1]
+			kept := false
+			for _, b := range chain {
+				kept = kept || !deleting[b.file]
+			}
+			if kept {
+				for _, b := range chain {
+					delete(deleting, b.file)
+				}
+			}
+			start = i + 1
+		}
+	}
+
+	remaining := make([]string, 0, len(old))
+	for _, file := range old {
+		if deleting[file] {
+			remaining = append(remaining, file)
+		}
+	}
+	return remaining
+}
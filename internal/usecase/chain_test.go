@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetainChains(t *testing.T) {
+	Convey("Given two backup chains on a target", t, func() {
+		all := []string{
+			"db_mysql-physical_20240101_000000_full.xbstream.gz",
+			"db_mysql-physical_20240102_000000_incremental.xbstream.gz",
+			"db_mysql-physical_20240103_000000_incremental.xbstream.gz",
+			"db_mysql-physical_20240104_000000_full.xbstream.gz",
+			"db_mysql-physical_20240105_000000_incremental.xbstream.gz",
+		}
+
+		Convey("A chain with a kept incremental should be kept whole", func() {
+			So(retainChains(all, all[:2]), ShouldBeEmpty)
+		})
+
+		Convey("A fully expired chain should be deleted", func() {
+			So(retainChains(all, all[:3]), ShouldResemble, all[:3])
+		})
+
+		Convey("The full backup of the newest chain should stay while its incrementals do", func() {
+			So(retainChains(all, all[:4]), ShouldResemble, all[:3])
+		})
+
+		Convey("Other files should pass through", func() {
+			old := []string{"db_mysql_20240101_000000.sql.gz"}
+			So(retainChains(all, old), ShouldResemble, old)
+		})
+	})
+}
@@ -4,22 +4,29 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/semmidev/phylax/internal/domain"
 )
 
 type Cleanup struct {
 	uploadTargets []UploadTarget
+	notifyTargets []NotifyTarget
 	logger        Logger
 	retentionDays int
 }
 
 func NewCleanup(
 	uploadTargets []UploadTarget,
+	notifyTargets []NotifyTarget,
 	logger Logger,
 	retentionDays int,
 ) *Cleanup {
 	return &Cleanup{
+		uploadTargets: uploadTargets,
+		notifyTargets: notifyTargets,
 		logger:        logger,
 		retentionDays: retentionDays,
 	}
@@ -30,53 +37,102 @@ func (uc *Cleanup) Execute(ctx context.Context) error {
 
 	cutoff := time.Now().AddDate(0, 0, -uc.retentionDays)
 
+	var results []domain.CleanupResult
 	if len(uc.uploadTargets) > 0 {
-		uc.cleanupTargets(ctx, cutoff)
+		results = uc.cleanupTargets(ctx, cutoff)
+	}
+
+	if len(uc.notifyTargets) > 0 {
+		notify(ctx, uc.logger, uc.notifyTargets, domain.Event{
+			Type:    domain.EventCleanupCompleted,
+			Time:    time.Now(),
+			Cleanup: results,
+		})
 	}
 
 	uc.logger.Infof("Cleanup completed")
 	return nil
 }
 
-func (uc *Cleanup) cleanupTargets(ctx context.Context, cutoff time.Time) {
+func (uc *Cleanup) cleanupTargets(ctx context.Context, cutoff time.Time) []domain.CleanupResult {
 	var wg sync.WaitGroup
+	results := make([]domain.CleanupResult, len(uc.uploadTargets))
 
-	for _, target := range uc.uploadTargets {
+	for i, target := range uc.uploadTargets {
 		wg.Add(1)
-		go func(t UploadTarget) {
+		go func(i int, t UploadTarget) {
 			defer wg.Done()
 
-			if err := uc.cleanupTarget(ctx, t, cutoff); err != nil {
-				uc.logger.Errorf("Cleanup failed for %s: %v", t.Name, err)
+			results[i] = uc.cleanupTarget(ctx, t, cutoff)
+			if results[i].Error != "" {
+				uc.logger.Errorf("Cleanup failed for %s: %s", t.Name, results[i].Error)
 			}
-		}(target)
+		}(i, target)
 	}
 
 	wg.Wait()
+	return results
 }
 
-func (uc *Cleanup) cleanupTarget(ctx context.Context, target UploadTarget, cutoff time.Time) error {
+func (uc *Cleanup) cleanupTarget(ctx context.Context, target UploadTarget, cutoff time.Time) domain.CleanupResult {
+	result := domain.CleanupResult{Target: target.Name}
+
 	files, err := target.Storage.GetOldFiles(ctx, cutoff)
 	if err != nil {
 		files, err = uc.fallbackListFiles(ctx, target, cutoff)
 		if err != nil {
-			return err
+			result.Error = err.Error()
+			return result
 		}
 	}
 
-	deleted := 0
+	files, err = uc.retainDependencies(ctx, target, files)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
 	for _, filename := range files {
 		uc.logger.Infof("Deleting old backup from %s: %s", target.Name, filename)
 
 		if err := target.Storage.Delete(ctx, filename); err != nil {
 			uc.logger.Errorf("Failed to delete %s from %s: %v", filename, target.Name, err)
+			result.Failed++
 		} else {
-			deleted++
+			result.Deleted++
 		}
 	}
 
-	uc.logger.Infof("Deleted %d old backup(s) from %s", deleted, target.Name)
-	return nil
+	uc.logger.Infof("Deleted %d old backup(s) from %s", result.Deleted, target.Name)
+
+	if pruner, ok := target.Storage.(domain.Pruner); ok {
+		if err := pruner.Prune(ctx); err != nil {
+			result.Error = fmt.Sprintf("prune: %v", err)
+		}
+	}
+	return result
+}
+
+// retainDependencies keeps expired files that remaining backups still
+// depend on: WAL needed by kept base backups, and the earlier links of
+// kept incremental backup chains.
+func (uc *Cleanup) retainDependencies(ctx context.Context, target UploadTarget, files []string) ([]string, error) {
+	if !slices.ContainsFunc(files, func(f string) bool {
+		return baseBackupPattern.MatchString(f) || archivedWALPattern.MatchString(f) || chainBackupPattern.MatchString(f)
+	}) {
+		return files, nil
+	}
+
+	all, err := target.Storage.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list files for retention: %w", err)
+	}
+
+	remaining := retainChains(all, retainWAL(all, files))
+	if kept := len(files) - len(remaining); kept > 0 {
+		uc.logger.Infof("Keeping %d expired file(s) on %s that retained backups depend on", kept, target.Name)
+	}
+	return remaining, nil
 }
 
 func (uc *Cleanup) fallbackListFiles(ctx context.Context, target UploadTarget, cutoff time.Time) ([]string, error) {
@@ -110,5 +166,5 @@ func extractTimestamp(filename string) (time.Time, error) {
 	}
 
 	timestampStr := matches[1] + "_" + matches[2]
-	return time.Parse("20060102_150405", timestampStr)
+	return time.ParseInLocation("20060102_150405", timestampStr, time.Local)
 }
@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+type NotifyTarget struct {
+	Name     string
+	Notifier domain.Notifier
+}
+
+// notify delivers the event to every notifier in parallel. Delivery failures
+// are logged but never fail the job that produced the event.
+func notify(ctx context.Context, logger Logger, targets []NotifyTarget, event domain.Event) {
+	var wg sync.WaitGroup
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(t NotifyTarget) {
+			defer wg.Done()
+
+			if err := t.Notifier.Notify(ctx, event); err != nil {
+				logger.Errorf("Failed to send %s notification via %s: %v", event.Type, t.Name, err)
+			}
+		}(target)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,214 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// Restore recovers a MySQL database to a point in time: it restores the
+// newest full dump taken before that time and replays archived binlogs on
+// top of it.
+type Restore struct {
+	db         domain.Database
+	restorer   domain.PointInTimeRestorer
+	storage    domain.Storage
+	compressor domain.Compressor
+	logger     Logger
+}
+
+func NewRestore(
+	db domain.Database,
+	restorer domain.PointInTimeRestorer,
+	storage domain.Storage,
+	compressor domain.Compressor,
+	logger Logger,
+) *Restore {
+	return &Restore{
+		db:         db,
+		restorer:   restorer,
+		storage:    storage,
+		compressor: compressor,
+		logger:     logger,
+	}
+}
+
+// Execute restores the database to stopTime. With dryRun it only logs the
+// plan.
+func (uc *Restore) Execute(ctx context.Context, stopTime time.Time, dryRun bool) error {
+	dbName := uc.db.Name()
+
+	downloader, ok := uc.storage.(domain.Downloader)
+	if !ok {
+		return errors.New("upload target does not support downloads")
+	}
+
+	files, err := uc.storage.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list backups: %w", err)
+	}
+
+	dump, err := uc.latestDump(files, stopTime)
+	if err != nil {
+		return err
+	}
+	uc.logger.Infof("[%s] Restoring full dump %s", dbName, dump)
+
+	tempDir, err := os.MkdirTemp("", "phylax-restore")
+	if err != nil {
+		return fmt.Errorf("create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dumpPath, err := uc.fetch(ctx, downloader, tempDir, dump)
+	if err != nil {
+		return err
+	}
+
+	start, err := uc.restorer.BinlogPosition(dumpPath)
+	if err != nil {
+		return fmt.Errorf("read binlog coordinates: %w", err)
+	}
+
+	binlogs, err := uc.binlogsFrom(files, start.File)
+	if err != nil {
+		return err
+	}
+
+	uc.logger.Infof("[%s] Replaying %d binlog(s) from %s:%d until %s",
+		dbName, len(binlogs), start.File, start.Position, stopTime.Format(time.RFC3339))
+	if len(binlogs) > 0 && binlogs[len(binlogs)-1].archived.Before(stopTime) {
+		uc.logger.Warnf("[%s] Newest archived binlog is from %s; changes after it cannot be recovered",
+			dbName, binlogs[len(binlogs)-1].archived.Format(time.RFC3339))
+	}
+
+	if dryRun {
+		for _, b := range binlogs {
+			uc.logger.Infof("[%s] Would replay %s", dbName, b.remote)
+		}
+		return nil
+	}
+
+	var binlogPaths []string
+	for _, b := range binlogs {
+		path, err := uc.fetch(ctx, downloader, tempDir, b.remote)
+		if err != nil {
+			return err
+		}
+		binlogPaths = append(binlogPaths, path)
+	}
+
+	if err := uc.restorer.Restore(ctx, dumpPath); err != nil {
+		return fmt.Errorf("restore dump: %w", err)
+	}
+	if err := uc.restorer.ReplayBinlogs(ctx, binlogPaths, start, stopTime); err != nil {
+		return fmt.Errorf("replay binlogs: %w", err)
+	}
+
+	uc.logger.Infof("[%s] Restored to %s", dbName, stopTime.Format(time.RFC3339))
+	return nil
+}
+
+// latestDump returns the newest full dump of the database taken at or
+// before stopTime.
+func (uc *Restore) latestDump(files []string, stopTime time.Time) (string, error) {
+	prefix := uc.db.Name() + "_" + uc.db.Type() + "_"
+
+	var latest string
+	var latestTime time.Time
+	for _, file := range files {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		timestamp, err := extractTimestamp(strings.TrimPrefix(file, prefix))
+		if err != nil || timestamp.After(stopTime) {
+			continue
+		}
+		if latest == "" || timestamp.After(latestTime) {
+			latest, latestTime = file, timestamp
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no full dump of %s found before %s", uc.db.Name(), stopTime.Format(time.RFC3339))
+	}
+	return latest, nil
+}
+
+type archivedBinlog struct {
+	remote   string
+	file     string
+	seq      int
+	archived time.Time
+}
+
+// binlogsFrom returns the archived binlogs from startFile on, in order,
+// and fails if any binlog in between is missing.
+func (uc *Restore) binlogsFrom(files []string, startFile string) ([]archivedBinlog, error) {
+	startSeq, ok := binlogSequence(startFile)
+	if !ok {
+		return nil, fmt.Errorf("invalid binlog file name %q", startFile)
+	}
+	prefix := uc.db.Name() + "_binlog_"
+
+	bySeq := make(map[int]archivedBinlog)
+	for _, file := range files {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		matches := archivedBinlogPattern.FindStringSubmatch(file)
+		if matches == nil {
+			continue
+		}
+		seq, ok := binlogSequence(matches[2])
+		if !ok || seq < startSeq {
+			continue
+		}
+		archived, _ := time.ParseInLocation("20060102_150405", matches[1], time.Local)
+		bySeq[seq] = archivedBinlog{remote: file, file: matches[2], seq: seq, archived: archived}
+	}
+
+	binlogs := make([]archivedBinlog, 0, len(bySeq))
+	for _, b := range bySeq {
+		binlogs = append(binlogs, b)
+	}
+	sort.Slice(binlogs, func(i, j int) bool { return binlogs[i].seq < binlogs[j].seq })
+
+	if len(binlogs) == 0 || binlogs[0].seq != startSeq {
+		return nil, fmt.Errorf("binlog %s has not been archived", startFile)
+	}
+	for i := 1; i < len(binlogs); i++ {
+		if binlogs[i].seq != binlogs[i-1].seq+1 {
+			return nil, fmt.Errorf("binlog archive has a gap after %s", binlogs[i-1].file)
+		}
+	}
+	return binlogs, nil
+}
+
+// fetch downloads remoteName into dir and decompresses it if needed.
+func (uc *Restore) fetch(ctx context.Context, downloader domain.Downloader, dir, remoteName string) (string, error) {
+	path := filepath.Join(dir, remoteName)
+	if err := downloader.Download(ctx, remoteName, path); err != nil {
+		return "", fmt.Errorf("download %s: %w", remoteName, err)
+	}
+
+	// Plain dumps end in .sql and plain binlogs in their sequence number;
+	// anything else was compressed.
+	if _, isBinlog := binlogSequence(remoteName); strings.HasSuffix(remoteName, ".sql") || isBinlog {
+		return path, nil
+	}
+
+	plainPath := strings.TrimSuffix(path, filepath.Ext(path))
+	if err := uc.compressor.Decompress(path, plainPath); err != nil {
+		return "", fmt.Errorf("decompress %s: %w", remoteName, err)
+	}
+	os.Remove(path)
+	return plainPath, nil
+}
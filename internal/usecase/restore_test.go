@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/semmidev/phylax/internal/adapter/compressor"
+	"github.com/semmidev/phylax/internal/adapter/storage"
+	"github.com/semmidev/phylax/internal/domain"
+	"github.com/semmidev/phylax/internal/infrastructure/logger"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeRestorer reports fixed binlog coordinates and records what it
+// restores and replays.
+type fakeRestorer struct {
+	start    domain.BinlogPosition
+	restored string
+	replayed []string
+	stopTime time.Time
+}
+
+func (f *fakeRestorer) Backup(ctx context.Context, outputPath string) error { return nil }
+func (f *fakeRestorer) Name() string                                        { return "orders" }
+func (f *fakeRestorer) Type() string                                        { return "mysql" }
+func (f *fakeRestorer) Ping(ctx context.Context) error                      { return nil }
+
+func (f *fakeRestorer) BinlogPosition(dumpPath string) (domain.BinlogPosition, error) {
+	return f.start, nil
+}
+
+func (f *fakeRestorer) Restore(ctx context.Context, dumpPath string) error {
+	data, err := os.ReadFile(dumpPath)
+	f.restored = string(data)
+	return err
+}
+
+func (f *fakeRestorer) ReplayBinlogs(ctx context.Context, files []string, start domain.BinlogPosition, stopTime time.Time) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		f.replayed = append(f.replayed, string(data))
+	}
+	f.stopTime = stopTime
+	return nil
+}
+
+func TestRestorePlan(t *testing.T) {
+	Convey("Given dumps and archived binlogs on a target", t, func() {
+		db := &fakeRestorer{}
+		restore := NewRestore(db, db, nil, nil, nil)
+		files := []string{
+			"orders_mysql_20240101_000000.sql.gz",
+			"orders_mysql_20240102_000000.sql.gz",
+			"orders_mysql_20240103_000000.sql.gz",
+			"orders_mydumper_20240102_120000.tar.gz",
+			"other_mysql_20240102_120000.sql.gz",
+			"orders_binlog_20240101_120000_binlog.000004.gz",
+			"orders_binlog_20240102_060000_binlog.000005.gz",
+			"orders_binlog_20240102_120000_binlog.000006.gz",
+			"orders_binlog_20240102_180000_binlog.000007.gz",
+			"other_binlog_20240102_180000_binlog.000008.gz",
+		}
+		at := func(value string) time.Time {
+			t, err := time.ParseInLocation(time.DateTime, value, time.Local)
+			So(err, ShouldBeNil)
+			return t
+		}
+
+		Convey("latestDump should pick the newest dump of this database before the stop time", func() {
+			dump, err := restore.latestDump(files, at("2024-01-02 15:00:00"))
+			So(err, ShouldBeNil)
+			So(dump, ShouldEqual, "orders_mysql_20240102_000000.sql.gz")
+
+			dump, err = restore.latestDump(files, at("2024-01-02 00:00:00"))
+			So(err, ShouldBeNil)
+			So(dump, ShouldEqual, "orders_mysql_20240102_000000.sql.gz")
+		})
+
+		Convey("latestDump should fail when every dump is newer", func() {
+			_, err := restore.latestDump(files, at("2023-12-31 00:00:00"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "no full dump")
+		})
+
+		Convey("binlogsFrom should return the binlogs from the start file in order", func() {
+			binlogs, err := restore.binlogsFrom(files, "binlog.000005")
+			So(err, ShouldBeNil)
+			So(binlogs, ShouldHaveLength, 3)
+			So(binlogs[0].remote, ShouldEqual, "orders_binlog_20240102_060000_binlog.000005.gz")
+			So(binlogs[2].file, ShouldEqual, "binlog.000007")
+			So(binlogs[2].archived, ShouldEqual, at("2024-01-02 18:00:00"))
+		})
+
+		Convey("binlogsFrom should fail on a gap in the archive", func() {
+			// Drop binlog.000006.
+			withGap := append([]string{}, files[:7]...)
+			withGap = append(withGap, files[8:]...)
+
+			_, err := restore.binlogsFrom(withGap, "binlog.000005")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "gap after binlog.000005")
+		})
+
+		Convey("binlogsFrom should fail when the start file was never archived", func() {
+			_, err := restore.binlogsFrom(files, "binlog.000003")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "binlog.000003 has not been archived")
+
+			_, err = restore.binlogsFrom(files, "binlog.000008")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRestoreExecute(t *testing.T) {
+	Convey("Given a local target with a dump and binlogs", t, func() {
+		tempDir, err := os.MkdirTemp("", "restore_test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		local, err := storage.NewLocal(filepath.Join(tempDir, "target"))
+		So(err, ShouldBeNil)
+		log, err := logger.New("fatal", "")
+		So(err, ShouldBeNil)
+		gzip := compressor.NewGzip()
+		ctx := context.Background()
+
+		store := func(remoteName, content string, compress bool) {
+			path := filepath.Join(tempDir, "source")
+			So(os.WriteFile(path, []byte(content), 0600), ShouldBeNil)
+			if compress {
+				So(gzip.Compress(path, path+".gz"), ShouldBeNil)
+				path += ".gz"
+			}
+			So(local.Upload(ctx, path, remoteName), ShouldBeNil)
+		}
+
+		store("orders_mysql_20240102_000000.sql.gz", "full dump", true)
+		store("orders_binlog_20240102_060000_binlog.000005", "plain binlog 5", false)
+		store("orders_binlog_20240102_120000_binlog.000006.gz", "compressed binlog 6", true)
+
+		db := &fakeRestorer{start: domain.BinlogPosition{File: "binlog.000005", Position: 157}}
+		restore := NewRestore(db, db, local, gzip, log)
+		stopTime := time.Date(2024, 1, 2, 13, 0, 0, 0, time.Local)
+
+		Convey("It should restore the dump and replay decompressed binlogs in order", func() {
+			So(restore.Execute(ctx, stopTime, false), ShouldBeNil)
+			So(db.restored, ShouldEqual, "full dump")
+			So(db.replayed, ShouldResemble, []string{"plain binlog 5", "compressed binlog 6"})
+			So(db.stopTime, ShouldEqual, stopTime)
+		})
+
+		Convey("A dry run should change nothing", func() {
+			So(restore.Execute(ctx, stopTime, true), ShouldBeNil)
+			So(db.restored, ShouldBeEmpty)
+			So(db.replayed, ShouldBeEmpty)
+		})
+
+		Convey("It should refuse to restore across a gap", func() {
+			store("orders_binlog_20240102_180000_binlog.000008", "binlog 8", false)
+
+			err := restore.Execute(ctx, stopTime, false)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "gap")
+			So(db.restored, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/semmidev/phylax/internal/domain"
+)
+
+// compressionExtensions are the extensions compressed archive files may
+// carry, whichever algorithm is configured now.
+var compressionExtensions = []string{".gz", ".zst", ".xz", ".lz4"}
+
+var (
+	baseBackupPattern  = regexp.MustCompile(`^(.+)_pgbasebackup_(\d{8}_\d{6})`)
+	archivedWALPattern = regexp.MustCompile(`^(.+)_wal_(\d{8}_\d{6})_`)
+)
+
+// WALArchive stores PostgreSQL WAL segments on the upload targets. It backs
+// the server's archive_command (Push) and restore_command (Fetch).
+type WALArchive struct {
+	dbName        string
+	uploadTargets []UploadTarget
+	compressor    domain.Compressor
+	logger        Logger
+	compress      bool
+}
+
+func NewWALArchive(
+	dbName string,
+	uploadTargets []UploadTarget,
+	compressor domain.Compressor,
+	logger Logger,
+	compress bool,
+) *WALArchive {
+	return &WALArchive{
+		dbName:        dbName,
+		uploadTargets: uploadTargets,
+		compressor:    compressor,
+		logger:        logger,
+		compress:      compress,
+	}
+}
+
+// Push uploads the WAL file at path to every target. It fails unless all
+// targets succeed, so the server keeps the segment and retries.
+func (w *WALArchive) Push(ctx context.Context, path string) error {
+	if len(w.uploadTargets) == 0 {
+		return errors.New("no upload targets configured")
+	}
+
+	walName := filepath.Base(path)
+	rawName := fmt.Sprintf("%s_wal_%s_%s", w.dbName, time.Now().Format("20060102_150405"), walName)
+	files := uploadFiles{path: path, filename: rawName, rawPath: path, rawFilename: rawName}
+
+	if w.compress {
+		files.filename = rawName + w.compressor.Extension()
+		files.path = filepath.Join(os.TempDir(), files.filename)
+		if err := w.compressor.Compress(path, files.path); err != nil {
+			return fmt.Errorf("compression: %w", err)
+		}
+		defer os.Remove(files.path)
+	}
+
+	if err := uploadToAll(ctx, w.uploadTargets, files); err != nil {
+		return fmt.Errorf("archive %s: %w", walName, err)
+	}
+
+	w.logger.Infof("[%s] Archived WAL %s", w.dbName, walName)
+	return nil
+}
+
+// Fetch restores the archived WAL file walName to dest, trying the targets
+// in order. It returns an error when no target has it, which tells the
+// server the archive ends there.
+func (w *WALArchive) Fetch(ctx context.Context, walName string, dest string) error {
+	for _, target := range w.uploadTargets {
+		downloader, ok := target.Storage.(domain.Downloader)
+		if !ok {
+			continue
+		}
+
+		files, err := target.Storage.List(ctx)
+		if err != nil {
+			w.logger.Warnf("[%s] Failed to list %s: %v", w.dbName, target.Name, err)
+			continue
+		}
+		remoteName := w.findWAL(files, walName)
+		if remoteName == "" {
+			continue
+		}
+
+		if err := w.download(ctx, downloader, remoteName, dest); err != nil {
+			w.logger.Warnf("[%s] Failed to fetch %s from %s: %v", w.dbName, walName, target.Name, err)
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("WAL %s not found in archive", walName)
+}
+
+// findWAL returns the newest archived copy of walName among files.
+func (w *WALArchive) findWAL(files []string, walName string) string {
+	prefix := w.dbName + "_wal_"
+
+	var found string
+	for _, file := range files {
+		rest, ok := strings.CutPrefix(file, prefix)
+		// rest is "<YYYYMMDD_HHMMSS>_<wal name>[<compression extension>]"
+		if !ok || len(rest) < 16 || rest[15] != '_' {
+			continue
+		}
+		suffix, ok := strings.CutPrefix(rest[16:], walName)
+		if !ok || (suffix != "" && !slices.Contains(compressionExtensions, suffix)) {
+			continue
+		}
+		// Retried pushes leave several copies; the timestamp sorts them.
+		if file > found {
+			found = file
+		}
+	}
+	return found
+}
+
+func (w *WALArchive) download(ctx context.Context, downloader domain.Downloader, remoteName, dest string) error {
+	if !slices.Contains(compressionExtensions, filepath.Ext(remoteName)) {
+		return downloader.Download(ctx, remoteName, dest)
+	}
+
+	tempPath := filepath.Join(os.TempDir(), remoteName)
+	if err := downloader.Download(ctx, remoteName, tempPath); err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+
+	if err := w.compressor.Decompress(tempPath, dest); err != nil {
+		return fmt.Errorf("decompression: %w", err)
+	}
+	return nil
+}
+
+// retainWAL removes from old the files point-in-time recovery still needs:
+// every WAL file archived since the oldest base backup that is kept, and,
+// when all base backups of a database have expired, the newest of them.
+// WAL of a database without any base backup on this target is kept too, as
+// are timeline history files, which recovery and promotion read for the
+// life of the archive.
+func retainWAL(all, old []string) []string {
+	deleting := make(map[string]bool, len(old))
+	for _, file := range old {
+		deleting[file] = true
+	}
+
+	type baseBackup struct {
+		file      string
+		timestamp string
+	}
+	bases := make(map[string][]baseBackup)
+	for _, file := range all {
+		if m := baseBackupPattern.FindStringSubmatch(file); m != nil {
+			bases[m[1]] = append(bases[m[1]], baseBackup{file: file, timestamp: m[2]})
+		}
+	}
+
+	oldestKept := make(map[string]string)
+	for db, backups := range bases {
+		newest := backups[0]
+		for _, b := range backups {
+			if b.timestamp > newest.timestamp {
+				newest = b
+			}
+			if !deleting[b.file] && (oldestKept[db] == "" || b.timestamp < oldestKept[db]) {
+				oldestKept[db] = b.timestamp
+			}
+		}
+		if oldestKept[db] == "" {
+			delete(deleting, newest.file)
+			oldestKept[db] = newest.timestamp
+		}
+	}
+
+	for _, file := range old {
+		m := archivedWALPattern.FindStringSubmatch(file)
+		if m == nil {
+			continue
+		}
+		name := file
+		if ext := filepath.Ext(name); slices.Contains(compressionExtensions, ext) {
+			name = strings.TrimSuffix(name, ext)
+		}
+		if strings.HasSuffix(name, ".history") {
+			delete(deleting, file)
+			continue
+		}
+		// Timestamps are fixed-width, so string order is time order.
+		if start, ok := oldestKept[m[1]]; !ok || m[2] >= start {
+			delete(deleting, file)
+		}
+	}
+
+	remaining := make([]string, 0, len(old))
+	for _, file := range old {
+		if deleting[file] {
+			remaining = append(remaining, file)
+		}
+	}
+	return remaining
+}
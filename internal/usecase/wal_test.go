@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetainWAL(t *testing.T) {
+	Convey("Given base backups and archived WAL on a target", t, func() {
+		all := []string{
+			"pg_pgbasebackup_20240101_000000.tar.gz",
+			"pg_wal_20231231_230000_000000010000000000000001.gz",
+			"pg_wal_20240101_010000_000000010000000000000002.gz",
+			"pg_pgbasebackup_20240105_000000.tar.gz",
+			"pg_wal_20240105_010000_000000010000000000000009.gz",
+			"other_wal_20240101_000000_000000010000000000000001.gz",
+			"pg_wal_20231231_220000_00000002.history.gz",
+			"pg_wal_20231231_220000_00000003.history",
+		}
+
+		Convey("WAL older than the oldest kept base backup should be deleted", func() {
+			old := []string{
+				"pg_pgbasebackup_20240101_000000.tar.gz",
+				"pg_wal_20231231_230000_000000010000000000000001.gz",
+				"pg_wal_20240101_010000_000000010000000000000002.gz",
+			}
+			So(retainWAL(all, old), ShouldResemble, old)
+		})
+
+		Convey("WAL needed by a kept base backup should stay even when expired", func() {
+			old := []string{
+				"pg_wal_20231231_230000_000000010000000000000001.gz",
+				"pg_wal_20240101_010000_000000010000000000000002.gz",
+			}
+			So(retainWAL(all, old), ShouldResemble, []string{
+				"pg_wal_20231231_230000_000000010000000000000001.gz",
+			})
+		})
+
+		Convey("The newest base backup and its WAL should stay when every base backup expired", func() {
+			remaining := retainWAL(all, all[:5])
+			So(remaining, ShouldResemble, []string{
+				"pg_pgbasebackup_20240101_000000.tar.gz",
+				"pg_wal_20231231_230000_000000010000000000000001.gz",
+				"pg_wal_20240101_010000_000000010000000000000002.gz",
+			})
+		})
+
+		Convey("Timeline history files should stay however old they are", func() {
+			old := []string{
+				"pg_wal_20231231_220000_00000002.history.gz",
+				"pg_wal_20231231_220000_00000003.history",
+				"pg_wal_20231231_230000_000000010000000000000001.gz",
+			}
+			So(retainWAL(all, old), ShouldResemble, []string{
+				"pg_wal_20231231_230000_000000010000000000000001.gz",
+			})
+		})
+
+		Convey("WAL of a database without base backups should stay", func() {
+			So(retainWAL(all, []string{"other_wal_20240101_000000_000000010000000000000001.gz"}), ShouldBeEmpty)
+		})
+
+		Convey("Other files should pass through", func() {
+			old := []string{"mysql_mysql_20240101_000000.sql.gz"}
+			So(retainWAL(all, old), ShouldResemble, old)
+		})
+	})
+}
+
+func TestWALArchiveFind(t *testing.T) {
+	Convey("Given archived WAL files", t, func() {
+		archive := NewWALArchive("pg", nil, nil, nil, true)
+		files := []string{
+			"pg_wal_20240101_010000_000000010000000000000002.gz",
+			"pg_wal_20240101_010500_000000010000000000000002.zst",
+			"pg_wal_20240101_010000_000000010000000000000002.00000028.backup.gz",
+			"pg_wal_20240101_020000_000000010000000000000003.partial",
+			"pg_wal_20240101_020000_00000002.history",
+		}
+
+		Convey("It should return the newest copy of an exact name", func() {
+			So(archive.findWAL(files, "000000010000000000000002"), ShouldEqual, files[1])
+			So(archive.findWAL(files, "00000002.history"), ShouldEqual, files[4])
+		})
+
+		Convey("It should not match partial segments or other names", func() {
+			So(archive.findWAL(files, "000000010000000000000003"), ShouldBeEmpty)
+			So(archive.findWAL(files, "000000010000000000000004"), ShouldBeEmpty)
+		})
+	})
+}